@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/auth"
+	"github.com/francisco3ferraz/zk-auth/internal/config"
+	"github.com/francisco3ferraz/zk-auth/internal/database"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+)
+
+const usage = `Usage: admin <command> [flags]
+
+Commands:
+  rotate-key [-alg EdDSA|ES256|RS256]  Generate a new signing key and retire the active one
+  unlock <username>                    Clear failed logins so the username can log in again
+  grant-role <username> <role>         Give a user a role, e.g. admin
+  revoke-role <username> <role>        Take a role from a user
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(os.Args[1], os.Args[2:]); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(command string, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if err := logger.Initialize(cfg.Server.Environment); err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	defer logger.Sync()
+
+	db, err := database.New(&cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	switch command {
+	case "rotate-key":
+		return rotateKey(ctx, cfg, db, args)
+	case "unlock":
+		return unlock(ctx, db, args)
+	case "grant-role":
+		return grantRole(ctx, db, args)
+	case "revoke-role":
+		return revokeRole(ctx, db, args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// rotateKey makes a new key active. The previous key keeps verifying tokens
+// until the last one it signed has expired.
+func rotateKey(ctx context.Context, cfg *config.Config, db *database.DB, args []string) error {
+	flags := flag.NewFlagSet("rotate-key", flag.ContinueOnError)
+	alg := flags.String("alg", "EdDSA", "signing algorithm: EdDSA, ES256 or RS256")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	encryptionKey, err := auth.SigningKeyEncryptionKey(&cfg.Security)
+	if err != nil {
+		return err
+	}
+
+	key, err := auth.GenerateSigningKey(*alg)
+	if err != nil {
+		return err
+	}
+	sealed, err := auth.SealSigningKey(encryptionKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt key: %w", err)
+	}
+
+	acceptUntil := time.Now().Add(auth.RetirementGrace(cfg.Security.JWTExpiry))
+	repo := model.NewSigningKeyRepository(db.Pool())
+	err = repo.Rotate(ctx, &model.SigningKey{
+		KID:                 key.ID,
+		Algorithm:           key.Method.Alg(),
+		PrivateKeyEncrypted: sealed,
+	}, acceptUntil)
+	if err != nil {
+		return fmt.Errorf("failed to store key: %w", err)
+	}
+
+	fmt.Printf("Active signing key is now %s (%s)\n", key.ID, key.Method.Alg())
+	fmt.Printf("The previous key is accepted until %s\n", acceptUntil.Format(time.RFC3339))
+	return nil
+}
+
+// unlock clears the failed logins of a username, lifting its lock and
+// backoff delay
+func unlock(ctx context.Context, db *database.DB, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: admin unlock <username>")
+	}
+	username := args[0]
+
+	err := model.NewLoginFailureRepository(db.Pool()).Delete(ctx, username)
+	if err == sql.ErrNoRows {
+		fmt.Printf("%s has no failed logins\n", username)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unlock: %w", err)
+	}
+
+	fmt.Printf("Unlocked %s\n", username)
+	return nil
+}
+
+// grantRole gives a user a role. This is how the first admin is created;
+// later grants can go through the admin API.
+func grantRole(ctx context.Context, db *database.DB, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: admin grant-role <username> <role>")
+	}
+	username, role := args[0], args[1]
+
+	user, err := model.NewUserRepository(db.Pool()).GetByUsername(ctx, username)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user %s not found", username)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to retrieve user: %w", err)
+	}
+
+	err = model.NewRoleRepository(db.Pool()).Grant(ctx, user.ID, role)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("role %s not found", role)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to grant role: %w", err)
+	}
+
+	fmt.Printf("Granted %s to %s; it applies once their token is refreshed\n", role, username)
+	return nil
+}
+
+// revokeRole takes a role from a user. Unlike the admin API it cannot
+// revoke tokens already issued, which keep the role until they expire.
+func revokeRole(ctx context.Context, db *database.DB, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: admin revoke-role <username> <role>")
+	}
+	username, role := args[0], args[1]
+
+	user, err := model.NewUserRepository(db.Pool()).GetByUsername(ctx, username)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user %s not found", username)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to retrieve user: %w", err)
+	}
+
+	err = model.NewRoleRepository(db.Pool()).Revoke(ctx, user.ID, role)
+	if err == sql.ErrNoRows {
+		fmt.Printf("%s does not have role %s\n", username, role)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke role: %w", err)
+	}
+
+	fmt.Printf("Revoked %s from %s; tokens already issued keep it until they expire\n", role, username)
+	return nil
+}
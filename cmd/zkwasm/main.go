@@ -0,0 +1,103 @@
+//go:build js && wasm
+
+// Command zkwasm is the SRP client of the OpenID Connect login page, built
+// to WebAssembly from pkg/zkclient:
+//
+//	GOOS=js GOARCH=wasm go build -o web/zkclient.wasm ./cmd/zkwasm
+//
+// It defines zkAuthLogin(username, password), which returns a promise of
+// {token} or, for accounts with a second factor, {mfaRequired: true}; the
+// login is then completed with zkAuthTOTP(code), a promise of the token.
+package main
+
+import (
+	"context"
+	"errors"
+	"syscall/js"
+
+	"github.com/francisco3ferraz/zk-auth/pkg/zkclient"
+)
+
+// client keeps the pending login between zkAuthLogin and zkAuthTOTP
+var client *zkclient.Client
+
+func main() {
+	client = zkclient.New(js.Global().Get("location").Get("origin").String(), nil)
+	js.Global().Set("zkAuthLogin", js.FuncOf(login))
+	js.Global().Set("zkAuthTOTP", js.FuncOf(loginTOTP))
+	select {}
+}
+
+func login(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return reject("zkAuthLogin expects a username and a password")
+	}
+	username, password := args[0].String(), args[1].String()
+
+	return promise(func() (interface{}, error) {
+		session, err := client.Login(context.Background(), username, password)
+		if errors.Is(err, zkclient.ErrMFARequired) {
+			return map[string]interface{}{"mfaRequired": true}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"token": session.Token}, nil
+	})
+}
+
+func loginTOTP(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return reject("zkAuthTOTP expects a code")
+	}
+	code := args[0].String()
+
+	return promise(func() (interface{}, error) {
+		session, err := client.LoginTOTP(context.Background(), code)
+		if err != nil {
+			return nil, err
+		}
+		return session.Token, nil
+	})
+}
+
+// promise runs fn on its own goroutine, since blocking calls such as HTTP
+// requests must not run on the JavaScript event loop
+func promise(fn func() (interface{}, error)) js.Value {
+	var executor js.Func
+	executor = js.FuncOf(func(this js.Value, p []js.Value) interface{} {
+		resolve, rejectFn := p[0], p[1]
+		go func() {
+			defer executor.Release()
+
+			result, err := fn()
+			if err != nil {
+				rejectFn.Invoke(js.Global().Get("Error").New(message(err)))
+				return
+			}
+			resolve.Invoke(js.ValueOf(result))
+		}()
+		return nil
+	})
+
+	return js.Global().Get("Promise").New(executor)
+}
+
+func reject(msg string) js.Value {
+	return js.Global().Get("Promise").Call("reject", js.Global().Get("Error").New(msg))
+}
+
+// message turns client errors into text for the login form
+func message(err error) string {
+	var apiErr *zkclient.Error
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.Message
+	case errors.Is(err, zkclient.ErrServerProof):
+		return "The server could not prove it knows your password."
+	case errors.Is(err, zkclient.ErrNotAuthenticated):
+		return "Sign in again."
+	default:
+		return "Login failed."
+	}
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"go.uber.org/zap"
+)
+
+// DeleteAccount deletes the caller's account after a fresh SRP or OPAQUE
+// proof. Every session is ended and its tokens revoked. With a grace period the account
+// is only scheduled for deletion, and logging in again allows cancelling it.
+func (s *Service) DeleteAccount(ctx context.Context, claims *TokenClaims, req *DeleteAccountRequest) (*DeleteAccountResponse, error) {
+	_, serverProof, err := s.verifyReauthProof(ctx, claims, &req.ReauthProof)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &DeleteAccountResponse{
+		ServerProof: hex.EncodeToString(serverProof),
+	}
+
+	if grace := s.config.Security.AccountDeletionGrace; grace > 0 {
+		deleteAfter := time.Now().Add(grace)
+		if err := s.userRepo.ScheduleDeletion(ctx, claims.UserID, deleteAfter); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, errors.NewNotFoundError("user")
+			}
+			return nil, errors.NewInternalError("failed to schedule account deletion")
+		}
+		s.revokeOtherSessions(ctx, claims.UserID, "")
+
+		logger.Info("Account scheduled for deletion",
+			zap.String("user_id", claims.UserID),
+			zap.Time("delete_after", deleteAfter))
+
+		resp.Message = "Account scheduled for deletion"
+		resp.DeleteAfter = &deleteAfter
+		return resp, nil
+	}
+
+	// Sessions are revoked first, as deleting the user deletes them too
+	s.revokeOtherSessions(ctx, claims.UserID, "")
+	if err := s.userRepo.Delete(ctx, claims.UserID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("user")
+		}
+		return nil, errors.NewInternalError("failed to delete account")
+	}
+	s.resetLoginFailures(ctx, claims.Username)
+
+	logger.Info("Account deleted", zap.String("user_id", claims.UserID))
+
+	resp.Message = "Account deleted"
+	return resp, nil
+}
+
+// CancelAccountDeletion keeps an account that is scheduled for deletion
+func (s *Service) CancelAccountDeletion(ctx context.Context, claims *TokenClaims) (*CancelAccountDeletionResponse, error) {
+	cancelled, err := s.userRepo.CancelDeletion(ctx, claims.UserID)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to cancel account deletion")
+	}
+	if !cancelled {
+		return nil, errors.NewConflictError("account is not scheduled for deletion")
+	}
+
+	logger.Info("Account deletion cancelled", zap.String("user_id", claims.UserID))
+
+	return &CancelAccountDeletionResponse{
+		Message: "Account deletion cancelled",
+	}, nil
+}
+
+// scheduledDeletion returns when the user's account will be deleted, or nil
+// if no deletion is scheduled
+func (s *Service) scheduledDeletion(ctx context.Context, userID string) (*time.Time, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("user")
+		}
+		return nil, errors.NewInternalError("failed to retrieve user")
+	}
+	return user.DeleteAfter, nil
+}
+
+// purgeDeletedAccounts deletes the accounts whose grace period has ended and
+// revokes the tokens of sessions opened since they were scheduled
+func (s *Service) purgeDeletedAccounts(ctx context.Context) {
+	ids, err := s.userRepo.ListDueForDeletion(ctx)
+	if err != nil {
+		logger.Warn("Failed to list accounts due for deletion", zap.Error(err))
+		return
+	}
+
+	for _, id := range ids {
+		sessions, err := s.sessionRepo.GetActiveByUserID(ctx, id)
+		if err != nil {
+			logger.Warn("Failed to list sessions for revocation",
+				zap.String("user_id", id),
+				zap.Error(err))
+			continue
+		}
+
+		// Only deleted if the deletion was not cancelled in the meantime
+		deleted, err := s.userRepo.DeleteIfDue(ctx, id)
+		if err != nil {
+			logger.Warn("Failed to delete account",
+				zap.String("user_id", id),
+				zap.Error(err))
+			continue
+		}
+		if !deleted {
+			continue
+		}
+
+		for _, session := range sessions {
+			s.revokeSessionToken(ctx, session)
+		}
+		logger.Info("Account deleted after grace period", zap.String("user_id", id))
+	}
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	// lastSeenInterval is how stale a session's last_seen_at may get before
+	// a request writes it again
+	lastSeenInterval = time.Minute
+	// Limits on the session metadata columns
+	maxIPAddressLength  = 255
+	maxUserAgentLength  = 512
+	maxDeviceNameLength = 64
+)
+
+// activityCache remembers when this instance last wrote each session's
+// last_seen_at, so that most requests skip the database entirely
+type activityCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func newActivityCache() *activityCache {
+	return &activityCache{seen: make(map[string]time.Time)}
+}
+
+// due reports whether sessionID should be touched at now, and if so
+// records that it was
+func (c *activityCache) due(sessionID string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if last, ok := c.seen[sessionID]; ok && now.Sub(last) < lastSeenInterval {
+		return false
+	}
+	c.seen[sessionID] = now
+	return true
+}
+
+// prune forgets sessions that have not been touched within the interval
+func (c *activityCache) prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, last := range c.seen {
+		if now.Sub(last) >= lastSeenInterval {
+			delete(c.seen, id)
+		}
+	}
+}
+
+// TouchSession records activity on a session. Writes are throttled to one
+// per lastSeenInterval, both per instance and in the database.
+func (s *Service) TouchSession(ctx context.Context, sessionID string) {
+	now := time.Now()
+	if !s.activity.due(sessionID, now) {
+		return
+	}
+
+	if err := s.sessionRepo.TouchLastSeen(ctx, sessionID, now.Add(-lastSeenInterval)); err != nil {
+		logger.Warn("Failed to update session last seen",
+			zap.String("session_id", sessionID),
+			zap.Error(err))
+	}
+}
+
+func (s *Service) cleanupActivityCache() {
+	s.activity.prune(time.Now())
+}
+
+// recordClient stores the caller's address and user agent on session
+func recordClient(ctx context.Context, session *model.Session) {
+	client, ok := ctx.Value(ClientContextKey).(*ClientInfo)
+	if !ok {
+		return
+	}
+	setClient(session, client)
+}
+
+func setClient(session *model.Session, client *ClientInfo) {
+	session.IPAddress = truncate(client.IPAddress, maxIPAddressLength)
+	session.UserAgent = truncate(client.UserAgent, maxUserAgentLength)
+}
+
+// normalizeDeviceName trims a client-supplied device label and rejects
+// labels that are too long or contain control characters
+func normalizeDeviceName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxDeviceNameLength {
+		return "", errors.NewValidationError("device_name must be at most 64 characters")
+	}
+	if !utf8.ValidString(name) || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return "", errors.NewValidationError("device_name contains invalid characters")
+	}
+	return name, nil
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
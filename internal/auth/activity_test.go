@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActivityCacheThrottles(t *testing.T) {
+	cache := newActivityCache()
+	now := time.Now()
+
+	if !cache.due("a", now) {
+		t.Fatal("first touch should be due")
+	}
+	if cache.due("a", now.Add(30*time.Second)) {
+		t.Error("touch within the interval should be skipped")
+	}
+	if !cache.due("b", now) {
+		t.Error("sessions should be throttled independently")
+	}
+	if !cache.due("a", now.Add(lastSeenInterval)) {
+		t.Error("touch after the interval should be due")
+	}
+
+	cache.prune(now.Add(2 * lastSeenInterval))
+	if len(cache.seen) != 0 {
+		t.Errorf("prune left %d entries", len(cache.seen))
+	}
+}
+
+func TestNormalizeDeviceName(t *testing.T) {
+	if got, err := normalizeDeviceName("  Work laptop "); err != nil || got != "Work laptop" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if got, err := normalizeDeviceName(""); err != nil || got != "" {
+		t.Errorf("empty name: got %q, %v", got, err)
+	}
+	if _, err := normalizeDeviceName(strings.Repeat("x", maxDeviceNameLength+1)); err == nil {
+		t.Error("overlong name accepted")
+	}
+	if _, err := normalizeDeviceName("phone\n"); err != nil {
+		t.Errorf("trailing newline should be trimmed: %v", err)
+	}
+	if _, err := normalizeDeviceName("ph\x00one"); err == nil {
+		t.Error("control character accepted")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("héllo", 2); got != "h" {
+		t.Errorf("truncate split a rune: %q", got)
+	}
+	if got := truncate("abc", 5); got != "abc" {
+		t.Errorf("got %q", got)
+	}
+}
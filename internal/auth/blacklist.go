@@ -5,10 +5,10 @@ import (
 	"time"
 )
 
-// TokenBlacklist manages revoked tokens in memory
+// TokenBlacklist manages revoked token IDs in memory
 // Tokens are stored with their expiration time for automatic cleanup
 type TokenBlacklist struct {
-	tokens map[string]time.Time // token -> expiration time
+	tokens map[string]time.Time // jti -> expiration time
 	mu     sync.RWMutex
 }
 
@@ -1,8 +1,16 @@
 package auth
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/config"
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 func TestTokenBlacklist_RevokeAndCheck(t *testing.T) {
@@ -41,3 +49,184 @@ func TestTokenBlacklist_DifferentTokensIndependent(t *testing.T) {
 		t.Error("token2 should not be revoked")
 	}
 }
+
+func TestValidateToken_RevocationMessages(t *testing.T) {
+	key := newHMACKey("secret")
+	keyring := NewKeyring(key, nil, nil, time.Hour)
+	s := &Service{
+		keyring:     keyring,
+		revocations: NewRevocations(nil),
+		config:      &config.Config{},
+	}
+
+	sign := func(jti string) string {
+		claims := TokenClaims{RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}}
+		token, err := jwt.NewWithClaims(key.Method, claims).SignedString(key.private)
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return token
+	}
+	message := func(err error) string {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr.Message
+		}
+		return ""
+	}
+
+	if _, err := s.ValidateToken(sign("")); message(err) != "invalid token" {
+		t.Errorf("token without jti: error = %v, want invalid token", err)
+	}
+
+	s.revocations.Revoke(context.Background(), "jti-1", time.Now().Add(time.Hour))
+	if _, err := s.ValidateToken(sign("jti-1")); message(err) != "token has been revoked" {
+		t.Errorf("revoked token: error = %v, want token has been revoked", err)
+	}
+
+	if _, err := s.ValidateToken(sign("jti-2")); err != nil {
+		t.Errorf("valid token rejected: %v", err)
+	}
+}
+
+func TestValidateToken_ClientTokens(t *testing.T) {
+	key := newHMACKey("secret")
+	s := &Service{
+		keyring:     NewKeyring(key, nil, nil, time.Hour),
+		revocations: NewRevocations(nil),
+		config:      &config.Config{Security: config.SecurityConfig{JWTExpiry: time.Hour}},
+	}
+
+	session := &model.Session{ID: "session-1", UserID: "user-1", ClientID: "client-1", CreatedAt: time.Now()}
+	token, _, err := s.generateToken(session, "alice", nil, nil)
+	if err != nil {
+		t.Fatalf("generateToken() error = %v", err)
+	}
+
+	if _, err := s.ValidateToken(token); err == nil {
+		t.Error("client token accepted as a first-party token")
+	}
+	claims, err := s.ValidateClientToken(token)
+	if err != nil {
+		t.Fatalf("ValidateClientToken() error = %v", err)
+	}
+	if claims.ClientID != "client-1" || len(claims.Audience) != 1 || claims.Audience[0] != "client-1" {
+		t.Errorf("client_id = %q, aud = %v, want client-1", claims.ClientID, claims.Audience)
+	}
+
+	session.ClientID = ""
+	token, _, err = s.generateToken(session, "alice", []string{"admin"}, nil)
+	if err != nil {
+		t.Fatalf("generateToken() error = %v", err)
+	}
+	if _, err := s.ValidateToken(token); err != nil {
+		t.Errorf("first-party token rejected: %v", err)
+	}
+}
+
+func TestRevokeClientToken_OtherClient(t *testing.T) {
+	key := newHMACKey("secret")
+	s := &Service{
+		keyring:     NewKeyring(key, nil, nil, time.Hour),
+		revocations: NewRevocations(nil),
+		config:      &config.Config{Security: config.SecurityConfig{JWTExpiry: time.Hour}},
+	}
+
+	session := &model.Session{ID: "session-1", UserID: "user-1", ClientID: "client-1", CreatedAt: time.Now()}
+	token, _, err := s.generateToken(session, "alice", nil, nil)
+	if err != nil {
+		t.Fatalf("generateToken() error = %v", err)
+	}
+
+	for _, clientID := range []string{"client-2", ""} {
+		if err := s.RevokeClientToken(context.Background(), clientID, token); err != nil {
+			t.Fatalf("RevokeClientToken(%q) error = %v", clientID, err)
+		}
+		if _, err := s.ValidateClientToken(token); err != nil {
+			t.Errorf("token revoked by %q: %v", clientID, err)
+		}
+	}
+
+	if err := s.RevokeClientToken(context.Background(), "client-1", token); err != nil {
+		t.Fatalf("RevokeClientToken() error = %v", err)
+	}
+	if _, err := s.ValidateClientToken(token); err == nil {
+		t.Error("token still valid after its client revoked it")
+	}
+}
+
+func TestRevocations_FallBackWhileListenerDown(t *testing.T) {
+	store := &memoryRevocations{revoked: map[string]bool{"jti-1": true}}
+	r := &Revocations{cache: NewTokenBlacklist(), repo: store}
+
+	if !r.IsRevoked("jti-1") {
+		t.Error("revocation in the repository missed while the listener is down")
+	}
+	if r.IsRevoked("jti-2") {
+		t.Error("live token reported as revoked")
+	}
+
+	if err := logger.Initialize("test"); err != nil {
+		t.Fatalf("logger.Initialize() error = %v", err)
+	}
+	store.err = fmt.Errorf("connection refused")
+	if !r.IsRevoked("jti-2") {
+		t.Error("failed lookup accepted the token")
+	}
+
+	// Once listening, the cache is complete and the repository is left alone
+	r.listening.Store(true)
+	if r.IsRevoked("jti-2") {
+		t.Error("repository consulted while the listener is up")
+	}
+}
+
+func TestRevocations_Load(t *testing.T) {
+	store := &memoryRevocations{revoked: map[string]bool{"jti-1": true}}
+	r := &Revocations{cache: NewTokenBlacklist(), repo: store}
+
+	if err := r.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !r.cache.IsRevoked("jti-1") {
+		t.Error("persisted revocation not loaded")
+	}
+
+	store.err = fmt.Errorf("connection refused")
+	if err := r.Load(context.Background()); err == nil {
+		t.Error("Load() hid the repository error")
+	}
+}
+
+type memoryRevocations struct {
+	revoked map[string]bool
+	err     error
+}
+
+func (m *memoryRevocations) Create(ctx context.Context, jti string, expiresAt time.Time) error {
+	m.revoked[jti] = true
+	return m.err
+}
+
+func (m *memoryRevocations) GetActive(ctx context.Context) ([]*model.RevokedToken, error) {
+	var tokens []*model.RevokedToken
+	for jti := range m.revoked {
+		tokens = append(tokens, &model.RevokedToken{JTI: jti, ExpiresAt: time.Now().Add(time.Hour)})
+	}
+	return tokens, m.err
+}
+
+func (m *memoryRevocations) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return m.revoked[jti], m.err
+}
+
+func (m *memoryRevocations) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *memoryRevocations) Listen(ctx context.Context, ready func() error, notify func(*model.RevokedToken)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
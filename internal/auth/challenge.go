@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"math/big"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+)
+
+// challengeTTL bounds how long a client has to answer an SRP challenge
+const challengeTTL = 5 * time.Minute
+
+// parseClientA decodes the client's public ephemeral value A. An empty value
+// is allowed and yields nil, A is then expected alongside the client proof.
+func parseClientA(clientAHex string) (*big.Int, error) {
+	if clientAHex == "" {
+		return nil, nil
+	}
+
+	clientABytes, err := hex.DecodeString(clientAHex)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid client_a format")
+	}
+	clientA := new(big.Int).SetBytes(clientABytes)
+
+	if clientA.Sign() == 0 {
+		return nil, errors.NewBadRequestError("invalid client_a value")
+	}
+
+	return clientA, nil
+}
+
+// newChallenge generates the server ephemeral keys for user and returns the
+// pending challenge. The caller assigns SessionID and stores it.
+func (s *Service) newChallenge(user *model.User, clientA *big.Int) (*AuthChallenge, error) {
+	if len(user.Verifier) == 0 {
+		// Registered with OPAQUE
+		return nil, errors.NewAuthenticationError("invalid credentials")
+	}
+
+	srp, err := s.srpFor(user.SRPGroup, user.HashAlgorithm, user.SRPMode)
+	if err != nil {
+		return nil, errors.NewInternalError("unsupported SRP parameters")
+	}
+
+	verifier := new(big.Int).SetBytes(user.Verifier)
+	serverSecret, serverB, err := srp.GenerateServerKeys(verifier)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to generate server keys")
+	}
+
+	return &AuthChallenge{
+		UserID:        user.ID,
+		Username:      user.Username,
+		ClientA:       clientA,
+		ServerB:       serverB,
+		ServerSecret:  serverSecret,
+		Salt:          user.Salt,
+		Verifier:      user.Verifier,
+		SRPGroup:      user.SRPGroup,
+		HashAlgorithm: user.HashAlgorithm,
+		SRPMode:       string(srp.Mode),
+		CreatedAt:     time.Now(),
+	}, nil
+}
+
+func (s *Service) putChallenge(ctx context.Context, challenge *AuthChallenge) error {
+	if err := s.challenges.Put(ctx, challenge, challengeTTL); err != nil {
+		return errors.NewInternalError("failed to store challenge")
+	}
+	return nil
+}
+
+// takeChallenge removes the challenge so that each one can be answered once
+func (s *Service) takeChallenge(ctx context.Context, id string) (*AuthChallenge, error) {
+	challenge, err := s.challenges.Take(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewAuthenticationError("invalid or expired session")
+		}
+		return nil, errors.NewInternalError("failed to retrieve challenge")
+	}
+
+	return challenge, nil
+}
+
+// verifyChallengeProof checks the client proof M1 against the challenge and
+// returns the server proof M2 on success. clientAHex must be given when A was
+// not already sent with the challenge request.
+func (s *Service) verifyChallengeProof(challenge *AuthChallenge, clientAHex, clientProofHex string) ([]byte, error) {
+	clientA, err := parseClientA(clientAHex)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case challenge.ClientA == nil && clientA == nil:
+		return nil, errors.NewValidationError("client_a is required")
+	case challenge.ClientA == nil:
+		challenge.ClientA = clientA
+	case clientA != nil && clientA.Cmp(challenge.ClientA) != 0:
+		return nil, errors.NewBadRequestError("client_a does not match the challenge")
+	}
+
+	clientProof, err := hex.DecodeString(clientProofHex)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid client_proof format")
+	}
+
+	srp, err := s.srpFor(challenge.SRPGroup, challenge.HashAlgorithm, challenge.SRPMode)
+	if err != nil {
+		return nil, errors.NewInternalError("unsupported SRP parameters")
+	}
+
+	// Compute u = H(A | B)
+	u := srp.ComputeU(challenge.ClientA, challenge.ServerB)
+
+	verifier := new(big.Int).SetBytes(challenge.Verifier)
+	serverKey, err := srp.ComputeServerSessionKey(
+		challenge.ClientA,
+		challenge.ServerSecret,
+		verifier,
+		u,
+	)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to compute session key")
+	}
+
+	if !srp.VerifyClientProof(
+		challenge.Username,
+		challenge.Salt,
+		challenge.ClientA,
+		challenge.ServerB,
+		serverKey,
+		clientProof,
+	) {
+		return nil, errors.NewAuthenticationError("invalid credentials")
+	}
+
+	return srp.ComputeServerProof(challenge.ClientA, clientProof, serverKey), nil
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/config"
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+)
+
+// ChallengeEncryptionKey decodes the key that encrypts challenges kept in
+// Postgres. It defaults to a key derived from JWTSecret, which every instance
+// shares.
+func ChallengeEncryptionKey(cfg *config.SecurityConfig) ([]byte, error) {
+	if cfg.ChallengeEncryptionKey == "" {
+		mac := hmac.New(sha256.New, []byte(cfg.JWTSecret))
+		mac.Write([]byte("zk-auth challenge encryption"))
+		return mac.Sum(nil), nil
+	}
+
+	key, err := hex.DecodeString(cfg.ChallengeEncryptionKey)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("CHALLENGE_ENCRYPTION_KEY must be 32 hex encoded bytes")
+	}
+	return key, nil
+}
+
+// ChallengeStore keeps pending challenges between the two legs of a login.
+// Take returns sql.ErrNoRows for unknown and expired challenges, and must
+// hand out each challenge at most once even under concurrent calls.
+type ChallengeStore interface {
+	Put(ctx context.Context, challenge *AuthChallenge, ttl time.Duration) error
+	Take(ctx context.Context, id string) (*AuthChallenge, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// NewChallengeStore returns the store named by kind, "memory" or "postgres".
+// key encrypts the challenges kept in Postgres.
+func NewChallengeStore(kind string, repo *model.ChallengeRepository, key []byte) (ChallengeStore, error) {
+	switch kind {
+	case "memory":
+		return NewMemoryChallengeStore(), nil
+	case "postgres":
+		return NewPostgresChallengeStore(repo, key), nil
+	default:
+		return nil, fmt.Errorf("unknown challenge store %q", kind)
+	}
+}
+
+type storedChallenge struct {
+	challenge *AuthChallenge
+	expiresAt time.Time
+}
+
+// MemoryChallengeStore keeps challenges in process. It only works when a
+// single instance serves both legs of every login.
+type MemoryChallengeStore struct {
+	challenges map[string]storedChallenge
+	mu         sync.Mutex
+}
+
+func NewMemoryChallengeStore() *MemoryChallengeStore {
+	return &MemoryChallengeStore{
+		challenges: make(map[string]storedChallenge),
+	}
+}
+
+func (m *MemoryChallengeStore) Put(ctx context.Context, challenge *AuthChallenge, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.challenges[challenge.SessionID] = storedChallenge{
+		challenge: challenge,
+		expiresAt: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+func (m *MemoryChallengeStore) Take(ctx context.Context, id string) (*AuthChallenge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, exists := m.challenges[id]
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+	delete(m.challenges, id)
+
+	if time.Now().After(stored.expiresAt) {
+		return nil, sql.ErrNoRows
+	}
+	return stored.challenge, nil
+}
+
+func (m *MemoryChallengeStore) DeleteExpired(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	now := time.Now()
+	for id, stored := range m.challenges {
+		if now.After(stored.expiresAt) {
+			delete(m.challenges, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// PostgresChallengeStore keeps challenges in the auth_challenges table so
+// any instance can answer the second leg of a login. They hold the server's
+// handshake secrets, so they are stored encrypted with the challenge ID as
+// additional data.
+type PostgresChallengeStore struct {
+	repo *model.ChallengeRepository
+	key  []byte
+}
+
+func NewPostgresChallengeStore(repo *model.ChallengeRepository, key []byte) *PostgresChallengeStore {
+	return &PostgresChallengeStore{repo: repo, key: key}
+}
+
+func (p *PostgresChallengeStore) Put(ctx context.Context, challenge *AuthChallenge, ttl time.Duration) error {
+	data, err := p.seal(challenge)
+	if err != nil {
+		return err
+	}
+
+	return p.repo.Create(ctx, &model.Challenge{
+		ID:        challenge.SessionID,
+		Data:      data,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+}
+
+func (p *PostgresChallengeStore) Take(ctx context.Context, id string) (*AuthChallenge, error) {
+	stored, err := p.repo.Take(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.open(id, stored.Data)
+}
+
+func (p *PostgresChallengeStore) DeleteExpired(ctx context.Context) (int64, error) {
+	return p.repo.DeleteExpired(ctx)
+}
+
+func (p *PostgresChallengeStore) seal(challenge *AuthChallenge) ([]byte, error) {
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Seal(p.key, data, []byte(challenge.SessionID))
+}
+
+func (p *PostgresChallengeStore) open(id string, sealed []byte) (*AuthChallenge, error) {
+	data, err := crypto.Open(p.key, sealed, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt challenge: %w", err)
+	}
+
+	var challenge AuthChallenge
+	if err := json.Unmarshal(data, &challenge); err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/config"
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+)
+
+func TestMemoryChallengeStore_TakeOnce(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryChallengeStore()
+
+	if err := store.Put(ctx, &AuthChallenge{SessionID: "c1"}, time.Minute); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	challenge, err := store.Take(ctx, "c1")
+	if err != nil || challenge.SessionID != "c1" {
+		t.Fatalf("Take() = %v, %v", challenge, err)
+	}
+
+	if _, err := store.Take(ctx, "c1"); err != sql.ErrNoRows {
+		t.Errorf("second Take() error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestMemoryChallengeStore_Expiry(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryChallengeStore()
+
+	store.Put(ctx, &AuthChallenge{SessionID: "expired"}, -time.Second)
+	store.Put(ctx, &AuthChallenge{SessionID: "live"}, time.Minute)
+
+	if _, err := store.Take(ctx, "expired"); err != sql.ErrNoRows {
+		t.Errorf("Take() on expired challenge error = %v, want sql.ErrNoRows", err)
+	}
+
+	store.Put(ctx, &AuthChallenge{SessionID: "expired"}, -time.Second)
+	deleted, err := store.DeleteExpired(ctx)
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteExpired() = %d, %v, want 1", deleted, err)
+	}
+	if _, err := store.Take(ctx, "live"); err != nil {
+		t.Errorf("Take() on live challenge error = %v", err)
+	}
+}
+
+// The Postgres store keeps challenges as JSON, so every field must survive
+// a round trip
+func TestAuthChallenge_JSONRoundTrip(t *testing.T) {
+	challenge := &AuthChallenge{
+		SessionID:      "c1",
+		UserID:         "u1",
+		Username:       "alice",
+		ServerB:        new(big.Int).Lsh(big.NewInt(1), 4000),
+		ServerSecret:   big.NewInt(12345),
+		Salt:           []byte{1, 2, 3},
+		Verifier:       []byte{4, 5, 6},
+		SRPGroup:       2048,
+		HashAlgorithm:  "SHA256",
+		SRPMode:        "rfc5054",
+		CreatedAt:      time.Now().Truncate(time.Second),
+		BoundSessionID: "s1",
+		OPAQUE: &crypto.OPAQUEServerLogin{
+			ExpectedClientMAC: []byte{7},
+			SessionKey:        []byte{8},
+		},
+	}
+
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var decoded AuthChallenge
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if decoded.ClientA != nil {
+		t.Error("nil ClientA should stay nil")
+	}
+	if decoded.ServerB.Cmp(challenge.ServerB) != 0 || decoded.ServerSecret.Cmp(challenge.ServerSecret) != 0 {
+		t.Error("SRP values changed in round trip")
+	}
+	if decoded.SRPMode != challenge.SRPMode || decoded.BoundSessionID != challenge.BoundSessionID ||
+		!decoded.CreatedAt.Equal(challenge.CreatedAt) {
+		t.Error("challenge fields changed in round trip")
+	}
+	if string(decoded.OPAQUE.SessionKey) != string(challenge.OPAQUE.SessionKey) {
+		t.Error("OPAQUE state changed in round trip")
+	}
+}
+
+func TestPostgresChallengeStore_Encrypted(t *testing.T) {
+	key, err := ChallengeEncryptionKey(&config.SecurityConfig{JWTSecret: "secret"})
+	if err != nil {
+		t.Fatalf("ChallengeEncryptionKey() error = %v", err)
+	}
+	store := NewPostgresChallengeStore(nil, key)
+	challenge := &AuthChallenge{SessionID: "c1", Username: "alice", ServerSecret: big.NewInt(12345)}
+
+	sealed, err := store.seal(challenge)
+	if err != nil {
+		t.Fatalf("seal() error = %v", err)
+	}
+	if bytes.Contains(sealed, []byte("alice")) || bytes.Contains(sealed, []byte("12345")) {
+		t.Error("challenge stored in plaintext")
+	}
+
+	opened, err := store.open("c1", sealed)
+	if err != nil {
+		t.Fatalf("open() error = %v", err)
+	}
+	if opened.ServerSecret.Cmp(challenge.ServerSecret) != 0 {
+		t.Error("server secret changed in round trip")
+	}
+
+	// Bound to its ID, so a row can't be answered as another challenge
+	if _, err := store.open("c2", sealed); err == nil {
+		t.Error("challenge opened under another ID")
+	}
+}
+
+func TestChallengeEncryptionKey(t *testing.T) {
+	if _, err := ChallengeEncryptionKey(&config.SecurityConfig{ChallengeEncryptionKey: "abcd"}); err == nil {
+		t.Error("short key accepted")
+	}
+	key, err := ChallengeEncryptionKey(&config.SecurityConfig{ChallengeEncryptionKey: strings.Repeat("ab", 32)})
+	if err != nil || len(key) != 32 {
+		t.Errorf("ChallengeEncryptionKey() = %x, %v", key, err)
+	}
+}
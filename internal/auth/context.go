@@ -6,4 +6,12 @@ type ContextKey string
 const (
 	// ClaimsContextKey is the key used to store token claims in the request context
 	ClaimsContextKey ContextKey = "claims"
+	// ClientContextKey is the key used to store the caller's ClientInfo in the request context
+	ClientContextKey ContextKey = "client"
 )
+
+// ClientInfo describes where a request came from
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
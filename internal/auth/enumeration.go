@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/config"
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"go.uber.org/zap"
+)
+
+// fakeParamsInterval is how often the parameters of real accounts are
+// recounted for fake credentials
+const fakeParamsInterval = time.Hour
+
+// parameterMix is the mix of parameters real accounts use. Fake credentials
+// draw theirs from it, so an account still on legacy parameters doesn't
+// stand out from unknown usernames. The current parameters come first, so
+// as accounts are upgraded a fake one moves to them like a real one would.
+type parameterMix struct {
+	srp       []*model.ParameterCount
+	srpTotal  int64
+	ksf       []*model.KSFCount
+	ksfTotal  int64
+	countedAt time.Time
+}
+
+// fakeUser returns stand-in credentials for a username that has no SRP
+// verifier. They are derived from a server secret, so repeated challenges
+// for the same name look like those of a real account, and no password
+// matches them. The returned user has no ID.
+func (s *Service) fakeUser(username string) *model.User {
+	params := s.fakeSRPParams(username)
+	srp, err := s.srpFor(params.SRPGroup, params.HashAlgorithm, params.SRPMode)
+	if err != nil {
+		srp = s.srp
+	}
+
+	x := new(big.Int).SetBytes(s.deriveFake("verifier", username))
+	verifier := new(big.Int).Exp(srp.G, x, srp.N)
+
+	return &model.User{
+		Username:      username,
+		Salt:          s.deriveFakeBytes("salt", username, params.SaltLength),
+		Verifier:      verifier.Bytes(),
+		SRPGroup:      srp.Group.Bits,
+		HashAlgorithm: string(srp.Hash),
+		SRPMode:       string(srp.Mode),
+		KDF:           params.KDF,
+	}
+}
+
+// fakeSRPParams draws the SRP parameters of a fake account from those of
+// real accounts, or returns the current ones before any are counted
+func (s *Service) fakeSRPParams(username string) *model.ParameterCount {
+	if mix := s.fakeParams.Load(); mix != nil && mix.srpTotal > 0 {
+		limit := int64(s.fakeFraction("srp-params", username) * float64(mix.srpTotal))
+		for _, params := range mix.srp {
+			if limit < params.Count {
+				return params
+			}
+			limit -= params.Count
+		}
+	}
+	return s.currentSRPParams()
+}
+
+// fakeKSF draws the key stretching parameters of a fake OPAQUE record like
+// fakeSRPParams
+func (s *Service) fakeKSF(username string) crypto.KDFParams {
+	if mix := s.fakeParams.Load(); mix != nil && mix.ksfTotal > 0 {
+		limit := int64(s.fakeFraction("ksf", username) * float64(mix.ksfTotal))
+		for _, params := range mix.ksf {
+			if limit < params.Count {
+				return params.KSF
+			}
+			limit -= params.Count
+		}
+	}
+	return s.kdf
+}
+
+func (s *Service) currentSRPParams() *model.ParameterCount {
+	return &model.ParameterCount{
+		SRPGroup:      s.srp.Group.Bits,
+		HashAlgorithm: string(s.srp.Hash),
+		SRPMode:       string(s.srp.Mode),
+		KDF:           s.kdf,
+		SaltLength:    crypto.SaltLength,
+	}
+}
+
+// refreshFakeParams recounts the parameters of real accounts once
+// fakeParamsInterval has passed. Failures keep the previous counts.
+func (s *Service) refreshFakeParams(ctx context.Context) {
+	if mix := s.fakeParams.Load(); mix != nil && time.Since(mix.countedAt) < fakeParamsInterval {
+		return
+	}
+
+	srpCounts, err := s.userRepo.CountByParameters(ctx)
+	if err != nil {
+		logger.Warn("Failed to count account parameters", zap.Error(err))
+		return
+	}
+	ksfCounts, err := s.opaqueRepo.CountByKSF(ctx)
+	if err != nil {
+		logger.Warn("Failed to count OPAQUE parameters", zap.Error(err))
+		return
+	}
+
+	s.fakeParams.Store(s.newFakeParams(srpCounts, ksfCounts))
+}
+
+func (s *Service) newFakeParams(srpCounts []*model.ParameterCount, ksfCounts []*model.KSFCount) *parameterMix {
+	mix := &parameterMix{countedAt: time.Now()}
+	current := s.currentSRPParams()
+
+	for _, params := range srpCounts {
+		// Parameters this server can no longer answer with are left out
+		if _, err := s.srpFor(params.SRPGroup, params.HashAlgorithm, params.SRPMode); err != nil {
+			continue
+		}
+		if params.SaltLength < crypto.MinSaltLength || params.SaltLength > crypto.MaxSaltLength {
+			continue
+		}
+		mix.srp = append(mix.srp, params)
+		mix.srpTotal += params.Count
+	}
+	sort.SliceStable(mix.srp, func(i, j int) bool {
+		a, b := mix.srp[i], mix.srp[j]
+		if isCurrent := sameSRPParams(a, current); isCurrent != sameSRPParams(b, current) {
+			return isCurrent
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return fmt.Sprint(*a) < fmt.Sprint(*b)
+	})
+
+	for _, params := range ksfCounts {
+		mix.ksf = append(mix.ksf, params)
+		mix.ksfTotal += params.Count
+	}
+	sort.SliceStable(mix.ksf, func(i, j int) bool {
+		a, b := mix.ksf[i], mix.ksf[j]
+		if isCurrent := a.KSF == s.kdf; isCurrent != (b.KSF == s.kdf) {
+			return isCurrent
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return fmt.Sprint(a.KSF) < fmt.Sprint(b.KSF)
+	})
+
+	return mix
+}
+
+func sameSRPParams(a, b *model.ParameterCount) bool {
+	return a.SRPGroup == b.SRPGroup && a.HashAlgorithm == b.HashAlgorithm &&
+		a.SRPMode == b.SRPMode && a.KDF == b.KDF && a.SaltLength == b.SaltLength
+}
+
+// fakeFraction maps username to a stable value in [0, 1)
+func (s *Service) fakeFraction(label, username string) float64 {
+	return float64(binary.BigEndian.Uint64(s.deriveFake(label, username))>>11) / (1 << 53)
+}
+
+// fakeUserID returns a stable user ID formatted like the database's UUIDs
+func (s *Service) fakeUserID(username string) string {
+	b := s.deriveFake("user-id", username)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// deriveFakeBytes returns n bytes derived from username. The first 32 are
+// those of deriveFake.
+func (s *Service) deriveFakeBytes(label, username string, n int) []byte {
+	b := s.deriveFake(label, username)
+	for i := 1; len(b) < n; i++ {
+		b = append(b, s.deriveFake(fmt.Sprintf("%s-%d", label, i), username)...)
+	}
+	return b[:n]
+}
+
+func (s *Service) deriveFake(label, username string) []byte {
+	mac := hmac.New(sha256.New, s.fakeSecret)
+	mac.Write([]byte(label))
+	mac.Write([]byte{0})
+	mac.Write([]byte(username))
+	return mac.Sum(nil)
+}
+
+// padResponseTime sleeps until the configured floor has passed since start,
+// so responses for existing and unknown users take about as long
+func (s *Service) padResponseTime(start time.Time) {
+	if remaining := s.config.Security.AuthResponseFloor - time.Since(start); remaining > 0 {
+		time.Sleep(remaining)
+	}
+}
+
+// fakeSecretFromConfig returns the key for fake credentials
+func fakeSecretFromConfig(cfg *config.SecurityConfig) []byte {
+	if cfg.FakeCredentialSecret != "" {
+		return []byte(cfg.FakeCredentialSecret)
+	}
+
+	mac := hmac.New(sha256.New, []byte(cfg.JWTSecret))
+	mac.Write([]byte("zk-auth fake credentials"))
+	return mac.Sum(nil)
+}
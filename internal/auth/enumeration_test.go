@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+)
+
+func newEnumerationService(t *testing.T) *Service {
+	t.Helper()
+	srps := make(map[srpParams]*crypto.SRP)
+	for _, g := range crypto.Groups() {
+		for _, h := range crypto.HashAlgorithms() {
+			for _, m := range crypto.Modes() {
+				srps[srpParams{group: g.Bits, hash: h, mode: m}] = crypto.NewSRP(g, h, m)
+			}
+		}
+	}
+	return &Service{
+		srp:        srps[srpParams{group: 2048, hash: crypto.SHA256, mode: crypto.ModeRFC5054}],
+		srps:       srps,
+		kdf:        crypto.KDFParams{Algorithm: crypto.KDFArgon2id, Time: 3, Memory: 64 * 1024, Threads: 4},
+		fakeSecret: []byte("secret"),
+	}
+}
+
+// An unknown username must not be told apart from an account still on
+// legacy parameters by the shape of its challenge
+func TestFakeUser_MatchesLegacyAccounts(t *testing.T) {
+	s := newEnumerationService(t)
+	legacyParams := &model.ParameterCount{
+		SRPGroup:      2048,
+		HashAlgorithm: string(crypto.SHA256),
+		SRPMode:       string(crypto.ModeLegacy),
+		KDF:           crypto.KDFParams{Algorithm: crypto.KDFNone},
+		SaltLength:    crypto.MinSaltLength,
+		Count:         3,
+	}
+	current := s.currentSRPParams()
+	current.Count = 1
+	s.fakeParams.Store(s.newFakeParams([]*model.ParameterCount{legacyParams, current}, nil))
+
+	legacySRP, err := s.srpFor(2048, legacyParams.HashAlgorithm, legacyParams.SRPMode)
+	if err != nil {
+		t.Fatalf("srpFor() error = %v", err)
+	}
+	salt := make([]byte, crypto.MinSaltLength)
+	verifier, err := legacySRP.ComputeVerifier("alice", "password", salt, legacyParams.KDF)
+	if err != nil {
+		t.Fatalf("ComputeVerifier() error = %v", err)
+	}
+	legacy := &model.User{
+		ID:            "u1",
+		Username:      "alice",
+		Salt:          salt,
+		Verifier:      verifier.Bytes(),
+		SRPGroup:      legacyParams.SRPGroup,
+		HashAlgorithm: legacyParams.HashAlgorithm,
+		SRPMode:       legacyParams.SRPMode,
+		KDF:           legacyParams.KDF,
+	}
+
+	var unknown string
+	for i := 0; i < 100 && unknown == ""; i++ {
+		name := fmt.Sprintf("user%d", i)
+		if s.fakeUser(name).SRPMode == legacyParams.SRPMode {
+			unknown = name
+		}
+	}
+	if unknown == "" {
+		t.Fatal("no unknown username drew the legacy parameters")
+	}
+	if !reflect.DeepEqual(s.fakeUser(unknown), s.fakeUser(unknown)) {
+		t.Error("fake credentials change between challenges")
+	}
+
+	want := challengeShape(t, s, legacy)
+	if got := challengeShape(t, s, s.fakeUser(unknown)); !reflect.DeepEqual(got, want) {
+		t.Errorf("unknown username challenge = %v, legacy account = %v", got, want)
+	}
+}
+
+func TestFakeUser_CurrentParamsBeforeCount(t *testing.T) {
+	s := newEnumerationService(t)
+
+	user := s.fakeUser("bob")
+	if user.SRPMode != string(crypto.ModeRFC5054) || user.KDF != s.kdf || len(user.Salt) != crypto.SaltLength {
+		t.Errorf("fakeUser() = %s %v with a %d byte salt, want the current parameters",
+			user.SRPMode, user.KDF, len(user.Salt))
+	}
+	if ksf := s.fakeKSF("bob"); ksf != s.kdf {
+		t.Errorf("fakeKSF() = %v, want %v", ksf, s.kdf)
+	}
+}
+
+// challengeShape returns what a challenge response reveals besides random
+// values: its fields, salt length and parameters
+func challengeShape(t *testing.T, s *Service, user *model.User) map[string]interface{} {
+	t.Helper()
+	challenge, err := s.newChallenge(user, nil)
+	if err != nil {
+		t.Fatalf("newChallenge() error = %v", err)
+	}
+	data, err := json.Marshal(s.challengeResponse(challenge, user))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	// B is random and loses leading zero bytes, so only its presence counts
+	fields["salt"] = len(fields["salt"].(string))
+	fields["server_b"] = fields["server_b"] != ""
+	return fields
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/gorilla/mux"
 )
 
 type Handler struct {
@@ -42,6 +43,11 @@ func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+func (h *Handler) HandleRegistrationParams(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.service.RegistrationParams())
+}
+
 func (h *Handler) HandleChallenge(w http.ResponseWriter, r *http.Request) {
 	var req ChallengeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -49,8 +55,8 @@ func (h *Handler) HandleChallenge(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Username == "" || req.ClientA == "" {
-		errors.NewValidationError("username and client_a are required").WriteResponse(w)
+	if req.Username == "" {
+		errors.NewValidationError("username is required").WriteResponse(w)
 		return
 	}
 
@@ -101,14 +107,56 @@ func (h *Handler) HandleProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	profile := map[string]interface{}{
-		"username":   claims.Username,
-		"session_id": claims.SessionID,
-		"expires_at": claims.ExpiresAt,
+	resp, err := h.service.GetProfile(r.Context(), claims)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("failed to get user info").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(*TokenClaims)
+	if !ok {
+		errors.NewInternalError("failed to get user info").WriteResponse(w)
+		return
+	}
+
+	// Generous for the field limits, but bounds what is decoded
+	r.Body = http.MaxBytesReader(w, r.Body, 4*maxProfileMetadataSize)
+
+	var req UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.NewBadRequestError("invalid request body").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.UpdateProfile(r.Context(), claims, &req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("profile update failed").WriteResponse(w)
+		}
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(profile)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleJWKS serves the public signing keys so resource servers can verify
+// tokens without the signing secret
+func (h *Handler) HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.service.JWKS())
 }
 
 func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
@@ -133,13 +181,18 @@ func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) HandleRefresh(w http.ResponseWriter, r *http.Request) {
-	token := h.extractToken(r)
-	if token == "" {
-		errors.NewAuthenticationError("missing authorization token").WriteResponse(w)
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.NewBadRequestError("invalid request body").WriteResponse(w)
 		return
 	}
 
-	resp, err := h.service.RefreshToken(r.Context(), token)
+	if req.RefreshToken == "" {
+		errors.NewValidationError("refresh_token is required").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.RefreshToken(r.Context(), req.RefreshToken)
 	if err != nil {
 		if appErr, ok := err.(*errors.AppError); ok {
 			appErr.WriteResponse(w)
@@ -153,6 +206,33 @@ func (h *Handler) HandleRefresh(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+func (h *Handler) HandleReauthChallenge(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(*TokenClaims)
+	if !ok {
+		errors.NewInternalError("failed to get user info").WriteResponse(w)
+		return
+	}
+
+	var req ReauthChallengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.NewBadRequestError("invalid request body").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.StartReauthChallenge(r.Context(), claims, &req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("challenge failed").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
 func (h *Handler) HandleChangePassword(w http.ResponseWriter, r *http.Request) {
 	claims, ok := r.Context().Value(ClaimsContextKey).(*TokenClaims)
 	if !ok {
@@ -166,7 +246,16 @@ func (h *Handler) HandleChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.CurrentPassword == "" || req.NewPassword == "" {
+	if req.ChallengeID != "" {
+		if err := validateReauthProof(&req.ReauthProof); err != nil {
+			err.WriteResponse(w)
+			return
+		}
+		if req.Record == "" && (req.Salt == "" || req.Verifier == "") {
+			errors.NewValidationError("salt and verifier, or record, are required").WriteResponse(w)
+			return
+		}
+	} else if req.CurrentPassword == "" || req.NewPassword == "" {
 		errors.NewValidationError("current_password and new_password are required").WriteResponse(w)
 		return
 	}
@@ -184,3 +273,465 @@ func (h *Handler) HandleChangePassword(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+func (h *Handler) HandleOPAQUERegisterStart(w http.ResponseWriter, r *http.Request) {
+	var req OPAQUERegisterStartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.NewBadRequestError("invalid request body").WriteResponse(w)
+		return
+	}
+
+	if err := validateUsername(req.Username); err != nil {
+		err.WriteResponse(w)
+		return
+	}
+	if req.RegistrationRequest == "" {
+		errors.NewValidationError("registration_request is required").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.OPAQUERegisterStart(r.Context(), &req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("registration failed").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleOPAQUERegisterFinish(w http.ResponseWriter, r *http.Request) {
+	var req OPAQUERegisterFinishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.NewBadRequestError("invalid request body").WriteResponse(w)
+		return
+	}
+
+	if err := validateUsername(req.Username); err != nil {
+		err.WriteResponse(w)
+		return
+	}
+	if req.Record == "" {
+		errors.NewValidationError("record is required").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.OPAQUERegisterFinish(r.Context(), &req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("registration failed").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleOPAQUELoginStart(w http.ResponseWriter, r *http.Request) {
+	var req OPAQUELoginStartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.NewBadRequestError("invalid request body").WriteResponse(w)
+		return
+	}
+
+	if req.Username == "" || req.KE1 == "" {
+		errors.NewValidationError("username and ke1 are required").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.OPAQUELoginStart(r.Context(), &req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("login failed").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleOPAQUEReauth(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(*TokenClaims)
+	if !ok {
+		errors.NewInternalError("failed to get user info").WriteResponse(w)
+		return
+	}
+
+	var req OPAQUEReauthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.NewBadRequestError("invalid request body").WriteResponse(w)
+		return
+	}
+
+	if req.KE1 == "" {
+		errors.NewValidationError("ke1 is required").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.OPAQUEReauthStart(r.Context(), claims, &req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("challenge failed").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleOPAQUELoginFinish(w http.ResponseWriter, r *http.Request) {
+	var req OPAQUELoginFinishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.NewBadRequestError("invalid request body").WriteResponse(w)
+		return
+	}
+
+	if req.SessionID == "" || req.KE3 == "" {
+		errors.NewValidationError("session_id and ke3 are required").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.OPAQUELoginFinish(r.Context(), &req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("login failed").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleTOTPEnroll(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(*TokenClaims)
+	if !ok {
+		errors.NewInternalError("failed to get user info").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.EnrollTOTP(r.Context(), claims)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("TOTP enrollment failed").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleTOTPConfirm(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(*TokenClaims)
+	if !ok {
+		errors.NewInternalError("failed to get user info").WriteResponse(w)
+		return
+	}
+
+	var req TOTPConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.NewBadRequestError("invalid request body").WriteResponse(w)
+		return
+	}
+
+	if req.Code == "" {
+		errors.NewValidationError("code is required").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.ConfirmTOTP(r.Context(), claims, &req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("TOTP confirmation failed").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleMFATOTP completes a login that /auth/verify left pending
+func (h *Handler) HandleMFATOTP(w http.ResponseWriter, r *http.Request) {
+	var req MFATOTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.NewBadRequestError("invalid request body").WriteResponse(w)
+		return
+	}
+
+	if req.MFATicket == "" || req.Code == "" {
+		errors.NewValidationError("mfa_ticket and code are required").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.VerifyTOTP(r.Context(), &req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("verification failed").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(*TokenClaims)
+	if !ok {
+		errors.NewInternalError("failed to get user info").WriteResponse(w)
+		return
+	}
+
+	var req RecoveryCodesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.NewBadRequestError("invalid request body").WriteResponse(w)
+		return
+	}
+
+	if err := validateReauthProof(&req.ReauthProof); err != nil {
+		err.WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.GenerateRecoveryCodes(r.Context(), claims, &req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("recovery code generation failed").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(*TokenClaims)
+	if !ok {
+		errors.NewInternalError("failed to get user info").WriteResponse(w)
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.NewBadRequestError("invalid request body").WriteResponse(w)
+		return
+	}
+
+	if err := validateReauthProof(&req.ReauthProof); err != nil {
+		err.WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.DeleteAccount(r.Context(), claims, &req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("account deletion failed").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleCancelAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(*TokenClaims)
+	if !ok {
+		errors.NewInternalError("failed to get user info").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.CancelAccountDeletion(r.Context(), claims)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("failed to cancel account deletion").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleRecoverAccount(w http.ResponseWriter, r *http.Request) {
+	var req RecoverAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.NewBadRequestError("invalid request body").WriteResponse(w)
+		return
+	}
+
+	if req.Username == "" || req.RecoveryCode == "" || req.Salt == "" || req.Verifier == "" {
+		errors.NewValidationError("username, recovery_code, salt and verifier are required").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.RecoverAccount(r.Context(), &req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("account recovery failed").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(*TokenClaims)
+	if !ok {
+		errors.NewInternalError("failed to get user info").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.ListSessions(r.Context(), claims)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("failed to list sessions").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(*TokenClaims)
+	if !ok {
+		errors.NewInternalError("failed to get user info").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.RevokeSession(r.Context(), claims, mux.Vars(r)["id"])
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("failed to revoke session").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleRevokeOtherSessions signs out every session but the caller's
+func (h *Handler) HandleRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(*TokenClaims)
+	if !ok {
+		errors.NewInternalError("failed to get user info").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.RevokeOtherSessions(r.Context(), claims)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("failed to revoke sessions").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleUserRoles lists the roles of the user in the path
+func (h *Handler) HandleUserRoles(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.service.UserRoles(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("failed to get roles").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleGrantRole(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(*TokenClaims)
+	if !ok {
+		errors.NewInternalError("failed to get user info").WriteResponse(w)
+		return
+	}
+
+	vars := mux.Vars(r)
+	resp, err := h.service.GrantRole(r.Context(), claims, vars["id"], vars["role"])
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("failed to grant role").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleRevokeRole(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(*TokenClaims)
+	if !ok {
+		errors.NewInternalError("failed to get user info").WriteResponse(w)
+		return
+	}
+
+	vars := mux.Vars(r)
+	resp, err := h.service.RevokeRole(r.Context(), claims, vars["id"], vars["role"])
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("failed to revoke role").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
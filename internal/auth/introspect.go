@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Token types reported by IntrospectToken
+const (
+	TokenTypeAccess  = "access_token"
+	TokenTypeRefresh = "refresh_token"
+)
+
+// TokenInfo describes a live access or refresh token
+type TokenInfo struct {
+	TokenType string
+	UserID    string
+	ClientID  string // empty for first-party tokens
+	Username  string
+	SessionID string
+	JTI       string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// IntrospectToken returns what is known about token, or nil if it is not a
+// live token of this server. An access token is live if it verifies, has
+// not been revoked and is still the current token of an open session.
+func (s *Service) IntrospectToken(ctx context.Context, token string) (*TokenInfo, error) {
+	if claims, err := s.ValidateClientToken(token); err == nil {
+		session, err := s.sessionRepo.GetByToken(ctx, token)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, nil
+			}
+			return nil, errors.NewInternalError("failed to retrieve session")
+		}
+
+		return &TokenInfo{
+			TokenType: TokenTypeAccess,
+			UserID:    session.UserID,
+			ClientID:  session.ClientID,
+			Username:  claims.Username,
+			SessionID: session.ID,
+			JTI:       claims.ID,
+			IssuedAt:  claims.IssuedAt.Time,
+			ExpiresAt: claims.ExpiresAt.Time,
+		}, nil
+	}
+
+	stored, err := s.refreshRepo.GetByHash(ctx, hashRefreshToken(token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.NewInternalError("failed to retrieve refresh token")
+	}
+	if stored.RotatedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, nil
+	}
+
+	session, err := s.sessionRepo.GetByID(ctx, stored.SessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.NewInternalError("failed to retrieve session")
+	}
+	if s.checkSessionLifetime(session, stored.CreatedAt, time.Now()) != nil {
+		return nil, nil
+	}
+	user, err := s.userRepo.GetByID(ctx, session.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.NewInternalError("failed to retrieve user")
+	}
+
+	return &TokenInfo{
+		TokenType: TokenTypeRefresh,
+		UserID:    user.ID,
+		ClientID:  session.ClientID,
+		Username:  user.Username,
+		SessionID: session.ID,
+		IssuedAt:  stored.CreatedAt,
+		ExpiresAt: stored.ExpiresAt,
+	}, nil
+}
+
+// RevokeClientToken revokes an access or refresh token issued to the OpenID
+// Connect client clientID. Revoking an access token leaves its session open;
+// revoking a refresh token ends the session and revokes its access token.
+// Unknown and expired tokens, and tokens of other clients, are ignored.
+func (s *Service) RevokeClientToken(ctx context.Context, clientID, token string) error {
+	if claims, err := s.verifyToken(token); err == nil {
+		if claims.ID == "" || claims.ClientID != clientID {
+			return nil
+		}
+		return s.revokeToken(ctx, claims)
+	}
+
+	stored, err := s.refreshRepo.GetByHash(ctx, hashRefreshToken(token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return errors.NewInternalError("failed to retrieve refresh token")
+	}
+
+	session, err := s.sessionRepo.GetByID(ctx, stored.SessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return errors.NewInternalError("failed to retrieve session")
+	}
+	if session.ClientID != clientID {
+		return nil
+	}
+
+	s.revokeSessionToken(ctx, session)
+	if err := s.sessionRepo.Delete(ctx, session.ID); err != nil && err != sql.ErrNoRows {
+		return errors.NewInternalError("failed to delete session")
+	}
+
+	logger.Info("Session revoked through refresh token",
+		zap.String("session_id", session.ID))
+	return nil
+}
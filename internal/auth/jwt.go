@@ -1,28 +1,57 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/francisco3ferraz/zk-auth/internal/model"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func (s *Service) generateToken(sessionID, username string) (string, time.Time, error) {
-	expiresAt := time.Now().Add(s.config.Security.JWTExpiry)
+// generateToken signs an access token for session carrying the user's roles
+// and permissions, or the audience of the session's OpenID Connect client.
+// It expires after JWTExpiry, or sooner if the session would go idle or
+// reach its deadline first.
+func (s *Service) generateToken(session *model.Session, username string, roles, permissions []string) (string, time.Time, error) {
+	now := time.Now()
+	lifetime := s.config.Security.JWTExpiry
+	if idle := s.config.Security.SessionIdleTimeout; idle > 0 && idle < lifetime {
+		lifetime = idle
+	}
+	expiresAt := s.capExpiry(session, now.Add(lifetime))
+
+	jti, err := newRandomID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
 
 	claims := TokenClaims{
-		SessionID: sessionID,
-		Username:  username,
+		UserID:      session.UserID,
+		SessionID:   session.ID,
+		Username:    username,
+		AuthTime:    jwt.NewNumericDate(session.CreatedAt),
+		Roles:       roles,
+		Permissions: permissions,
+		ClientID:    session.ClientID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
+	if session.ClientID != "" {
+		claims.Audience = jwt.ClaimStrings{session.ClientID}
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(s.config.Security.JWTSecret))
+	key := s.keyring.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	signedToken, err := token.SignedString(key.private)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -32,10 +61,15 @@ func (s *Service) generateToken(sessionID, username string) (string, time.Time,
 
 func (s *Service) verifyToken(tokenStr string) (*TokenClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keyring.Lookup(context.Background(), kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id: %q", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(s.config.Security.JWTSecret), nil
+		return key.public, nil
 	})
 	if err != nil {
 		return nil, err
@@ -49,6 +83,30 @@ func (s *Service) verifyToken(tokenStr string) (*TokenClaims, error) {
 	return claims, nil
 }
 
+// SignToken signs claims issued by this server for other parties, such as
+// OpenID Connect ID tokens, with the active key. HS256 keys are refused
+// because the recipient could not verify them.
+func (s *Service) SignToken(claims jwt.Claims) (string, error) {
+	key := s.keyring.Active()
+	if !key.Public() {
+		return "", fmt.Errorf("signing key %s is not asymmetric", key.Method.Alg())
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
+}
+
+// SigningAlgorithm returns the algorithm of the active signing key
+func (s *Service) SigningAlgorithm() string {
+	return s.keyring.Active().Method.Alg()
+}
+
+// JWKS returns the public keys resource servers verify tokens with
+func (s *Service) JWKS() *JWKSet {
+	return s.keyring.JWKS()
+}
+
 func (h *Handler) extractToken(r *http.Request) string {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	// keyringReloadInterval is how often instances pick up rotated keys
+	keyringReloadInterval = time.Minute
+	// keyringMissReload limits reloads triggered by tokens with unknown kids
+	keyringMissReload = 10 * time.Second
+)
+
+type keyringEntry struct {
+	key         *SigningKey
+	acceptUntil time.Time // zero while the key is active
+}
+
+// Keyring holds the active signing key and the retired keys that still
+// verify tokens, selected by kid. Keys are stored encrypted in the
+// signing_keys table and rotated with the admin command. Until the first
+// rotation the key from the configuration is active; afterwards it is
+// accepted for one more token lifetime.
+type Keyring struct {
+	mu         sync.RWMutex
+	active     *SigningKey
+	keys       map[string]keyringEntry
+	lastReload time.Time
+
+	configured    *SigningKey
+	repo          *model.SigningKeyRepository // nil uses the configured key only
+	encryptionKey []byte                      // Decrypts the stored keys, may be nil
+	tokenTTL      time.Duration
+}
+
+// NewKeyring creates a keyring starting with the configured key.
+// encryptionKey decrypts the stored keys and is only needed once a key has
+// been rotated in.
+func NewKeyring(configured *SigningKey, repo *model.SigningKeyRepository, encryptionKey []byte, tokenTTL time.Duration) *Keyring {
+	return &Keyring{
+		active:        configured,
+		keys:          map[string]keyringEntry{configured.ID: {key: configured}},
+		configured:    configured,
+		repo:          repo,
+		encryptionKey: encryptionKey,
+		tokenTTL:      tokenTTL,
+	}
+}
+
+// RetirementGrace is how long a key must keep verifying tokens after it
+// stops being active: one token lifetime plus the time other instances may
+// take to notice the rotation.
+func RetirementGrace(tokenTTL time.Duration) time.Duration {
+	return tokenTTL + keyringReloadInterval
+}
+
+// Load replaces the keys with those stored in the database
+func (k *Keyring) Load(ctx context.Context) error {
+	if k.repo == nil {
+		return nil
+	}
+
+	stored, err := k.repo.GetUsable(ctx)
+	if err != nil {
+		return err
+	}
+	first, err := k.repo.FirstCreatedAt(ctx)
+	if err != nil {
+		return err
+	}
+
+	active := k.configured
+	keys := make(map[string]keyringEntry)
+	if first == nil {
+		keys[k.configured.ID] = keyringEntry{key: k.configured}
+	} else if acceptUntil := first.Add(RetirementGrace(k.tokenTTL)); time.Now().Before(acceptUntil) {
+		keys[k.configured.ID] = keyringEntry{key: k.configured, acceptUntil: acceptUntil}
+	}
+
+	for _, row := range stored {
+		key, err := openSigningKey(k.encryptionKey, row.KID, row.PrivateKeyEncrypted)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", row.KID, err)
+		}
+		if key.ID != row.KID {
+			return fmt.Errorf("signing key %s: kid does not match the key", row.KID)
+		}
+
+		entry := keyringEntry{key: key}
+		if row.Status == model.SigningKeyActive {
+			active = key
+		} else if row.AcceptUntil != nil {
+			entry.acceptUntil = *row.AcceptUntil
+		}
+		keys[key.ID] = entry
+	}
+
+	k.mu.Lock()
+	k.active = active
+	k.keys = keys
+	k.lastReload = time.Now()
+	k.mu.Unlock()
+
+	return nil
+}
+
+// Active returns the key new tokens are signed with
+func (k *Keyring) Active() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Lookup returns the key with the given kid if it still verifies tokens. An
+// unknown kid may belong to a key rotated in on another instance, so it
+// triggers a reload.
+func (k *Keyring) Lookup(ctx context.Context, kid string) (*SigningKey, bool) {
+	key, ok, known := k.lookup(kid)
+	if known || k.repo == nil {
+		return key, ok
+	}
+
+	k.mu.RLock()
+	recent := time.Since(k.lastReload) < keyringMissReload
+	k.mu.RUnlock()
+	if recent {
+		return nil, false
+	}
+
+	if err := k.Load(ctx); err != nil {
+		logger.Warn("Failed to reload signing keys", zap.Error(err))
+		return nil, false
+	}
+	key, ok, _ = k.lookup(kid)
+	return key, ok
+}
+
+func (k *Keyring) lookup(kid string) (key *SigningKey, ok, known bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	entry, known := k.keys[kid]
+	if !known {
+		return nil, false, false
+	}
+	if !entry.acceptUntil.IsZero() && time.Now().After(entry.acceptUntil) {
+		return nil, false, true
+	}
+	return entry.key, true, true
+}
+
+// JWKS returns the public keys of every key that still verifies tokens
+func (k *Keyring) JWKS() *JWKSet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := &JWKSet{Keys: []JWK{}}
+	now := time.Now()
+	for _, entry := range k.keys {
+		if !entry.key.Public() {
+			continue
+		}
+		if !entry.acceptUntil.IsZero() && now.After(entry.acceptUntil) {
+			continue
+		}
+		set.Keys = append(set.Keys, entry.key.JWK())
+	}
+	return set
+}
+
+// Sync reloads the keys periodically until ctx is cancelled
+func (k *Keyring) Sync(ctx context.Context) {
+	if k.repo == nil {
+		return
+	}
+
+	ticker := time.NewTicker(keyringReloadInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := k.Load(ctx); err != nil {
+					logger.Warn("Failed to reload signing keys", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
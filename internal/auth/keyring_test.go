@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGenerateSigningKey_PEMRoundTrip(t *testing.T) {
+	for _, alg := range []string{"EdDSA", "ES256", "RS256"} {
+		key, err := GenerateSigningKey(alg)
+		if err != nil {
+			t.Fatalf("GenerateSigningKey(%s) error = %v", alg, err)
+		}
+		data, err := key.MarshalPEM()
+		if err != nil {
+			t.Fatalf("MarshalPEM() error = %v", err)
+		}
+		parsed, err := ParseSigningKey(data)
+		if err != nil {
+			t.Fatalf("ParseSigningKey() error = %v", err)
+		}
+		if parsed.ID != key.ID || parsed.Method.Alg() != alg {
+			t.Errorf("%s: parsed key %s/%s, want %s", alg, parsed.ID, parsed.Method.Alg(), key.ID)
+		}
+	}
+
+	if _, err := GenerateSigningKey("HS256"); err == nil {
+		t.Error("HS256 should not be generated")
+	}
+}
+
+func TestKeyring_RetiredKeys(t *testing.T) {
+	ctx := context.Background()
+	active, _ := GenerateSigningKey("EdDSA")
+	retired, _ := GenerateSigningKey("ES256")
+	expired, _ := GenerateSigningKey("ES256")
+
+	kr := NewKeyring(active, nil, nil, time.Hour)
+	kr.keys[retired.ID] = keyringEntry{key: retired, acceptUntil: time.Now().Add(time.Minute)}
+	kr.keys[expired.ID] = keyringEntry{key: expired, acceptUntil: time.Now().Add(-time.Minute)}
+
+	if kr.Active() != active {
+		t.Error("configured key should be active")
+	}
+	if _, ok := kr.Lookup(ctx, retired.ID); !ok {
+		t.Error("retired key should still verify tokens")
+	}
+	if _, ok := kr.Lookup(ctx, expired.ID); ok {
+		t.Error("key past accept_until should be rejected")
+	}
+	if _, ok := kr.Lookup(ctx, "unknown"); ok {
+		t.Error("unknown kid should be rejected")
+	}
+
+	if n := len(kr.JWKS().Keys); n != 2 {
+		t.Errorf("JWKS has %d keys, want 2", n)
+	}
+}
+
+func TestKeyring_HMACNotPublished(t *testing.T) {
+	kr := NewKeyring(newHMACKey("secret"), nil, nil, time.Hour)
+
+	if _, ok := kr.Lookup(context.Background(), ""); !ok {
+		t.Error("HS256 tokens carry no kid and should find the configured key")
+	}
+	if n := len(kr.JWKS().Keys); n != 0 {
+		t.Errorf("JWKS has %d keys, want 0", n)
+	}
+}
+
+func TestSealSigningKey(t *testing.T) {
+	encryptionKey := make([]byte, 32)
+	key, _ := GenerateSigningKey("EdDSA")
+
+	sealed, err := SealSigningKey(encryptionKey, key)
+	if err != nil {
+		t.Fatalf("SealSigningKey() error = %v", err)
+	}
+	opened, err := openSigningKey(encryptionKey, key.ID, sealed)
+	if err != nil || opened.ID != key.ID {
+		t.Fatalf("openSigningKey() = %v, %v", opened, err)
+	}
+
+	if _, err := openSigningKey(encryptionKey, "other-kid", sealed); err == nil {
+		t.Error("sealed key should not open under another kid")
+	}
+	if _, err := openSigningKey(nil, key.ID, sealed); err == nil {
+		t.Error("stored key should require the encryption key")
+	}
+}
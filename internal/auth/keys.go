@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/francisco3ferraz/zk-auth/internal/config"
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSAKeyBits is the smallest RSA modulus accepted for RS256
+const minRSAKeyBits = 2048
+
+// SigningKey is a key tokens are signed and verified with
+type SigningKey struct {
+	ID      string // kid header
+	Method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// JWK is the public part of a signing key as defined in RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// ParseSigningKey parses a PEM encoded Ed25519, P-256 or RSA private key.
+// The algorithm follows from the key type: EdDSA, ES256 or RS256.
+func ParseSigningKey(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	var private interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newSigningKey(private)
+}
+
+// LoadSigningKey reads a PEM encoded private key from path
+func LoadSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSigningKey(data)
+}
+
+func newSigningKey(private interface{}) (*SigningKey, error) {
+	key := &SigningKey{private: private}
+
+	switch k := private.(type) {
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.public = k.Public()
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported curve %s, only P-256 is supported", k.Curve.Params().Name)
+		}
+		key.Method = jwt.SigningMethodES256
+		key.public = &k.PublicKey
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", minRSAKeyBits)
+		}
+		key.Method = jwt.SigningMethodRS256
+		key.public = &k.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", private)
+	}
+
+	jwk := key.JWK()
+	kid, err := thumbprint(&jwk)
+	if err != nil {
+		return nil, err
+	}
+	key.ID = kid
+
+	return key, nil
+}
+
+// newHMACKey wraps the shared JWT secret. It signs with HS256 and, being
+// symmetric, is never published.
+func newHMACKey(secret string) *SigningKey {
+	return &SigningKey{
+		Method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+}
+
+// Public reports whether the key can be published in the JWK set
+func (k *SigningKey) Public() bool {
+	return k.Method != jwt.SigningMethodHS256
+}
+
+// JWK returns the public key with kid and alg set
+func (k *SigningKey) JWK() JWK {
+	jwk := JWK{Use: "sig", Alg: k.Method.Alg(), Kid: k.ID}
+
+	switch pub := k.public.(type) {
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64URL(pub)
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = base64URL(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64URL(pub.Y.FillBytes(make([]byte, size)))
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64URL(pub.N.Bytes())
+		jwk.E = base64URL(big.NewInt(int64(pub.E)).Bytes())
+	}
+
+	return jwk
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint used as kid
+func thumbprint(jwk *JWK) (string, error) {
+	// Required members only, in lexicographic order
+	var members interface{}
+	switch jwk.Kty {
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	default:
+		return "", fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64URL(sum[:]), nil
+}
+
+func base64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// signingKeyFromConfig loads the configured private key, or falls back to
+// HS256 with the JWT secret when none is set
+func signingKeyFromConfig(cfg *config.SecurityConfig) (*SigningKey, error) {
+	if cfg.JWTPrivateKeyFile == "" {
+		return newHMACKey(cfg.JWTSecret), nil
+	}
+	return LoadSigningKey(cfg.JWTPrivateKeyFile)
+}
+
+// SigningKeyEncryptionKey decodes the key that encrypts stored signing keys
+func SigningKeyEncryptionKey(cfg *config.SecurityConfig) ([]byte, error) {
+	if cfg.SigningKeyEncryptionKey == "" {
+		return nil, fmt.Errorf("SIGNING_KEY_ENCRYPTION_KEY is not set")
+	}
+
+	key, err := hex.DecodeString(cfg.SigningKeyEncryptionKey)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("SIGNING_KEY_ENCRYPTION_KEY must be 32 hex encoded bytes")
+	}
+	return key, nil
+}
+
+// SealSigningKey encrypts key for storage. The kid is bound as additional
+// data, so a sealed key cannot be swapped into another row.
+func SealSigningKey(encryptionKey []byte, key *SigningKey) ([]byte, error) {
+	privateKey, err := key.MarshalPEM()
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Seal(encryptionKey, privateKey, []byte(key.ID))
+}
+
+// openSigningKey decrypts a key sealed by SealSigningKey
+func openSigningKey(encryptionKey []byte, kid string, sealed []byte) (*SigningKey, error) {
+	if len(encryptionKey) == 0 {
+		return nil, fmt.Errorf("SIGNING_KEY_ENCRYPTION_KEY is required to load stored signing keys")
+	}
+	privateKey, err := crypto.Open(encryptionKey, sealed, []byte(kid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return ParseSigningKey(privateKey)
+}
+
+// GenerateSigningKey creates a new private key for alg: EdDSA, ES256 or
+// RS256
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	var private interface{}
+	var err error
+	switch alg {
+	case jwt.SigningMethodEdDSA.Alg():
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case jwt.SigningMethodES256.Alg():
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwt.SigningMethodRS256.Alg():
+		private, err = rsa.GenerateKey(rand.Reader, 3072)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, use EdDSA, ES256 or RS256", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newSigningKey(private)
+}
+
+// MarshalPEM encodes the private key as PKCS #8 PEM
+func (k *SigningKey) MarshalPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func pemKey(t *testing.T, private interface{}) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestParseSigningKey(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	tests := []struct {
+		name    string
+		private interface{}
+		alg     string
+		kty     string
+	}{
+		{"Ed25519", edKey, "EdDSA", "OKP"},
+		{"P-256", ecKey, "ES256", "EC"},
+		{"RSA", rsaKey, "RS256", "RSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseSigningKey(pemKey(t, tt.private))
+			if err != nil {
+				t.Fatalf("ParseSigningKey() error = %v", err)
+			}
+			if key.Method.Alg() != tt.alg {
+				t.Errorf("alg = %s, want %s", key.Method.Alg(), tt.alg)
+			}
+
+			jwk := key.JWK()
+			if jwk.Kty != tt.kty || jwk.Kid != key.ID || key.ID == "" {
+				t.Errorf("unexpected JWK %+v", jwk)
+			}
+
+			token := jwt.NewWithClaims(key.Method, jwt.RegisteredClaims{Subject: "alice"})
+			signed, err := token.SignedString(key.private)
+			if err != nil {
+				t.Fatalf("SignedString() error = %v", err)
+			}
+			if _, err := jwt.Parse(signed, func(*jwt.Token) (interface{}, error) { return key.public, nil }); err != nil {
+				t.Errorf("Parse() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestParseSigningKey_Rejects(t *testing.T) {
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if _, err := ParseSigningKey(pemKey(t, p384)); err == nil {
+		t.Error("P-384 key should be rejected")
+	}
+
+	small, _ := rsa.GenerateKey(rand.Reader, 1024)
+	if _, err := ParseSigningKey(pemKey(t, small)); err == nil {
+		t.Error("1024-bit RSA key should be rejected")
+	}
+
+	if _, err := ParseSigningKey([]byte("not a key")); err == nil {
+		t.Error("non-PEM input should be rejected")
+	}
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/config"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func lifetimeService(idle, max time.Duration) *Service {
+	return &Service{config: &config.Config{Security: config.SecurityConfig{
+		SessionIdleTimeout: idle,
+		SessionMaxLifetime: max,
+	}}}
+}
+
+func TestCheckSessionLifetime(t *testing.T) {
+	s := lifetimeService(time.Hour, 24*time.Hour)
+	now := time.Now()
+	recent := now.Add(-10 * time.Minute)
+
+	tests := []struct {
+		name         string
+		createdAt    time.Time
+		lastSeenAt   *time.Time
+		lastActivity time.Time
+		wantErr      bool
+	}{
+		{"active", now.Add(-2 * time.Hour), nil, recent, false},
+		{"idle", now.Add(-3 * time.Hour), nil, now.Add(-2 * time.Hour), true},
+		{"seen since refresh", now.Add(-3 * time.Hour), &recent, now.Add(-2 * time.Hour), false},
+		{"past deadline", now.Add(-25 * time.Hour), nil, recent, true},
+	}
+	for _, tt := range tests {
+		session := &model.Session{CreatedAt: tt.createdAt, LastSeenAt: tt.lastSeenAt}
+		err := s.checkSessionLifetime(session, tt.lastActivity, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if err := lifetimeService(0, 0).checkSessionLifetime(&model.Session{}, time.Time{}, now); err != nil {
+		t.Errorf("disabled limits: %v", err)
+	}
+}
+
+func TestCheckTokenLifetime(t *testing.T) {
+	s := lifetimeService(time.Hour, 24*time.Hour)
+	now := time.Now()
+
+	claims := &TokenClaims{
+		AuthTime: jwt.NewNumericDate(now.Add(-time.Hour)),
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt: jwt.NewNumericDate(now.Add(-time.Minute)),
+		},
+	}
+	if err := s.checkTokenLifetime(claims, now); err != nil {
+		t.Errorf("fresh token rejected: %v", err)
+	}
+
+	claims.AuthTime = jwt.NewNumericDate(now.Add(-24 * time.Hour))
+	if err := s.checkTokenLifetime(claims, now); err == nil {
+		t.Error("token past the session deadline accepted")
+	}
+}
+
+func TestCapExpiry(t *testing.T) {
+	s := lifetimeService(0, 24*time.Hour)
+	createdAt := time.Now().Add(-23 * time.Hour)
+	session := &model.Session{CreatedAt: createdAt}
+
+	got := s.capExpiry(session, time.Now().Add(30*24*time.Hour))
+	if !got.Equal(createdAt.Add(24 * time.Hour)) {
+		t.Errorf("expiry not capped to deadline: %v", got)
+	}
+}
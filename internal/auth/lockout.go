@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/config"
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"go.uber.org/zap"
+)
+
+// loginFailureRetention is how long a failed login counts against a username
+const loginFailureRetention = 24 * time.Hour
+
+// loginFailureStore keeps the failed login counters. It is implemented by
+// model.LoginFailureRepository.
+type loginFailureStore interface {
+	Get(ctx context.Context, username string) (*model.LoginFailure, error)
+	Attempt(ctx context.Context, username string, staleBefore time.Time, allow func(*model.LoginFailure) error) (*model.LoginFailure, error)
+	Delete(ctx context.Context, username string) error
+	DeleteStale(ctx context.Context, staleBefore time.Time) (int64, error)
+}
+
+// checkLoginAllowed rejects a login attempt for username while it waits out
+// the delay after its last failure. Unknown usernames are tracked the same
+// way so the delay doesn't reveal which accounts exist.
+func (s *Service) checkLoginAllowed(ctx context.Context, username string) error {
+	if s.config.Security.LockoutThreshold <= 0 {
+		return nil
+	}
+
+	failure, err := s.failureRepo.Get(ctx, username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return errors.NewInternalError("failed to check login failures")
+	}
+	if time.Since(failure.LastFailedAt) > loginFailureRetention {
+		return nil
+	}
+
+	delay := loginDelay(&s.config.Security, failure.FailedCount)
+	if wait := time.Until(failure.LastFailedAt.Add(delay)); wait > 0 {
+		return errors.NewAccountLockedError(wait)
+	}
+	return nil
+}
+
+// beginLoginAttempt counts an attempt at a proof for username before the
+// proof is checked, and rejects it while username waits out the delay after
+// earlier failures. Counting first means parallel attempts cannot all pass
+// the check before any of them is recorded; a successful proof resets the
+// counter with resetLoginFailures. It returns the attempt's count.
+func (s *Service) beginLoginAttempt(ctx context.Context, username string) (int, error) {
+	return s.countLoginAttempt(ctx, username, 0)
+}
+
+// beginMFAAttempt counts an attempt at the second factor like
+// beginLoginAttempt. A first factor that needs a second one leaves its own
+// attempt counted until the second factor succeeds, so that attempt is not
+// held against the code; each new ticket still costs an attempt, which stops
+// fresh tickets from resetting the guesses at the code.
+func (s *Service) beginMFAAttempt(ctx context.Context, username string) (int, error) {
+	return s.countLoginAttempt(ctx, username, 1)
+}
+
+func (s *Service) countLoginAttempt(ctx context.Context, username string, pending int) (int, error) {
+	if s.config.Security.LockoutThreshold <= 0 {
+		return 0, nil
+	}
+
+	failure, err := s.failureRepo.Attempt(ctx, username, time.Now().Add(-loginFailureRetention), func(failure *model.LoginFailure) error {
+		delay := loginDelay(&s.config.Security, failure.FailedCount-pending)
+		if wait := time.Until(failure.LastFailedAt.Add(delay)); wait > 0 {
+			return errors.NewAccountLockedError(wait)
+		}
+		return nil
+	})
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return 0, appErr
+		}
+		return 0, errors.NewInternalError("failed to record login attempt")
+	}
+	return failure.FailedCount, nil
+}
+
+// loginFailed reports the failed proof of an attempt counted by
+// beginLoginAttempt
+func (s *Service) loginFailed(username string, attempt int) {
+	if attempt > 0 && attempt == s.config.Security.LockoutThreshold {
+		logger.Warn("Username locked after failed logins",
+			zap.String("username", username),
+			zap.Int("failures", attempt))
+	}
+}
+
+// resetLoginFailures clears the counter after a successful login, which
+// includes its second factor if the user has one
+func (s *Service) resetLoginFailures(ctx context.Context, username string) {
+	if s.config.Security.LockoutThreshold <= 0 {
+		return
+	}
+
+	if err := s.failureRepo.Delete(ctx, username); err != nil && err != sql.ErrNoRows {
+		logger.Warn("Failed to reset login failures",
+			zap.String("username", username),
+			zap.Error(err))
+	}
+}
+
+func (s *Service) cleanupStaleLoginFailures(ctx context.Context) {
+	if _, err := s.failureRepo.DeleteStale(ctx, time.Now().Add(-loginFailureRetention)); err != nil {
+		logger.Warn("Failed to delete stale login failures", zap.Error(err))
+	}
+}
+
+// failedProof reports whether err rejected the credentials themselves, as
+// opposed to a malformed request or a server error
+func failedProof(err error) bool {
+	appErr, ok := err.(*errors.AppError)
+	return ok && appErr.Code == errors.ErrCodeAuthentication
+}
+
+// loginDelay returns how long a username must wait after its last failure:
+// LockoutDuration once failures reach LockoutThreshold, and before that a
+// delay doubling from LoginBackoffBase up to LoginBackoffMax
+func loginDelay(cfg *config.SecurityConfig, failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	if failures >= cfg.LockoutThreshold {
+		return cfg.LockoutDuration
+	}
+
+	delay := cfg.LoginBackoffBase
+	for i := 1; i < failures && delay < cfg.LoginBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > cfg.LoginBackoffMax {
+		delay = cfg.LoginBackoffMax
+	}
+	return delay
+}
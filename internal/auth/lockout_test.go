@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/config"
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+)
+
+func TestLoginDelay(t *testing.T) {
+	cfg := &config.SecurityConfig{
+		LockoutThreshold: 10,
+		LockoutDuration:  15 * time.Minute,
+		LoginBackoffBase: time.Second,
+		LoginBackoffMax:  30 * time.Second,
+	}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{5, 16 * time.Second},
+		{6, 30 * time.Second},
+		{9, 30 * time.Second},
+		{10, 15 * time.Minute},
+		{25, 15 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := loginDelay(cfg, tt.failures); got != tt.want {
+			t.Errorf("loginDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestBeginLoginAttempt_Parallel(t *testing.T) {
+	s := &Service{
+		config: &config.Config{Security: config.SecurityConfig{
+			LockoutThreshold: 10,
+			LockoutDuration:  15 * time.Minute,
+			LoginBackoffBase: time.Minute,
+			LoginBackoffMax:  time.Hour,
+		}},
+		failureRepo: &memoryLoginFailures{failures: map[string]model.LoginFailure{}},
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed, locked := 0, 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.beginLoginAttempt(context.Background(), "alice")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				allowed++
+			} else if appErr, ok := err.(*errors.AppError); ok && appErr.Code == errors.ErrCodeAccountLocked {
+				locked++
+			} else {
+				t.Errorf("beginLoginAttempt() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The first attempt is counted before its proof is checked, so the
+	// others wait out the backoff instead of racing past the check
+	if allowed != 1 || locked != 19 {
+		t.Errorf("allowed %d and locked %d attempts, want 1 and 19", allowed, locked)
+	}
+}
+
+func TestBeginMFAAttempt_CountsAcrossTickets(t *testing.T) {
+	failures := &memoryLoginFailures{failures: map[string]model.LoginFailure{}}
+	s := &Service{
+		config: &config.Config{Security: config.SecurityConfig{
+			LockoutThreshold: 10,
+			LockoutDuration:  15 * time.Minute,
+			LoginBackoffBase: time.Minute,
+			LoginBackoffMax:  time.Hour,
+		}},
+		failureRepo: failures,
+	}
+	ctx := context.Background()
+
+	// A correct password for an account with TOTP leaves its attempt
+	// counted, but the code may be entered right away
+	if _, err := s.beginLoginAttempt(ctx, "alice"); err != nil {
+		t.Fatalf("beginLoginAttempt() error = %v", err)
+	}
+	if _, err := s.beginMFAAttempt(ctx, "alice"); err != nil {
+		t.Fatalf("beginMFAAttempt() error = %v", err)
+	}
+
+	// A wrong code counts, so neither another code nor a new ticket gets
+	// past the delay
+	if _, err := s.beginMFAAttempt(ctx, "alice"); err == nil {
+		t.Error("second code accepted without waiting out the delay")
+	}
+	if _, err := s.beginLoginAttempt(ctx, "alice"); err == nil {
+		t.Error("new ticket issued without waiting out the delay")
+	}
+	if got := failures.failures["alice"].FailedCount; got != 2 {
+		t.Errorf("FailedCount = %d, want 2", got)
+	}
+
+	// Only a correct code clears the counter
+	s.resetLoginFailures(ctx, "alice")
+	if _, err := s.beginLoginAttempt(ctx, "alice"); err != nil {
+		t.Errorf("beginLoginAttempt() after reset error = %v", err)
+	}
+}
+
+// memoryLoginFailures decides attempts one at a time like the row lock of
+// model.LoginFailureRepository
+type memoryLoginFailures struct {
+	mu       sync.Mutex
+	failures map[string]model.LoginFailure
+}
+
+func (m *memoryLoginFailures) Get(ctx context.Context, username string) (*model.LoginFailure, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	failure, ok := m.failures[username]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &failure, nil
+}
+
+func (m *memoryLoginFailures) Attempt(ctx context.Context, username string, staleBefore time.Time, allow func(*model.LoginFailure) error) (*model.LoginFailure, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	failure := m.failures[username]
+	failure.Username = username
+	if failure.LastFailedAt.Before(staleBefore) {
+		failure.FailedCount = 0
+	}
+	if err := allow(&failure); err != nil {
+		return nil, err
+	}
+	failure.FailedCount++
+	failure.LastFailedAt = time.Now()
+	m.failures[username] = failure
+	return &failure, nil
+}
+
+func (m *memoryLoginFailures) Delete(ctx context.Context, username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.failures, username)
+	return nil
+}
+
+func (m *memoryLoginFailures) DeleteStale(ctx context.Context, staleBefore time.Time) (int64, error) {
+	return 0, nil
+}
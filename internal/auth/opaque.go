@@ -0,0 +1,410 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/config"
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+)
+
+// opaqueContext binds OPAQUE handshakes to this service
+const opaqueContext = "zk-auth"
+
+// OPAQUERegisterStart evaluates the OPRF on the client's blinded password.
+// Nothing is stored until the client uploads its record.
+func (s *Service) OPAQUERegisterStart(ctx context.Context, req *OPAQUERegisterStartRequest) (*OPAQUERegisterStartResponse, error) {
+	if s.opaque == nil {
+		return nil, errOPAQUEDisabled()
+	}
+
+	request, err := hex.DecodeString(req.RegistrationRequest)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid registration_request format")
+	}
+
+	response, err := s.opaque.RegistrationResponse([]byte(req.Username), request)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid registration_request value")
+	}
+
+	return &OPAQUERegisterStartResponse{
+		RegistrationResponse: hex.EncodeToString(response),
+		KSF:                  s.kdf,
+	}, nil
+}
+
+// OPAQUERegisterFinish creates the account from the client's OPAQUE record
+func (s *Service) OPAQUERegisterFinish(ctx context.Context, req *OPAQUERegisterFinishRequest) (*RegisterResponse, error) {
+	if s.opaque == nil {
+		return nil, errOPAQUEDisabled()
+	}
+
+	start := time.Now()
+	defer s.padResponseTime(start)
+
+	record, err := s.parseOPAQUERecord(req.Record, req.KSF)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &model.User{
+		Username:      req.Username,
+		SRPGroup:      s.srp.Group.Bits,
+		HashAlgorithm: string(s.srp.Hash),
+		SRPMode:       string(s.srp.Mode),
+		KDF:           crypto.KDFParams{Algorithm: crypto.KDFNone},
+	}
+
+	taken, err := s.usernameTaken(ctx, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		user.ID = s.fakeUserID(req.Username)
+		return registeredResponse(user), nil
+	}
+
+	if err := s.opaqueRepo.CreateWithUser(ctx, user, record); err != nil {
+		if err == model.ErrUsernameTaken {
+			// Registered concurrently since the check above
+			user.ID = s.fakeUserID(req.Username)
+			return registeredResponse(user), nil
+		}
+		return nil, errors.NewInternalError("failed to create user")
+	}
+
+	return registeredResponse(user), nil
+}
+
+// OPAQUELoginStart answers KE1 with KE2. Unknown users and accounts without
+// an OPAQUE record get a fake one, so the login fails at KE3 like a wrong
+// password.
+func (s *Service) OPAQUELoginStart(ctx context.Context, req *OPAQUELoginStartRequest) (*OPAQUELoginStartResponse, error) {
+	if s.opaque == nil {
+		return nil, errOPAQUEDisabled()
+	}
+
+	start := time.Now()
+	defer s.padResponseTime(start)
+
+	ke1, err := hex.DecodeString(req.KE1)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid ke1 format")
+	}
+	deviceName, err := normalizeDeviceName(req.DeviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkLoginAllowed(ctx, req.Username); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByUsername(ctx, req.Username)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.NewInternalError("failed to retrieve user")
+	}
+	if err == sql.ErrNoRows {
+		user = &model.User{Username: req.Username}
+	}
+
+	credentialID := []byte(user.Username)
+	record, ksf, err := s.opaqueRecordFor(ctx, user.ID, credentialID)
+	if err != nil {
+		return nil, err
+	}
+
+	ke2, login, err := s.opaque.LoginResponse(credentialID, record, credentialID, ke1)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid ke1 value")
+	}
+
+	challenge := &AuthChallenge{
+		UserID:    user.ID,
+		Username:  user.Username,
+		OPAQUE:    login,
+		CreatedAt: time.Now(),
+	}
+
+	if user.ID == "" {
+		challenge.SessionID, err = newRandomID()
+		if err != nil {
+			return nil, errors.NewInternalError("failed to generate session id")
+		}
+	} else {
+		session := &model.Session{
+			UserID:     user.ID,
+			DeviceName: deviceName,
+			ExpiresAt:  time.Now().Add(challengeTTL),
+		}
+		recordClient(ctx, session)
+		if err := s.sessionRepo.Create(ctx, session); err != nil {
+			return nil, errors.NewInternalError("failed to create session")
+		}
+		challenge.SessionID = session.ID
+	}
+	if err := s.putChallenge(ctx, challenge); err != nil {
+		return nil, err
+	}
+
+	return &OPAQUELoginStartResponse{
+		SessionID: challenge.SessionID,
+		KE2:       hex.EncodeToString(ke2),
+		KSF:       ksf,
+	}, nil
+}
+
+// OPAQUELoginFinish checks KE3 and issues the same token as an SRP login
+func (s *Service) OPAQUELoginFinish(ctx context.Context, req *OPAQUELoginFinishRequest) (*OPAQUELoginFinishResponse, error) {
+	if s.opaque == nil {
+		return nil, errOPAQUEDisabled()
+	}
+
+	ke3, err := hex.DecodeString(req.KE3)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid ke3 format")
+	}
+
+	challenge, err := s.takeChallenge(ctx, req.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.OPAQUE == nil || challenge.BoundSessionID != "" {
+		// SRP challenges and re-authentications are answered elsewhere
+		return nil, errors.NewAuthenticationError("invalid or expired session")
+	}
+
+	attempt, err := s.beginLoginAttempt(ctx, challenge.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := challenge.OPAQUE.Finish(ke3); err != nil || challenge.UserID == "" {
+		s.loginFailed(challenge.Username, attempt)
+		return nil, errors.NewAuthenticationError("invalid credentials")
+	}
+
+	mfa, err := s.mfaRequired(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa {
+		// Failures are reset once the second factor succeeds
+		ticket, err := s.startMFA(ctx, challenge, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &OPAQUELoginFinishResponse{
+			MFAPending: true,
+			MFATicket:  ticket,
+		}, nil
+	}
+
+	s.resetLoginFailures(ctx, challenge.Username)
+
+	tokens, err := s.openSession(ctx, challenge.SessionID, challenge.Username, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OPAQUELoginFinishResponse{
+		Token:            tokens.AccessToken,
+		ExpiresAt:        tokens.ExpiresAt,
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt,
+	}, nil
+}
+
+// OPAQUEReauthStart answers KE1 with KE2 for a re-authentication of the
+// caller's OPAQUE account. Like StartReauthChallenge it is bound to the
+// caller's session, and the KE3 goes in the ReauthProof of the operation.
+func (s *Service) OPAQUEReauthStart(ctx context.Context, claims *TokenClaims, req *OPAQUEReauthRequest) (*OPAQUEReauthResponse, error) {
+	if s.opaque == nil {
+		return nil, errOPAQUEDisabled()
+	}
+
+	ke1, err := hex.DecodeString(req.KE1)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid ke1 format")
+	}
+
+	if err := s.checkLoginAllowed(ctx, claims.Username); err != nil {
+		return nil, err
+	}
+
+	// Accounts registered with SRP get a fake record and fail at KE3
+	record, ksf, err := s.opaqueRecordFor(ctx, claims.UserID, []byte(claims.Username))
+	if err != nil {
+		return nil, err
+	}
+
+	challengeID, ke2, err := s.startOPAQUEReauth(ctx, claims, record, ke1)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OPAQUEReauthResponse{
+		ChallengeID: challengeID,
+		KE2:         hex.EncodeToString(ke2),
+		KSF:         ksf,
+	}, nil
+}
+
+// startOPAQUEReauth runs the server side of KE2 against record and stores
+// the handshake as a challenge bound to the caller's session
+func (s *Service) startOPAQUEReauth(ctx context.Context, claims *TokenClaims, record *crypto.OPAQUERecord, ke1 []byte) (string, []byte, error) {
+	credentialID := []byte(claims.Username)
+	ke2, login, err := s.opaque.LoginResponse(credentialID, record, credentialID, ke1)
+	if err != nil {
+		return "", nil, errors.NewBadRequestError("invalid ke1 value")
+	}
+
+	challengeID, err := newRandomID()
+	if err != nil {
+		return "", nil, errors.NewInternalError("failed to generate challenge id")
+	}
+
+	challenge := &AuthChallenge{
+		SessionID:      challengeID,
+		UserID:         claims.UserID,
+		Username:       claims.Username,
+		OPAQUE:         login,
+		BoundSessionID: claims.SessionID,
+		CreatedAt:      time.Now(),
+	}
+	if err := s.putChallenge(ctx, challenge); err != nil {
+		return "", nil, err
+	}
+
+	return challengeID, ke2, nil
+}
+
+// verifyOPAQUEProof checks the KE3 answering an OPAQUE challenge
+func verifyOPAQUEProof(challenge *AuthChallenge, ke3Hex string) error {
+	ke3, err := hex.DecodeString(ke3Hex)
+	if err != nil {
+		return errors.NewBadRequestError("invalid ke3 format")
+	}
+	if _, err := challenge.OPAQUE.Finish(ke3); err != nil {
+		return errors.NewAuthenticationError("invalid credentials")
+	}
+	return nil
+}
+
+// changeOPAQUERecord replaces the record of an OPAQUE account after a fresh
+// re-authentication. The client builds the new record from the registration
+// response of OPAQUERegisterStart for its own username.
+func (s *Service) changeOPAQUERecord(ctx context.Context, claims *TokenClaims, req *ChangePasswordRequest) (*ChangePasswordResponse, error) {
+	// Check the new record before the challenge is consumed
+	record, err := s.parseOPAQUERecord(req.Record, req.KSF)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, serverProof, err := s.verifyReauthProof(ctx, claims, &req.ReauthProof)
+	if err != nil {
+		return nil, err
+	}
+
+	record.UserID = challenge.UserID
+	if err := s.opaqueRepo.Update(ctx, record); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewValidationError("account does not use OPAQUE, submit a salt and verifier instead")
+		}
+		return nil, errors.NewInternalError("failed to update password")
+	}
+
+	s.revokeOtherSessions(ctx, challenge.UserID, claims.SessionID)
+
+	return &ChangePasswordResponse{
+		Message:     "Password changed successfully",
+		ServerProof: hex.EncodeToString(serverProof),
+	}, nil
+}
+
+// parseOPAQUERecord checks an uploaded record and the key stretching the
+// client applied, which must meet the current parameters
+func (s *Service) parseOPAQUERecord(recordHex string, ksfParams *crypto.KDFParams) (*model.OPAQUERecord, error) {
+	recordBytes, err := hex.DecodeString(recordHex)
+	if err != nil {
+		return nil, errors.NewBadRequestError("invalid record format")
+	}
+	if _, err := crypto.ParseOPAQUERecord(recordBytes); err != nil {
+		return nil, errors.NewValidationError("invalid record value")
+	}
+
+	ksf := crypto.KDFParams{Algorithm: crypto.KDFNone}
+	if ksfParams != nil {
+		ksf = *ksfParams
+	}
+	if err := ksf.Validate(); err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("invalid ksf: %v", err))
+	}
+	if !ksf.AtLeast(s.kdf) {
+		return nil, errors.NewValidationError("ksf does not meet the current parameters")
+	}
+
+	return &model.OPAQUERecord{
+		Record: recordBytes,
+		KSF:    ksf,
+	}, nil
+}
+
+// opaqueRecordFor loads the user's OPAQUE record, or a fake one if the user
+// is unknown or was registered with SRP
+func (s *Service) opaqueRecordFor(ctx context.Context, userID string, credentialID []byte) (*crypto.OPAQUERecord, crypto.KDFParams, error) {
+	var stored *model.OPAQUERecord
+	err := sql.ErrNoRows
+	if userID != "" {
+		stored, err = s.opaqueRepo.GetByUserID(ctx, userID)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return nil, crypto.KDFParams{}, errors.NewInternalError("failed to retrieve OPAQUE record")
+	}
+
+	if err == nil {
+		record, err := crypto.ParseOPAQUERecord(stored.Record)
+		if err != nil {
+			return nil, crypto.KDFParams{}, errors.NewInternalError("invalid OPAQUE record")
+		}
+		return record, stored.KSF, nil
+	}
+
+	record, err := s.opaque.FakeRecord(credentialID)
+	if err != nil {
+		return nil, crypto.KDFParams{}, errors.NewInternalError("failed to create OPAQUE record")
+	}
+	return record, s.fakeKSF(string(credentialID)), nil
+}
+
+func (s *Service) usernameTaken(ctx context.Context, username string) (bool, error) {
+	exists, err := s.userRepo.ExistsByUsername(ctx, username)
+	if err != nil {
+		return false, errors.NewInternalError("failed to check user existence")
+	}
+	return exists, nil
+}
+
+func errOPAQUEDisabled() *errors.AppError {
+	return errors.NewNotFoundError("OPAQUE")
+}
+
+// opaqueFromConfig returns the OPAQUE server, or nil if no secret is set
+func opaqueFromConfig(cfg *config.OPAQUEConfig) (*crypto.OPAQUEServer, error) {
+	if cfg.ServerSecret == "" {
+		return nil, nil
+	}
+
+	secret, err := hex.DecodeString(cfg.ServerSecret)
+	if err != nil {
+		return nil, fmt.Errorf("OPAQUE_SERVER_SECRET must be hex encoded")
+	}
+
+	return crypto.NewOPAQUEServer(secret, opaqueContext)
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/francisco3ferraz/zk-auth/internal/config"
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+)
+
+func TestVerifyReauthProof_OPAQUE(t *testing.T) {
+	ctx := context.Background()
+	server, err := crypto.NewOPAQUEServer(bytes.Repeat([]byte{0x42}, 32), opaqueContext)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	s := &Service{
+		opaque:     server,
+		challenges: NewMemoryChallengeStore(),
+		config:     &config.Config{},
+	}
+	client := crypto.NewOPAQUEClient(opaqueContext)
+	ksf := crypto.KDFParams{Algorithm: crypto.KDFNone}
+	claims := &TokenClaims{UserID: "user-1", Username: "alice", SessionID: "session-1"}
+
+	registration, request, _ := client.StartRegistration([]byte("password123"))
+	response, _ := server.RegistrationResponse([]byte(claims.Username), request)
+	record, _, err := registration.Finish(ksf, []byte(claims.Username), response)
+	if err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+
+	reauth := func() *ReauthProof {
+		t.Helper()
+		login, ke1, _ := client.StartLogin([]byte("password123"))
+		challengeID, ke2, err := s.startOPAQUEReauth(ctx, claims, record, ke1)
+		if err != nil {
+			t.Fatalf("startOPAQUEReauth() error = %v", err)
+		}
+		ke3, _, _, err := login.Finish(ksf, []byte(claims.Username), ke2)
+		if err != nil {
+			t.Fatalf("client rejected KE2: %v", err)
+		}
+		return &ReauthProof{ChallengeID: challengeID, KE3: hex.EncodeToString(ke3)}
+	}
+
+	proof := reauth()
+	challenge, _, err := s.verifyReauthProof(ctx, claims, proof)
+	if err != nil {
+		t.Fatalf("verifyReauthProof() error = %v", err)
+	}
+	if challenge.UserID != claims.UserID {
+		t.Errorf("challenge user = %q, want %q", challenge.UserID, claims.UserID)
+	}
+	if _, _, err := s.verifyReauthProof(ctx, claims, proof); err == nil {
+		t.Error("proof should only be accepted once")
+	}
+
+	other := *claims
+	other.SessionID = "session-2"
+	if _, _, err := s.verifyReauthProof(ctx, &other, reauth()); err == nil {
+		t.Error("proof should be bound to the session that started it")
+	}
+
+	wrong := reauth()
+	wrong.KE3 = reauth().KE3
+	if _, _, err := s.verifyReauthProof(ctx, claims, wrong); !failedProof(err) {
+		t.Errorf("KE3 of another handshake: error = %v, want authentication error", err)
+	}
+
+	// A re-authentication must not open a new session
+	proof = reauth()
+	finish := &OPAQUELoginFinishRequest{SessionID: proof.ChallengeID, KE3: proof.KE3}
+	if _, err := s.OPAQUELoginFinish(ctx, finish); err == nil {
+		t.Error("re-authentication challenge accepted as a login")
+	}
+}
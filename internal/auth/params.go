@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/config"
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"go.uber.org/zap"
+)
+
+// RegistrationParams returns the SRP parameters clients must use when
+// computing a verifier for a new account or a password change.
+func (s *Service) RegistrationParams() *RegistrationParamsResponse {
+	kdf := s.kdf
+	return &RegistrationParamsResponse{
+		SRPGroup:      s.srp.Group.Bits,
+		HashAlgorithm: string(s.srp.Hash),
+		SRPMode:       string(s.srp.Mode),
+		KDF:           &kdf,
+	}
+}
+
+// Server side password hashing, used by the legacy plaintext endpoints, is
+// limited to a few runs at a time so unauthenticated requests cannot tie up
+// every core and the KDF memory.
+const (
+	maxConcurrentKDF = 4
+	kdfQueueTimeout  = 5 * time.Second
+)
+
+// computeVerifier derives a verifier from a plaintext password once a KDF
+// slot is free. It fails with too many requests if none frees up in time.
+func (s *Service) computeVerifier(ctx context.Context, srp *crypto.SRP, username, password string, salt []byte, kdf crypto.KDFParams) (*big.Int, error) {
+	timer := time.NewTimer(kdfQueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case s.kdfSlots <- struct{}{}:
+	case <-timer.C:
+		return nil, errors.NewTooManyRequestsError()
+	case <-ctx.Done():
+		return nil, errors.NewTooManyRequestsError()
+	}
+	defer func() { <-s.kdfSlots }()
+
+	verifier, err := srp.ComputeVerifier(username, password, salt, kdf)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to compute verifier")
+	}
+	return verifier, nil
+}
+
+// upgradeFor returns the parameters a user's verifier should be recomputed
+// with, or nil when the stored verifier already meets the current policy.
+func (s *Service) upgradeFor(user *model.User) *RegistrationParamsResponse {
+	if user.SRPGroup >= s.srp.Group.Bits && user.KDF.AtLeast(s.kdf) &&
+		crypto.HashAlgorithm(user.HashAlgorithm).AtLeast(s.srp.Hash) &&
+		crypto.Mode(user.SRPMode).AtLeast(s.srp.Mode) {
+		return nil
+	}
+	return s.RegistrationParams()
+}
+
+// upgradeHint returns the parameters to recompute the user's verifier with,
+// or nil. It is only sent once the user has authenticated, as the hint would
+// otherwise tell real accounts from unknown usernames.
+func (s *Service) upgradeHint(ctx context.Context, userID string) *RegistrationParamsResponse {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		logger.Warn("Failed to check verifier parameters",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return nil
+	}
+	if user.Verifier == nil {
+		// OPAQUE accounts have no verifier to upgrade
+		return nil
+	}
+	return s.upgradeFor(user)
+}
+
+// upgradeVerifier stores credentials the client recomputed during login.
+// The login itself has already succeeded, so failures are only logged.
+func (s *Service) upgradeVerifier(ctx context.Context, userID string, upgraded *model.User) bool {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err == nil && s.upgradeFor(user) == nil {
+		// Nothing was offered; password changes go through ChangePassword
+		return false
+	}
+	if err == nil {
+		user.Salt = upgraded.Salt
+		user.Verifier = upgraded.Verifier
+		user.SRPGroup = upgraded.SRPGroup
+		user.HashAlgorithm = upgraded.HashAlgorithm
+		user.SRPMode = upgraded.SRPMode
+		user.KDF = upgraded.KDF
+		err = s.userRepo.Update(ctx, user)
+	}
+
+	if err != nil {
+		logger.Warn("Failed to upgrade verifier",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return false
+	}
+
+	return true
+}
+
+// applyVerifierCredentials decodes a client-computed salt and verifier,
+// checks them against the server's policy and stores them on user.
+func (s *Service) applyVerifierCredentials(user *model.User, creds *VerifierCredentials) error {
+	groupBits := creds.SRPGroup
+	if groupBits == 0 {
+		groupBits = s.srp.Group.Bits
+	}
+	if groupBits < s.srp.Group.Bits {
+		return errors.NewValidationError(fmt.Sprintf("srp_group must be at least %d bits", s.srp.Group.Bits))
+	}
+	hashName := creds.HashAlgorithm
+	if hashName == "" {
+		hashName = string(s.srp.Hash)
+	}
+	hash, err := crypto.ParseHashAlgorithm(hashName)
+	if err != nil {
+		return errors.NewValidationError("unsupported hash_algorithm")
+	}
+	if !hash.AtLeast(s.srp.Hash) {
+		return errors.NewValidationError(fmt.Sprintf("hash_algorithm must be at least as strong as %s", s.srp.Hash))
+	}
+	modeName := creds.SRPMode
+	if modeName == "" {
+		modeName = string(s.srp.Mode)
+	}
+	mode, err := crypto.ParseMode(modeName)
+	if err != nil {
+		return errors.NewValidationError("unsupported srp_mode")
+	}
+	if !mode.AtLeast(s.srp.Mode) {
+		return errors.NewValidationError(fmt.Sprintf("srp_mode must be %s", s.srp.Mode))
+	}
+	srp, err := s.srpFor(groupBits, string(hash), string(mode))
+	if err != nil {
+		return errors.NewValidationError("unsupported srp_group")
+	}
+
+	salt, err := hex.DecodeString(creds.Salt)
+	if err != nil {
+		return errors.NewBadRequestError("invalid salt format")
+	}
+	if len(salt) < crypto.MinSaltLength || len(salt) > crypto.MaxSaltLength {
+		return errors.NewValidationError(fmt.Sprintf("salt must be between %d and %d bytes", crypto.MinSaltLength, crypto.MaxSaltLength))
+	}
+
+	verifierBytes, err := hex.DecodeString(creds.Verifier)
+	if err != nil {
+		return errors.NewBadRequestError("invalid verifier format")
+	}
+
+	verifier := new(big.Int).SetBytes(verifierBytes)
+	if err := srp.ValidateVerifier(verifier); err != nil {
+		return errors.NewValidationError("invalid verifier value")
+	}
+
+	kdf := crypto.KDFParams{Algorithm: crypto.KDFNone}
+	if creds.KDF != nil {
+		kdf = *creds.KDF
+	}
+	if err := kdf.Validate(); err != nil {
+		return errors.NewValidationError(fmt.Sprintf("invalid kdf: %v", err))
+	}
+	if !kdf.AtLeast(s.kdf) {
+		return errors.NewValidationError("kdf does not meet the current parameters")
+	}
+
+	user.Salt = salt
+	user.Verifier = verifier.Bytes()
+	user.SRPGroup = groupBits
+	user.HashAlgorithm = string(hash)
+	user.SRPMode = string(mode)
+	user.KDF = kdf
+	return nil
+}
+
+// srpParams identifies an SRP instance by group size, hash algorithm and mode
+type srpParams struct {
+	group int
+	hash  crypto.HashAlgorithm
+	mode  crypto.Mode
+}
+
+// srpFor returns the SRP instance for the parameters stored with a user
+func (s *Service) srpFor(groupBits int, hashAlgorithm, modeName string) (*crypto.SRP, error) {
+	hash, err := crypto.ParseHashAlgorithm(hashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	if modeName == "" {
+		modeName = string(crypto.DefaultMode)
+	}
+	mode, err := crypto.ParseMode(modeName)
+	if err != nil {
+		return nil, err
+	}
+
+	srp, ok := s.srps[srpParams{group: groupBits, hash: hash, mode: mode}]
+	if !ok {
+		return nil, fmt.Errorf("unsupported SRP group size: %d", groupBits)
+	}
+	return srp, nil
+}
+
+// kdfFromConfig builds the KDF parameters for new verifiers
+func kdfFromConfig(cfg *config.SRPConfig) (crypto.KDFParams, error) {
+	kdf := crypto.KDFParams{Algorithm: crypto.KDFAlgorithm(cfg.KDFAlgorithm)}
+
+	switch kdf.Algorithm {
+	case crypto.KDFArgon2id:
+		kdf.Time = uint32(cfg.Argon2Time)
+		kdf.Memory = uint32(cfg.Argon2Memory)
+		kdf.Threads = uint8(cfg.Argon2Threads)
+	case crypto.KDFScrypt:
+		kdf.N = cfg.ScryptN
+		kdf.R = cfg.ScryptR
+		kdf.P = cfg.ScryptP
+	}
+
+	if err := kdf.Validate(); err != nil {
+		return crypto.KDFParams{}, err
+	}
+
+	return kdf, nil
+}
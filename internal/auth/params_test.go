@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+)
+
+func TestComputeVerifier_BoundedConcurrency(t *testing.T) {
+	s := &Service{kdfSlots: make(chan struct{}, 1)}
+	srp := crypto.NewSRP(crypto.Groups()[0], crypto.HashAlgorithms()[0], crypto.Modes()[0])
+	kdf := crypto.KDFParams{Algorithm: crypto.KDFNone}
+
+	if _, err := s.computeVerifier(context.Background(), srp, "alice", "password", []byte("salt"), kdf); err != nil {
+		t.Fatalf("computeVerifier() error = %v", err)
+	}
+
+	// With every slot taken the request gives up instead of queueing
+	s.kdfSlots <- struct{}{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.computeVerifier(ctx, srp, "alice", "password", []byte("salt"), kdf)
+	if appErr, ok := err.(*errors.AppError); !ok || appErr.Code != errors.ErrCodeTooManyRequests {
+		t.Errorf("computeVerifier() error = %v, want too many requests", err)
+	}
+}
+
+func TestApplyVerifierCredentials_EnforcesPolicy(t *testing.T) {
+	group, _ := crypto.GroupByBits(2048)
+	srp := crypto.NewSRP(group, crypto.SHA256, crypto.ModeRFC5054)
+	policy := crypto.KDFParams{Algorithm: crypto.KDFArgon2id, Time: 3, Memory: 64 * 1024, Threads: 4}
+	s := &Service{
+		srp:  srp,
+		srps: map[srpParams]*crypto.SRP{{group: 2048, hash: crypto.SHA256, mode: crypto.ModeRFC5054}: srp},
+		kdf:  policy,
+	}
+
+	salt := make([]byte, crypto.MinSaltLength)
+	verifier, err := srp.ComputeVerifier("alice", "password", salt, crypto.KDFParams{Algorithm: crypto.KDFNone})
+	if err != nil {
+		t.Fatalf("ComputeVerifier() error = %v", err)
+	}
+	creds := func() *VerifierCredentials {
+		kdf := policy
+		return &VerifierCredentials{
+			Salt:          hex.EncodeToString(salt),
+			Verifier:      hex.EncodeToString(verifier.Bytes()),
+			SRPGroup:      2048,
+			HashAlgorithm: string(crypto.SHA256),
+			SRPMode:       string(crypto.ModeRFC5054),
+			KDF:           &kdf,
+		}
+	}
+
+	if err := s.applyVerifierCredentials(&model.User{}, creds()); err != nil {
+		t.Errorf("credentials meeting the policy rejected: %v", err)
+	}
+
+	weak := map[string]func(*VerifierCredentials){
+		"kdf none":    func(c *VerifierCredentials) { c.KDF = &crypto.KDFParams{Algorithm: crypto.KDFNone} },
+		"kdf omitted": func(c *VerifierCredentials) { c.KDF = nil },
+		"cheaper kdf": func(c *VerifierCredentials) { c.KDF.Time = 1 },
+		"sha1":        func(c *VerifierCredentials) { c.HashAlgorithm = string(crypto.SHA1) },
+		"legacy mode": func(c *VerifierCredentials) { c.SRPMode = string(crypto.ModeLegacy) },
+	}
+	for name, weaken := range weak {
+		c := creds()
+		weaken(c)
+		if err := s.applyVerifierCredentials(&model.User{}, c); err == nil {
+			t.Errorf("%s: credentials accepted", name)
+		}
+	}
+}
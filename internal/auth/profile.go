@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+)
+
+// Limits on profile fields
+const (
+	maxDisplayNameLength = 100
+	maxEmailLength       = 254
+	maxLocaleLength      = 35
+	// maxProfileMetadataSize is the largest metadata object accepted, in
+	// bytes of compact JSON
+	maxProfileMetadataSize = 4 << 10
+)
+
+// localePattern matches BCP 47 language tags such as "en", "pt-BR" or
+// "zh-Hant-TW"
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// GetProfile returns the caller's profile. Users who never edited it get
+// empty fields.
+func (s *Service) GetProfile(ctx context.Context, claims *TokenClaims) (*ProfileResponse, error) {
+	profile, err := s.profileFor(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return s.profileResponse(ctx, claims, profile)
+}
+
+// UpdateProfile changes the fields present in req and leaves the others as
+// they are. A null metadata resets it to an empty object.
+func (s *Service) UpdateProfile(ctx context.Context, claims *TokenClaims, req *UpdateProfileRequest) (*ProfileResponse, error) {
+	profile, err := s.profileFor(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DisplayName != nil {
+		if profile.DisplayName, err = normalizeDisplayName(*req.DisplayName); err != nil {
+			return nil, err
+		}
+	}
+	if req.Email != nil {
+		if profile.Email, err = normalizeEmail(*req.Email); err != nil {
+			return nil, err
+		}
+	}
+	if req.Locale != nil {
+		if profile.Locale, err = normalizeLocale(*req.Locale); err != nil {
+			return nil, err
+		}
+	}
+	if req.Metadata != nil {
+		if profile.Metadata, err = normalizeMetadata(req.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.profileRepo.Upsert(ctx, profile); err != nil {
+		return nil, errors.NewInternalError("failed to update profile")
+	}
+
+	return s.profileResponse(ctx, claims, profile)
+}
+
+// profileFor loads the user's profile, or an empty one if there is none yet
+func (s *Service) profileFor(ctx context.Context, userID string) (*model.Profile, error) {
+	profile, err := s.profileRepo.GetByUserID(ctx, userID)
+	if err == sql.ErrNoRows {
+		return &model.Profile{UserID: userID, Metadata: json.RawMessage("{}")}, nil
+	}
+	if err != nil {
+		return nil, errors.NewInternalError("failed to retrieve profile")
+	}
+	return profile, nil
+}
+
+func (s *Service) profileResponse(ctx context.Context, claims *TokenClaims, profile *model.Profile) (*ProfileResponse, error) {
+	remaining, err := s.RecoveryCodesRemaining(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	deleteAfter, err := s.scheduledDeletion(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProfileResponse{
+		UserID:                 claims.UserID,
+		Username:               claims.Username,
+		DisplayName:            profile.DisplayName,
+		Email:                  profile.Email,
+		Locale:                 profile.Locale,
+		Metadata:               profile.Metadata,
+		SessionID:              claims.SessionID,
+		ExpiresAt:              claims.ExpiresAt,
+		RecoveryCodesRemaining: remaining,
+		DeleteAfter:            deleteAfter,
+	}, nil
+}
+
+// normalizeDisplayName trims name and rejects names that are too long or
+// contain control characters. An empty name clears it.
+func normalizeDisplayName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxDisplayNameLength {
+		return "", errors.NewValidationError("display_name must be at most 100 characters")
+	}
+	if !utf8.ValidString(name) || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return "", errors.NewValidationError("display_name contains invalid characters")
+	}
+	return name, nil
+}
+
+// normalizeEmail accepts a bare address such as "ana@example.com". An empty
+// email clears it.
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", nil
+	}
+	if len(email) > maxEmailLength {
+		return "", errors.NewValidationError("email must be at most 254 characters")
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", errors.NewValidationError("email is not a valid address")
+	}
+	return email, nil
+}
+
+// normalizeLocale accepts a BCP 47 language tag. An empty locale clears it.
+func normalizeLocale(locale string) (string, error) {
+	locale = strings.TrimSpace(locale)
+	if locale == "" {
+		return "", nil
+	}
+	if len(locale) > maxLocaleLength || !localePattern.MatchString(locale) {
+		return "", errors.NewValidationError("locale must be a language tag such as en or pt-BR")
+	}
+	return locale, nil
+}
+
+// normalizeMetadata compacts a JSON object and enforces the size cap. null
+// stands for an empty object.
+func normalizeMetadata(raw json.RawMessage) (json.RawMessage, error) {
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return json.RawMessage("{}"), nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, errors.NewValidationError("metadata must be a JSON object")
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return nil, errors.NewValidationError("metadata must be a JSON object")
+	}
+	if compact.Len() > maxProfileMetadataSize {
+		return nil, errors.NewValidationError("metadata must be at most 4096 bytes")
+	}
+	return compact.Bytes(), nil
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeProfileFields(t *testing.T) {
+	valid := []struct {
+		name string
+		fn   func(string) (string, error)
+		in   string
+		want string
+	}{
+		{"display name", normalizeDisplayName, "  Ana Lima ", "Ana Lima"},
+		{"empty display name", normalizeDisplayName, "", ""},
+		{"email", normalizeEmail, " ana@example.com", "ana@example.com"},
+		{"empty email", normalizeEmail, "", ""},
+		{"locale", normalizeLocale, "pt-BR", "pt-BR"},
+		{"script locale", normalizeLocale, "zh-Hant-TW", "zh-Hant-TW"},
+	}
+	for _, tt := range valid {
+		got, err := tt.fn(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	invalid := []struct {
+		name string
+		fn   func(string) (string, error)
+		in   string
+	}{
+		{"long display name", normalizeDisplayName, strings.Repeat("a", maxDisplayNameLength+1)},
+		{"control character", normalizeDisplayName, "Ana\x07"},
+		{"email with name", normalizeEmail, "Ana <ana@example.com>"},
+		{"not an email", normalizeEmail, "ana"},
+		{"long email", normalizeEmail, strings.Repeat("a", maxEmailLength) + "@example.com"},
+		{"bad locale", normalizeLocale, "english"},
+		{"locale with underscore", normalizeLocale, "pt_BR"},
+	}
+	for _, tt := range invalid {
+		if _, err := tt.fn(tt.in); err == nil {
+			t.Errorf("%s: %q accepted", tt.name, tt.in)
+		}
+	}
+}
+
+func TestNormalizeMetadata(t *testing.T) {
+	got, err := normalizeMetadata(json.RawMessage(`{ "theme": "dark",  "beta": true }`))
+	if err != nil || string(got) != `{"theme":"dark","beta":true}` {
+		t.Errorf("got %s, %v", got, err)
+	}
+
+	if got, err := normalizeMetadata(json.RawMessage(`null`)); err != nil || string(got) != `{}` {
+		t.Errorf("null: got %s, %v", got, err)
+	}
+
+	for _, raw := range []string{`[1, 2]`, `"text"`, `{"a":`} {
+		if _, err := normalizeMetadata(json.RawMessage(raw)); err == nil {
+			t.Errorf("%s accepted", raw)
+		}
+	}
+
+	big := `{"blob":"` + strings.Repeat("x", maxProfileMetadataSize) + `"}`
+	if _, err := normalizeMetadata(json.RawMessage(big)); err == nil {
+		t.Error("oversized metadata accepted")
+	}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	// recoveryCodeCount is the number of codes in a batch
+	recoveryCodeCount = 10
+	// recoveryCodeLength is the number of random bytes in a code, giving
+	// 16 base32 characters
+	recoveryCodeLength = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateRecoveryCodes replaces the caller's recovery codes with a new
+// batch. Like a password change it needs a fresh SRP or OPAQUE proof, since
+// the codes can take over the account. The codes are only returned here.
+func (s *Service) GenerateRecoveryCodes(ctx context.Context, claims *TokenClaims, req *RecoveryCodesRequest) (*RecoveryCodesResponse, error) {
+	_, serverProof, err := s.verifyReauthProof(ctx, claims, &req.ReauthProof)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([][]byte, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = newRecoveryCode()
+		if err != nil {
+			return nil, errors.NewInternalError("failed to generate recovery codes")
+		}
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	if err := s.recoveryRepo.Replace(ctx, claims.UserID, hashes); err != nil {
+		return nil, errors.NewInternalError("failed to store recovery codes")
+	}
+
+	logger.Info("Recovery codes generated", zap.String("user_id", claims.UserID))
+
+	return &RecoveryCodesResponse{
+		Codes:       codes,
+		ServerProof: hex.EncodeToString(serverProof),
+	}, nil
+}
+
+// RecoverAccount spends a recovery code to set a new salt and verifier for a
+// user who forgot their password. Every session of the account is ended.
+func (s *Service) RecoverAccount(ctx context.Context, req *RecoverAccountRequest) (*RecoverAccountResponse, error) {
+	start := time.Now()
+	defer s.padResponseTime(start)
+
+	updated := &model.User{}
+	if err := s.applyVerifierCredentials(updated, &req.VerifierCredentials); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByUsername(ctx, req.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewAuthenticationError("invalid recovery code")
+		}
+		return nil, errors.NewInternalError("failed to retrieve user")
+	}
+
+	updated.ID = user.ID
+	recovered, err := s.recoveryRepo.Recover(ctx, hashRecoveryCode(req.RecoveryCode), updated)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to recover account")
+	}
+	if !recovered {
+		return nil, errors.NewAuthenticationError("invalid recovery code")
+	}
+
+	s.revokeOtherSessions(ctx, user.ID, "")
+
+	logger.Info("Account recovered with a recovery code", zap.String("user_id", user.ID))
+
+	return &RecoverAccountResponse{
+		Message: "Password reset successfully",
+	}, nil
+}
+
+// RecoveryCodesRemaining returns the number of unused recovery codes
+func (s *Service) RecoveryCodesRemaining(ctx context.Context, userID string) (int, error) {
+	count, err := s.recoveryRepo.CountUnused(ctx, userID)
+	if err != nil {
+		return 0, errors.NewInternalError("failed to count recovery codes")
+	}
+	return count, nil
+}
+
+// newRecoveryCode returns a code formatted as four groups of four
+// characters, e.g. "abcd-efgh-ijkl-mnop"
+func newRecoveryCode() (string, error) {
+	b, err := crypto.GenerateRandomBytes(recoveryCodeLength)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+	groups := make([]string, 0, len(encoded)/4)
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:i+4])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// hashRecoveryCode hashes a code after removing separators and case, so it
+// can be typed back in any format
+func hashRecoveryCode(code string) []byte {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+
+	sum := sha256.Sum256([]byte(normalized))
+	return sum[:]
+}
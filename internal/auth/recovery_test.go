@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestNewRecoveryCode(t *testing.T) {
+	code, err := newRecoveryCode()
+	if err != nil {
+		t.Fatalf("newRecoveryCode() error = %v", err)
+	}
+	if !regexp.MustCompile(`^[a-z2-7]{4}(-[a-z2-7]{4}){3}$`).MatchString(code) {
+		t.Errorf("newRecoveryCode() = %q, want four groups of four base32 characters", code)
+	}
+
+	other, _ := newRecoveryCode()
+	if code == other {
+		t.Error("newRecoveryCode() returned the same code twice")
+	}
+}
+
+func TestHashRecoveryCode_IgnoresFormatting(t *testing.T) {
+	want := hashRecoveryCode("abcd-efgh-ijkl-mnop")
+
+	for _, typed := range []string{"ABCD-EFGH-IJKL-MNOP", "abcdefghijklmnop", "abcd efgh ijkl mnop"} {
+		if !bytes.Equal(hashRecoveryCode(typed), want) {
+			t.Errorf("hashRecoveryCode(%q) differs from the formatted code", typed)
+		}
+	}
+	if bytes.Equal(hashRecoveryCode("abcd-efgh-ijkl-mnoq"), want) {
+		t.Error("different codes hash the same")
+	}
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"go.uber.org/zap"
+)
+
+// refreshTokenLength is the number of random bytes in a refresh token
+const refreshTokenLength = 32
+
+// SessionTokens are handed to the client when a session is opened or
+// refreshed
+type SessionTokens struct {
+	AccessToken      string
+	ExpiresAt        time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// issueTokens signs a new access token for session and starts or continues
+// its refresh token chain. The session then expires with the refresh token,
+// which never outlives the session's maximum lifetime. Sessions of OpenID
+// Connect clients get no roles.
+func (s *Service) issueTokens(ctx context.Context, session *model.Session, username string) (*SessionTokens, error) {
+	var roles, permissions []string
+	var err error
+	if session.ClientID == "" {
+		roles, permissions, err = s.accessFor(ctx, session.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	accessToken, expiresAt, err := s.generateToken(session, username, roles, permissions)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to generate token")
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, errors.NewInternalError("failed to generate refresh token")
+	}
+	refreshExpiresAt := s.capExpiry(session, time.Now().Add(s.config.Security.RefreshTokenExpiry))
+
+	err = s.refreshRepo.Create(ctx, &model.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hashRefreshToken(refreshToken),
+		ExpiresAt: refreshExpiresAt,
+	})
+	if err != nil {
+		return nil, errors.NewInternalError("failed to store refresh token")
+	}
+
+	session.Token = accessToken
+	session.ExpiresAt = refreshExpiresAt
+	if err := s.sessionRepo.Update(ctx, session); err != nil {
+		return nil, errors.NewInternalError("failed to update session")
+	}
+
+	return &SessionTokens{
+		AccessToken:      accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token.
+// Each refresh token can be used once; presenting one that was already
+// rotated ends the whole session, since either copy may be stolen. Sessions
+// past their idle timeout or maximum lifetime are ended instead, and the
+// user has to log in again. Refresh tokens of OpenID Connect clients are
+// refused, those are refreshed with RefreshClientToken.
+func (s *Service) RefreshToken(ctx context.Context, refreshToken string) (*RefreshResponse, error) {
+	return s.refresh(ctx, "", refreshToken)
+}
+
+// RefreshClientToken is RefreshToken for the OpenID Connect client clientID.
+// It only accepts refresh tokens issued to that client.
+func (s *Service) RefreshClientToken(ctx context.Context, clientID, refreshToken string) (*RefreshResponse, error) {
+	return s.refresh(ctx, clientID, refreshToken)
+}
+
+func (s *Service) refresh(ctx context.Context, clientID, refreshToken string) (*RefreshResponse, error) {
+	stored, err := s.refreshRepo.GetByHash(ctx, hashRefreshToken(refreshToken))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewAuthenticationError("invalid refresh token")
+		}
+		return nil, errors.NewInternalError("failed to retrieve refresh token")
+	}
+
+	if stored.RotatedAt != nil {
+		return nil, s.refreshTokenReused(ctx, stored.SessionID)
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, errors.NewSessionExpiredError()
+	}
+
+	session, err := s.sessionRepo.GetByID(ctx, stored.SessionID)
+	if err != nil {
+		return nil, errors.NewAuthenticationError("session not found")
+	}
+	if session.ClientID != clientID {
+		// Checked before rotating, so that another party can't use up the
+		// token
+		return nil, errors.NewAuthenticationError("invalid refresh token")
+	}
+
+	rotated, err := s.refreshRepo.MarkRotated(ctx, stored.ID)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to rotate refresh token")
+	}
+	if !rotated {
+		// A concurrent request used the same token first
+		return nil, s.refreshTokenReused(ctx, stored.SessionID)
+	}
+	if err := s.checkSessionLifetime(session, stored.CreatedAt, time.Now()); err != nil {
+		s.revokeSessionToken(ctx, session)
+		if err := s.sessionRepo.Delete(ctx, session.ID); err != nil && err != sql.ErrNoRows {
+			logger.Warn("Failed to delete session",
+				zap.String("session_id", session.ID),
+				zap.Error(err))
+		}
+		return nil, err
+	}
+	user, err := s.userRepo.GetByID(ctx, session.UserID)
+	if err != nil {
+		return nil, errors.NewAuthenticationError("session not found")
+	}
+
+	// The previous access token is superseded by the new one
+	s.revokeSessionToken(ctx, session)
+
+	tokens, err := s.issueTokens(ctx, session, user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RefreshResponse{
+		Token:            tokens.AccessToken,
+		ExpiresAt:        tokens.ExpiresAt,
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt,
+	}, nil
+}
+
+func (s *Service) refreshTokenReused(ctx context.Context, sessionID string) error {
+	logger.Warn("Refresh token reused, revoking session",
+		zap.String("session_id", sessionID))
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err == nil {
+		s.revokeSessionToken(ctx, session)
+		if err := s.sessionRepo.Delete(ctx, session.ID); err != nil {
+			logger.Warn("Failed to delete session",
+				zap.String("session_id", session.ID),
+				zap.Error(err))
+		}
+	}
+
+	return errors.NewAuthenticationError("refresh token has already been used")
+}
+
+// revokeSessionToken revokes the session's current access token if it has
+// not expired. Failures are logged.
+func (s *Service) revokeSessionToken(ctx context.Context, session *model.Session) {
+	if session.Token == "" {
+		return
+	}
+	claims, err := s.verifyToken(session.Token)
+	if err != nil {
+		return
+	}
+	if err := s.revokeToken(ctx, claims); err != nil {
+		logger.Warn("Failed to revoke session token",
+			zap.String("session_id", session.ID),
+			zap.Error(err))
+	}
+}
+
+func newRefreshToken() (string, error) {
+	b, err := crypto.GenerateRandomBytes(refreshTokenLength)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashRefreshToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
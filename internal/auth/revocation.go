@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	// revocationRetryInterval is the pause before reconnecting a failed
+	// listener
+	revocationRetryInterval = 5 * time.Second
+	// revocationLookupTimeout bounds a check against the repository while
+	// the listener is down
+	revocationLookupTimeout = 2 * time.Second
+)
+
+// revocationStore persists revocations. It is implemented by
+// model.RevokedTokenRepository.
+type revocationStore interface {
+	Create(ctx context.Context, jti string, expiresAt time.Time) error
+	GetActive(ctx context.Context) ([]*model.RevokedToken, error)
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+	Listen(ctx context.Context, ready func() error, notify func(*model.RevokedToken)) error
+}
+
+// Revocations tracks revoked tokens by jti. Checks are answered from the
+// in-memory blacklist. With a repository, revocations are also persisted and
+// reach other instances through Postgres notifications; while the listener
+// is down, checks the cache can't answer go to the repository.
+type Revocations struct {
+	cache     *TokenBlacklist
+	repo      revocationStore // nil keeps revocations in memory only
+	listening atomic.Bool
+}
+
+func NewRevocations(repo *model.RevokedTokenRepository) *Revocations {
+	r := &Revocations{cache: NewTokenBlacklist()}
+	if repo != nil {
+		r.repo = repo
+	}
+	return r
+}
+
+// Revoke revokes the token with the given jti until expiresAt
+func (r *Revocations) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	r.cache.Revoke(jti, expiresAt)
+	if r.repo == nil {
+		return nil
+	}
+	return r.repo.Create(ctx, jti, expiresAt)
+}
+
+// IsRevoked reports whether the token with the given jti has been revoked.
+// A failed lookup counts as revoked.
+func (r *Revocations) IsRevoked(jti string) bool {
+	if r.cache.IsRevoked(jti) {
+		return true
+	}
+	if r.repo == nil || r.listening.Load() {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), revocationLookupTimeout)
+	defer cancel()
+	revoked, err := r.repo.IsRevoked(ctx, jti)
+	if err != nil {
+		logger.Warn("Failed to check token revocation", zap.Error(err))
+		return true
+	}
+	return revoked
+}
+
+// Load fills the cache with the persisted revocations. It runs before the
+// server starts, so no revoked token is accepted while Sync connects.
+func (r *Revocations) Load(ctx context.Context) error {
+	if r.repo == nil {
+		return nil
+	}
+	return r.load(ctx)
+}
+
+// Sync keeps listening for new revocations until ctx is cancelled. The cache
+// is reloaded after every reconnect so that notifications missed in between
+// are not lost.
+func (r *Revocations) Sync(ctx context.Context) {
+	if r.repo == nil {
+		return
+	}
+
+	go func() {
+		for {
+			err := r.repo.Listen(ctx,
+				func() error {
+					if err := r.load(ctx); err != nil {
+						return err
+					}
+					r.listening.Store(true)
+					return nil
+				},
+				func(token *model.RevokedToken) { r.cache.Revoke(token.JTI, token.ExpiresAt) },
+			)
+			r.listening.Store(false)
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Warn("Token revocation listener stopped, retrying", zap.Error(err))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(revocationRetryInterval):
+			}
+		}
+	}()
+}
+
+func (r *Revocations) load(ctx context.Context) error {
+	tokens, err := r.repo.GetActive(ctx)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		r.cache.Revoke(token.JTI, token.ExpiresAt)
+	}
+	return nil
+}
+
+// DeleteExpired removes persisted revocations of tokens that have expired
+func (r *Revocations) DeleteExpired(ctx context.Context) error {
+	if r.repo == nil {
+		return nil
+	}
+	_, err := r.repo.DeleteExpired(ctx)
+	return err
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Permissions checked by the server's own routes. Roles and the permissions
+// they grant are defined in the database.
+const (
+	PermissionRolesRead   = "roles:read"
+	PermissionRolesManage = "roles:manage"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+
+// HasPermission reports whether the token grants permission
+func (c *TokenClaims) HasPermission(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// UserRoles returns the roles and permissions held by a user
+func (s *Service) UserRoles(ctx context.Context, userID string) (*UserRolesResponse, error) {
+	if err := s.checkUserExists(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	roles, permissions, err := s.accessFor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserRolesResponse{
+		UserID:      userID,
+		Roles:       roles,
+		Permissions: permissions,
+	}, nil
+}
+
+// GrantRole gives a user a role. It shows up in the user's access tokens
+// once they are refreshed.
+func (s *Service) GrantRole(ctx context.Context, claims *TokenClaims, userID, role string) (*UserRolesResponse, error) {
+	if err := s.checkUserExists(ctx, userID); err != nil {
+		return nil, err
+	}
+	if !roleNamePattern.MatchString(role) {
+		return nil, errors.NewNotFoundError("role")
+	}
+
+	if err := s.roleRepo.Grant(ctx, userID, role); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("role")
+		}
+		return nil, errors.NewInternalError("failed to grant role")
+	}
+
+	logger.Info("Role granted",
+		zap.String("user_id", userID),
+		zap.String("role", role),
+		zap.String("granted_by", claims.UserID))
+
+	return s.UserRoles(ctx, userID)
+}
+
+// RevokeRole takes a role from a user. The user's access tokens are revoked
+// so that the role stops working now rather than when they expire; refresh
+// tokens keep working and yield tokens without the role.
+func (s *Service) RevokeRole(ctx context.Context, claims *TokenClaims, userID, role string) (*UserRolesResponse, error) {
+	if err := s.checkUserExists(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.roleRepo.Revoke(ctx, userID, role); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("role assignment")
+		}
+		return nil, errors.NewInternalError("failed to revoke role")
+	}
+
+	sessions, err := s.sessionRepo.GetActiveByUserID(ctx, userID)
+	if err != nil {
+		logger.Warn("Failed to list sessions for revocation",
+			zap.String("user_id", userID),
+			zap.Error(err))
+	}
+	for _, session := range sessions {
+		s.revokeSessionToken(ctx, session)
+	}
+
+	logger.Info("Role revoked",
+		zap.String("user_id", userID),
+		zap.String("role", role),
+		zap.String("revoked_by", claims.UserID))
+
+	return s.UserRoles(ctx, userID)
+}
+
+// accessFor loads the roles and permissions to put in a user's tokens
+func (s *Service) accessFor(ctx context.Context, userID string) ([]string, []string, error) {
+	roles, err := s.roleRepo.RolesForUser(ctx, userID)
+	if err != nil {
+		return nil, nil, errors.NewInternalError("failed to retrieve roles")
+	}
+	permissions, err := s.roleRepo.PermissionsForUser(ctx, userID)
+	if err != nil {
+		return nil, nil, errors.NewInternalError("failed to retrieve permissions")
+	}
+	return roles, permissions, nil
+}
+
+// checkUserExists returns a not found error for malformed or unknown user IDs
+func (s *Service) checkUserExists(ctx context.Context, userID string) error {
+	if !uuidPattern.MatchString(userID) {
+		return errors.NewNotFoundError("user")
+	}
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.NewNotFoundError("user")
+		}
+		return errors.NewInternalError("failed to retrieve user")
+	}
+	return nil
+}
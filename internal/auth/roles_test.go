@@ -0,0 +1,32 @@
+package auth
+
+import "testing"
+
+func TestHasPermission(t *testing.T) {
+	claims := &TokenClaims{
+		Roles:       []string{"admin"},
+		Permissions: []string{PermissionRolesRead, PermissionRolesManage},
+	}
+	if !claims.HasPermission(PermissionRolesManage) {
+		t.Error("granted permission denied")
+	}
+	if claims.HasPermission("users:delete") {
+		t.Error("missing permission granted")
+	}
+	if (&TokenClaims{}).HasPermission(PermissionRolesRead) {
+		t.Error("token without permissions granted one")
+	}
+}
+
+func TestRoleNamePattern(t *testing.T) {
+	for _, name := range []string{"admin", "support-tier_2"} {
+		if !roleNamePattern.MatchString(name) {
+			t.Errorf("%q rejected", name)
+		}
+	}
+	for _, name := range []string{"", "Admin", "1admin", "admin role"} {
+		if roleNamePattern.MatchString(name) {
+			t.Errorf("%q accepted", name)
+		}
+	}
+}
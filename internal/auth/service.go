@@ -4,35 +4,153 @@ import (
 	"context"
 	"database/sql"
 	"encoding/hex"
+	"fmt"
 	"math/big"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/francisco3ferraz/zk-auth/internal/config"
 	"github.com/francisco3ferraz/zk-auth/internal/crypto"
 	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
 	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"go.uber.org/zap"
 )
 
 type Service struct {
-	srp          *crypto.SRP
+	srp          *crypto.SRP                  // Parameters for newly created verifiers
+	srps         map[srpParams]*crypto.SRP    // One instance per supported group and hash
+	kdf          crypto.KDFParams             // KDF for newly created verifiers
+	opaque       *crypto.OPAQUEServer         // nil when OPAQUE is disabled
+	fakeSecret   []byte                       // Key for credentials of unknown users
+	fakeParams   atomic.Pointer[parameterMix] // Parameters of real accounts, for fake ones
+	mfaKey       []byte                       // Encrypts TOTP secrets, nil when TOTP is disabled
+	keyring      *Keyring                     // Signs and verifies access tokens
 	userRepo     *model.UserRepository
 	sessionRepo  *model.SessionRepository
+	opaqueRepo   *model.OPAQUERepository
+	refreshRepo  *model.RefreshTokenRepository
+	totpRepo     *model.TOTPRepository
+	recoveryRepo *model.RecoveryCodeRepository
+	profileRepo  *model.ProfileRepository
+	roleRepo     *model.RoleRepository
+	failureRepo  loginFailureStore
 	config       *config.Config
-	challenges   map[string]*AuthChallenge // In-memory challenge storage
-	challengesMu sync.RWMutex              // Protects challenges map
-	blacklist    *TokenBlacklist           // Token revocation list
+	challenges   ChallengeStore // Pending challenges
+	revocations  *Revocations   // Revoked tokens by jti
+	activity     *activityCache // Recent last_seen_at writes
+	kdfSlots     chan struct{}  // Bounds concurrent server side KDF runs
 }
 
-func NewService(userRepo *model.UserRepository, sessionRepo *model.SessionRepository, cfg *config.Config) *Service {
-	return &Service{
-		srp:         crypto.NewSRP(),
-		userRepo:    userRepo,
-		sessionRepo: sessionRepo,
-		config:      cfg,
-		challenges:  make(map[string]*AuthChallenge),
-		blacklist:   NewTokenBlacklist(),
+// Repositories groups the stores the service reads and writes
+type Repositories struct {
+	Users    *model.UserRepository
+	Sessions *model.SessionRepository
+	OPAQUE   *model.OPAQUERepository
+	Refresh  *model.RefreshTokenRepository
+	TOTP     *model.TOTPRepository
+	Recovery *model.RecoveryCodeRepository
+	Profiles *model.ProfileRepository
+	Roles    *model.RoleRepository
+	// LoginFailures counts failed logins for backoff and lockout
+	LoginFailures *model.LoginFailureRepository
+	// Challenges defaults to an in-memory store when nil
+	Challenges ChallengeStore
+	// RevokedTokens persists revocations; when nil they are kept in memory
+	RevokedTokens *model.RevokedTokenRepository
+	// SigningKeys holds rotated keys; when nil only the configured key is used
+	SigningKeys *model.SigningKeyRepository
+}
+
+func NewService(repos Repositories, cfg *config.Config) (*Service, error) {
+	group, err := crypto.GroupByBits(cfg.SRP.KeyLength)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRP_KEY_LENGTH: %w", err)
+	}
+	hash, err := crypto.ParseHashAlgorithm(cfg.SRP.HashAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRP_HASH_ALGORITHM: %w", err)
+	}
+	mode, err := crypto.ParseMode(cfg.SRP.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRP_MODE: %w", err)
+	}
+	kdf, err := kdfFromConfig(&cfg.SRP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRP KDF configuration: %w", err)
+	}
+
+	signingKey, err := signingKeyFromConfig(&cfg.Security)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT signing key: %w", err)
+	}
+	// Only required once admin rotate-key has stored a key, which Load checks
+	var signingKeyEncryption []byte
+	if cfg.Security.SigningKeyEncryptionKey != "" {
+		signingKeyEncryption, err = SigningKeyEncryptionKey(&cfg.Security)
+		if err != nil {
+			return nil, err
+		}
+	}
+	keyring := NewKeyring(signingKey, repos.SigningKeys, signingKeyEncryption, cfg.Security.JWTExpiry)
+	if err := keyring.Load(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	revocations := NewRevocations(repos.RevokedTokens)
+	if err := revocations.Load(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to load token revocations: %w", err)
+	}
+
+	opaque, err := opaqueFromConfig(&cfg.OPAQUE)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OPAQUE configuration: %w", err)
+	}
+
+	mfaKey, err := mfaKeyFromConfig(&cfg.MFA)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MFA configuration: %w", err)
+	}
+
+	challenges := repos.Challenges
+	if challenges == nil {
+		challenges = NewMemoryChallengeStore()
 	}
+
+	srps := make(map[srpParams]*crypto.SRP)
+	for _, g := range crypto.Groups() {
+		for _, h := range crypto.HashAlgorithms() {
+			for _, m := range crypto.Modes() {
+				srps[srpParams{group: g.Bits, hash: h, mode: m}] = crypto.NewSRP(g, h, m)
+			}
+		}
+	}
+
+	s := &Service{
+		srp:          srps[srpParams{group: group.Bits, hash: hash, mode: mode}],
+		srps:         srps,
+		kdf:          kdf,
+		opaque:       opaque,
+		fakeSecret:   fakeSecretFromConfig(&cfg.Security),
+		mfaKey:       mfaKey,
+		keyring:      keyring,
+		userRepo:     repos.Users,
+		sessionRepo:  repos.Sessions,
+		opaqueRepo:   repos.OPAQUE,
+		refreshRepo:  repos.Refresh,
+		totpRepo:     repos.TOTP,
+		recoveryRepo: repos.Recovery,
+		profileRepo:  repos.Profiles,
+		roleRepo:     repos.Roles,
+		failureRepo:  repos.LoginFailures,
+		config:       cfg,
+		challenges:   challenges,
+		revocations:  revocations,
+		activity:     newActivityCache(),
+		kdfSlots:     make(chan struct{}, maxConcurrentKDF),
+	}
+	s.refreshFakeParams(context.Background())
+	return s, nil
 }
 
 // StartCleanup starts a background goroutine that periodically removes expired challenges.
@@ -46,193 +164,414 @@ func (s *Service) StartCleanup(ctx context.Context) {
 				ticker.Stop()
 				return
 			case <-ticker.C:
-				s.cleanupExpiredChallenges()
+				s.cleanupExpiredChallenges(ctx)
+				s.cleanupExpiredRevocations(ctx)
+				s.cleanupStaleLoginFailures(ctx)
+				s.cleanupActivityCache()
+				s.purgeDeletedAccounts(ctx)
+				s.refreshFakeParams(ctx)
 			}
 		}
 	}()
 }
 
-func (s *Service) cleanupExpiredChallenges() {
-	s.challengesMu.Lock()
-	defer s.challengesMu.Unlock()
+func (s *Service) cleanupExpiredChallenges(ctx context.Context) {
+	if _, err := s.challenges.DeleteExpired(ctx); err != nil {
+		logger.Warn("Failed to delete expired challenges", zap.Error(err))
+	}
+}
 
-	now := time.Now()
-	for id, challenge := range s.challenges {
-		if now.Sub(challenge.CreatedAt) > 5*time.Minute {
-			delete(s.challenges, id)
-		}
+func (s *Service) cleanupExpiredRevocations(ctx context.Context) {
+	if err := s.revocations.DeleteExpired(ctx); err != nil {
+		logger.Warn("Failed to delete expired revocations", zap.Error(err))
 	}
 }
 
+// StartRevocationSync keeps revocations made by other instances in the local
+// cache until ctx is cancelled
+func (s *Service) StartRevocationSync(ctx context.Context) {
+	s.revocations.Sync(ctx)
+}
+
+// StartKeyringSync picks up rotated signing keys until ctx is cancelled
+func (s *Service) StartKeyringSync(ctx context.Context) {
+	s.keyring.Sync(ctx)
+}
+
 func (s *Service) Register(ctx context.Context, req *RegisterRequest) (*RegisterResponse, error) {
-	exists, err := s.userRepo.ExistsByUsername(ctx, req.Username)
-	if err != nil {
-		return nil, errors.NewInternalError("failed to check user existence")
-	}
-	if exists {
-		return nil, errors.NewConflictError("username already exists")
+	start := time.Now()
+	defer s.padResponseTime(start)
+
+	user := &model.User{Username: req.Username}
+
+	if req.Verifier != "" {
+		if err := s.applyVerifierCredentials(user, &req.VerifierCredentials); err != nil {
+			return nil, err
+		}
+	} else if !s.config.Security.AllowPlaintextPasswords {
+		return nil, errors.NewValidationError("password registration is disabled, submit a salt and verifier instead")
 	}
 
-	salt, err := s.srp.GenerateSalt()
-	if err != nil {
-		return nil, errors.NewInternalError("failed to generate salt")
+	// Legacy path: the server derives the verifier from the plaintext password.
+	// This runs before the existence check so both outcomes cost the same.
+	if user.Verifier == nil {
+		salt, err := s.srp.GenerateSalt()
+		if err != nil {
+			return nil, errors.NewInternalError("failed to generate salt")
+		}
+
+		verifier, err := s.computeVerifier(ctx, s.srp, req.Username, req.Password, salt, s.kdf)
+		if err != nil {
+			return nil, err
+		}
+
+		user.Salt = salt
+		user.Verifier = verifier.Bytes()
+		user.SRPGroup = s.srp.Group.Bits
+		user.HashAlgorithm = string(s.srp.Hash)
+		user.SRPMode = string(s.srp.Mode)
+		user.KDF = s.kdf
 	}
 
-	verifier, err := s.srp.ComputeVerifier(req.Username, req.Password, salt)
+	taken, err := s.usernameTaken(ctx, req.Username)
 	if err != nil {
-		return nil, errors.NewInternalError("failed to compute verifier")
+		return nil, err
 	}
-
-	user := &model.User{
-		Username: req.Username,
-		Salt:     salt,
-		Verifier: verifier.Bytes(),
+	if taken {
+		// Answer as if the account was created, a conflict would reveal it
+		user.ID = s.fakeUserID(req.Username)
+		return registeredResponse(user), nil
 	}
 
 	if err := s.userRepo.Create(ctx, user); err != nil {
+		if err == model.ErrUsernameTaken {
+			// Registered concurrently since the check above
+			user.ID = s.fakeUserID(req.Username)
+			return registeredResponse(user), nil
+		}
 		return nil, errors.NewInternalError("failed to create user")
 	}
 
+	return registeredResponse(user), nil
+}
+
+func registeredResponse(user *model.User) *RegisterResponse {
 	return &RegisterResponse{
 		UserID:   user.ID,
 		Username: user.Username,
 		Message:  "User registered successfully",
-	}, nil
+	}
 }
 
 func (s *Service) StartChallenge(ctx context.Context, req *ChallengeRequest) (*ChallengeResponse, error) {
-	clientABytes, err := hex.DecodeString(req.ClientA)
+	start := time.Now()
+	defer s.padResponseTime(start)
+
+	clientA, err := parseClientA(req.ClientA)
+	if err != nil {
+		return nil, err
+	}
+	deviceName, err := normalizeDeviceName(req.DeviceName)
 	if err != nil {
-		return nil, errors.NewBadRequestError("invalid client_a format")
+		return nil, err
 	}
-	clientA := new(big.Int).SetBytes(clientABytes)
 
-	if clientA.Sign() == 0 {
-		return nil, errors.NewBadRequestError("invalid client_a value")
+	if err := s.checkLoginAllowed(ctx, req.Username); err != nil {
+		return nil, err
 	}
 
 	user, err := s.userRepo.GetByUsername(ctx, req.Username)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			// Don't reveal whether user exists
-			return nil, errors.NewAuthenticationError("invalid credentials")
-		}
+	if err != nil && err != sql.ErrNoRows {
 		return nil, errors.NewInternalError("failed to retrieve user")
 	}
+	if err == sql.ErrNoRows || len(user.Verifier) == 0 {
+		// Don't reveal whether the user exists: the challenge for fake
+		// credentials looks the same and fails at verification
+		user = s.fakeUser(req.Username)
+	}
 
-	verifier := new(big.Int).SetBytes(user.Verifier)
-	serverSecret, serverB, err := s.srp.GenerateServerKeys(verifier)
+	challenge, err := s.newChallenge(user, clientA)
 	if err != nil {
-		return nil, errors.NewInternalError("failed to generate server keys")
+		return nil, err
+	}
+
+	if user.ID == "" {
+		challenge.SessionID, err = newRandomID()
+		if err != nil {
+			return nil, errors.NewInternalError("failed to generate session id")
+		}
+		if err := s.putChallenge(ctx, challenge); err != nil {
+			return nil, err
+		}
+		return s.challengeResponse(challenge, user), nil
 	}
 
 	session := &model.Session{
 		UserID:       user.ID,
-		Challenge:    clientA.Bytes(),
-		ServerSecret: serverSecret.Bytes(),
-		ExpiresAt:    time.Now().Add(5 * time.Minute),
+		ServerSecret: challenge.ServerSecret.Bytes(),
+		DeviceName:   deviceName,
+		ExpiresAt:    time.Now().Add(challengeTTL),
+	}
+	if clientA != nil {
+		session.Challenge = clientA.Bytes()
 	}
+	recordClient(ctx, session)
 
 	if err := s.sessionRepo.Create(ctx, session); err != nil {
 		return nil, errors.NewInternalError("failed to create session")
 	}
 
-	s.challengesMu.Lock()
-	s.challenges[session.ID] = &AuthChallenge{
-		SessionID:    session.ID,
-		Username:     user.Username,
-		ClientA:      clientA,
-		ServerB:      serverB,
-		ServerSecret: serverSecret,
-		Salt:         user.Salt,
-		Verifier:     user.Verifier,
-		CreatedAt:    time.Now(),
+	challenge.SessionID = session.ID
+	if err := s.putChallenge(ctx, challenge); err != nil {
+		return nil, err
 	}
-	s.challengesMu.Unlock()
 
+	return s.challengeResponse(challenge, user), nil
+}
+
+func (s *Service) challengeResponse(challenge *AuthChallenge, user *model.User) *ChallengeResponse {
 	return &ChallengeResponse{
-		SessionID: session.ID,
-		Salt:      hex.EncodeToString(user.Salt),
-		ServerB:   hex.EncodeToString(serverB.Bytes()),
-	}, nil
+		SessionID:     challenge.SessionID,
+		Salt:          hex.EncodeToString(user.Salt),
+		ServerB:       hex.EncodeToString(challenge.ServerB.Bytes()),
+		SRPGroup:      challenge.SRPGroup,
+		HashAlgorithm: challenge.HashAlgorithm,
+		SRPMode:       challenge.SRPMode,
+		KDF:           user.KDF,
+	}
 }
 
 func (s *Service) VerifyChallenge(ctx context.Context, req *VerifyRequest) (*VerifyResponse, error) {
-	s.challengesMu.Lock()
-	challenge, exists := s.challenges[req.SessionID]
-	if !exists {
-		s.challengesMu.Unlock()
+	// Check upgraded credentials before the challenge is consumed
+	var upgraded *model.User
+	if req.Upgrade != nil {
+		upgraded = &model.User{}
+		if err := s.applyVerifierCredentials(upgraded, req.Upgrade); err != nil {
+			return nil, err
+		}
+		if s.upgradeFor(upgraded) != nil {
+			return nil, errors.NewValidationError("upgraded credentials do not meet the current parameters")
+		}
+	}
+
+	challenge, err := s.takeChallenge(ctx, req.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.BoundSessionID != "" || challenge.OPAQUE != nil || challenge.PendingSessionID != "" {
+		// Re-authentication, OPAQUE challenges and MFA tickets are answered
+		// elsewhere
 		return nil, errors.NewAuthenticationError("invalid or expired session")
 	}
-	delete(s.challenges, req.SessionID)
-	s.challengesMu.Unlock()
+	// Checked again here, since challenges started before a lock could
+	// otherwise still be answered
+	attempt, err := s.beginLoginAttempt(ctx, challenge.Username)
+	if err != nil {
+		return nil, err
+	}
 
-	if time.Since(challenge.CreatedAt) > 5*time.Minute {
-		return nil, errors.NewSessionExpiredError()
+	serverProof, err := s.verifyChallengeProof(challenge, req.ClientA, req.ClientProof)
+	if err == nil && challenge.UserID == "" {
+		// Issued for an unknown username
+		err = errors.NewAuthenticationError("invalid credentials")
+	}
+	if err != nil {
+		if failedProof(err) {
+			s.loginFailed(challenge.Username, attempt)
+		}
+		return nil, err
 	}
 
-	clientProof, err := hex.DecodeString(req.ClientProof)
+	mfa, err := s.mfaRequired(ctx, challenge.UserID)
 	if err != nil {
-		return nil, errors.NewBadRequestError("invalid client_proof format")
+		return nil, err
+	}
+	if mfa {
+		// The upgrade and the failure reset wait for the second factor as
+		// well
+		ticket, err := s.startMFA(ctx, challenge, req.Upgrade)
+		if err != nil {
+			return nil, err
+		}
+		return &VerifyResponse{
+			ServerProof: hex.EncodeToString(serverProof),
+			MFAPending:  true,
+			MFATicket:   ticket,
+		}, nil
 	}
 
-	// Compute u = H(A | B)
-	u := s.srp.ComputeU(challenge.ClientA, challenge.ServerB)
+	s.resetLoginFailures(ctx, challenge.Username)
 
-	verifier := new(big.Int).SetBytes(challenge.Verifier)
-	serverKey, err := s.srp.ComputeServerSessionKey(
-		challenge.ClientA,
-		challenge.ServerSecret,
-		verifier,
-		u,
-	)
+	tokens, err := s.openSession(ctx, challenge.SessionID, challenge.Username, challenge.ClientA.Bytes())
 	if err != nil {
-		return nil, errors.NewInternalError("failed to compute session key")
+		return nil, err
+	}
+
+	resp := &VerifyResponse{
+		Token:            tokens.AccessToken,
+		ServerProof:      hex.EncodeToString(serverProof),
+		ExpiresAt:        tokens.ExpiresAt,
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt,
 	}
 
-	if !s.srp.VerifyClientProof(
-		challenge.Username,
-		challenge.Salt,
-		challenge.ClientA,
-		challenge.ServerB,
-		serverKey,
-		clientProof,
-	) {
-		return nil, errors.NewAuthenticationError("invalid credentials")
+	if upgraded != nil {
+		resp.Upgraded = s.upgradeVerifier(ctx, challenge.UserID, upgraded)
 	}
-	serverProof := s.srp.ComputeServerProof(challenge.ClientA, clientProof, serverKey)
+	if !resp.Upgraded {
+		resp.Upgrade = s.upgradeHint(ctx, challenge.UserID)
+	}
+
+	return resp, nil
+}
 
-	token, expiresAt, err := s.generateToken(challenge.SessionID, challenge.Username)
+// ClientGrant describes the login an OpenID Connect client redeemed a code
+// for
+type ClientGrant struct {
+	UserID   string
+	ClientID string
+	// AuthTime is when the user logged in
+	AuthTime time.Time
+	// Client is where the user authorized the client from, may be nil
+	Client *ClientInfo
+}
+
+// CreateSession opens a session for an OpenID Connect client on behalf of a
+// user who has already authenticated, and issues its tokens. The session's
+// lifetime starts when the user logged in, not when the code was redeemed.
+func (s *Service) CreateSession(ctx context.Context, grant *ClientGrant) (*SessionTokens, error) {
+	user, err := s.userRepo.GetByID(ctx, grant.UserID)
 	if err != nil {
-		return nil, errors.NewInternalError("failed to generate token")
+		if err == sql.ErrNoRows {
+			return nil, errors.NewAuthenticationError("user not found")
+		}
+		return nil, errors.NewInternalError("failed to retrieve user")
+	}
+
+	session := &model.Session{
+		UserID:    user.ID,
+		ClientID:  grant.ClientID,
+		ExpiresAt: time.Now().Add(s.config.Security.RefreshTokenExpiry),
+		CreatedAt: grant.AuthTime,
+	}
+	if grant.Client != nil {
+		setClient(session, grant.Client)
 	}
+	now := time.Now()
+	if err := s.checkSessionLifetime(session, now, now); err != nil {
+		return nil, err
+	}
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, errors.NewInternalError("failed to create session")
+	}
+
+	return s.issueTokens(ctx, session, user.Username)
+}
 
-	session, err := s.sessionRepo.GetByID(ctx, challenge.SessionID)
+// openSession issues the tokens for a session whose handshake has completed.
+// challenge is the client's public handshake value kept with the session.
+// The client recorded is the one that completed the login.
+func (s *Service) openSession(ctx context.Context, sessionID, username string, challenge []byte) (*SessionTokens, error) {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
 	if err != nil {
 		return nil, errors.NewInternalError("failed to retrieve session")
 	}
-
-	session.Token = token
-	session.ExpiresAt = expiresAt
-	if err := s.sessionRepo.Update(ctx, session); err != nil {
+	session.CreatedAt, err = s.sessionRepo.MarkLoggedIn(ctx, sessionID)
+	if err != nil {
 		return nil, errors.NewInternalError("failed to update session")
 	}
 
-	return &VerifyResponse{
-		Token:       token,
-		ServerProof: hex.EncodeToString(serverProof),
-		ExpiresAt:   expiresAt,
+	session.Challenge = challenge
+	recordClient(ctx, session)
+	return s.issueTokens(ctx, session, username)
+}
+
+// StartReauthChallenge issues an SRP challenge bound to the caller's session.
+// Answering it proves the caller still knows the password, which sensitive
+// operations such as changing the password require on top of a bearer token.
+func (s *Service) StartReauthChallenge(ctx context.Context, claims *TokenClaims, req *ReauthChallengeRequest) (*ReauthChallengeResponse, error) {
+	clientA, err := parseClientA(req.ClientA)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkLoginAllowed(ctx, claims.Username); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByUsername(ctx, claims.Username)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to retrieve user")
+	}
+
+	challenge, err := s.newChallenge(user, clientA)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge.SessionID, err = newRandomID()
+	if err != nil {
+		return nil, errors.NewInternalError("failed to generate challenge id")
+	}
+	challenge.BoundSessionID = claims.SessionID
+	if err := s.putChallenge(ctx, challenge); err != nil {
+		return nil, err
+	}
+
+	return &ReauthChallengeResponse{
+		ChallengeID:   challenge.SessionID,
+		Salt:          hex.EncodeToString(user.Salt),
+		ServerB:       hex.EncodeToString(challenge.ServerB.Bytes()),
+		SRPGroup:      challenge.SRPGroup,
+		HashAlgorithm: challenge.HashAlgorithm,
+		SRPMode:       challenge.SRPMode,
+		KDF:           user.KDF,
 	}, nil
 }
 
+// verifyReauthProof consumes a re-authentication challenge issued to the
+// session in claims and returns the challenge together with the server proof.
+// OPAQUE handshakes have no server proof, the client checked the server at
+// KE2 already.
+func (s *Service) verifyReauthProof(ctx context.Context, claims *TokenClaims, proof *ReauthProof) (*AuthChallenge, []byte, error) {
+	challenge, err := s.takeChallenge(ctx, proof.ChallengeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge.BoundSessionID == "" || challenge.BoundSessionID != claims.SessionID {
+		return nil, nil, errors.NewAuthenticationError("invalid or expired challenge")
+	}
+	attempt, err := s.beginLoginAttempt(ctx, challenge.Username)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var serverProof []byte
+	if challenge.OPAQUE != nil {
+		err = verifyOPAQUEProof(challenge, proof.KE3)
+	} else {
+		serverProof, err = s.verifyChallengeProof(challenge, proof.ClientA, proof.ClientProof)
+	}
+	if err != nil {
+		if failedProof(err) {
+			s.loginFailed(challenge.Username, attempt)
+		}
+		return nil, nil, err
+	}
+	s.resetLoginFailures(ctx, challenge.Username)
+
+	return challenge, serverProof, nil
+}
+
 func (s *Service) Logout(ctx context.Context, token string) (*LogoutResponse, error) {
 	claims, err := s.verifyToken(token)
 	if err != nil {
 		return nil, errors.NewAuthenticationError("invalid token")
 	}
 
-	// Revoke the token
-	s.blacklist.Revoke(token, claims.ExpiresAt.Time)
+	if err := s.revokeToken(ctx, claims); err != nil {
+		return nil, err
+	}
 
 	if err := s.sessionRepo.Delete(ctx, claims.SessionID); err != nil {
 		return nil, errors.NewInternalError("failed to delete session")
@@ -243,56 +582,75 @@ func (s *Service) Logout(ctx context.Context, token string) (*LogoutResponse, er
 	}, nil
 }
 
+// ValidateToken validates a first-party access token. Tokens issued to
+// OpenID Connect clients are rejected, they are only good for the userinfo
+// endpoint.
 func (s *Service) ValidateToken(token string) (*TokenClaims, error) {
-	// Check if token has been revoked
-	if s.blacklist.IsRevoked(token) {
-		return nil, errors.NewAuthenticationError("token has been revoked")
+	claims, err := s.ValidateClientToken(token)
+	if err != nil {
+		return nil, err
 	}
-	return s.verifyToken(token)
+	if claims.ClientID != "" {
+		return nil, errors.NewAuthenticationError("token was issued to a client")
+	}
+	return claims, nil
 }
 
-func (s *Service) RefreshToken(ctx context.Context, token string) (*RefreshResponse, error) {
+// ValidateClientToken validates an access token whether it is first-party or
+// was issued to an OpenID Connect client
+func (s *Service) ValidateClientToken(token string) (*TokenClaims, error) {
 	claims, err := s.verifyToken(token)
 	if err != nil {
-		return nil, errors.NewAuthenticationError("invalid or expired token")
+		return nil, err
 	}
 
-	// Verify the session still exists and is valid
-	session, err := s.sessionRepo.GetByID(ctx, claims.SessionID)
-	if err != nil {
-		return nil, errors.NewAuthenticationError("session not found")
+	// Tokens without a jti predate revocation tracking and can't be revoked
+	if claims.ID == "" {
+		return nil, errors.NewAuthenticationError("invalid token")
 	}
-
-	// Generate new token with fresh expiry
-	newToken, expiresAt, err := s.generateToken(session.ID, claims.Username)
-	if err != nil {
-		return nil, errors.NewInternalError("failed to generate token")
+	if s.revocations.IsRevoked(claims.ID) {
+		return nil, errors.NewAuthenticationError("token has been revoked")
 	}
-
-	// Update session with new token
-	session.Token = newToken
-	session.ExpiresAt = expiresAt
-	if err := s.sessionRepo.Update(ctx, session); err != nil {
-		return nil, errors.NewInternalError("failed to update session")
+	if err := s.checkTokenLifetime(claims, time.Now()); err != nil {
+		return nil, err
 	}
+	return claims, nil
+}
 
-	return &RefreshResponse{
-		Token:     newToken,
-		ExpiresAt: expiresAt,
-	}, nil
+func (s *Service) revokeToken(ctx context.Context, claims *TokenClaims) error {
+	if err := s.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return errors.NewInternalError("failed to revoke token")
+	}
+	return nil
 }
 
 func (s *Service) ChangePassword(ctx context.Context, claims *TokenClaims, req *ChangePasswordRequest) (*ChangePasswordResponse, error) {
+	if req.ChallengeID != "" && req.Record != "" {
+		return s.changeOPAQUERecord(ctx, claims, req)
+	}
+	if req.ChallengeID != "" {
+		return s.changeVerifier(ctx, claims, req)
+	}
+
+	if !s.config.Security.AllowPlaintextPasswords {
+		return nil, errors.NewValidationError("plaintext password change is disabled, submit a re-authentication proof and new verifier instead")
+	}
+
 	// Get user from database
 	user, err := s.userRepo.GetByUsername(ctx, claims.Username)
 	if err != nil {
 		return nil, errors.NewInternalError("failed to retrieve user")
 	}
 
+	userSRP, err := s.srpFor(user.SRPGroup, user.HashAlgorithm, user.SRPMode)
+	if err != nil {
+		return nil, errors.NewInternalError("unsupported SRP group")
+	}
+
 	// Verify current password by computing verifier and comparing
-	currentVerifier, err := s.srp.ComputeVerifier(user.Username, req.CurrentPassword, user.Salt)
+	currentVerifier, err := s.computeVerifier(ctx, userSRP, user.Username, req.CurrentPassword, user.Salt, user.KDF)
 	if err != nil {
-		return nil, errors.NewInternalError("failed to verify password")
+		return nil, err
 	}
 
 	storedVerifier := new(big.Int).SetBytes(user.Verifier)
@@ -311,24 +669,96 @@ func (s *Service) ChangePassword(ctx context.Context, claims *TokenClaims, req *
 		return nil, errors.NewInternalError("failed to generate salt")
 	}
 
-	newVerifier, err := s.srp.ComputeVerifier(user.Username, req.NewPassword, newSalt)
+	newVerifier, err := s.computeVerifier(ctx, s.srp, user.Username, req.NewPassword, newSalt, s.kdf)
 	if err != nil {
-		return nil, errors.NewInternalError("failed to compute verifier")
+		return nil, err
 	}
 
 	// Update user with new credentials
 	user.Salt = newSalt
 	user.Verifier = newVerifier.Bytes()
+	user.SRPGroup = s.srp.Group.Bits
+	user.HashAlgorithm = string(s.srp.Hash)
+	user.SRPMode = string(s.srp.Mode)
+	user.KDF = s.kdf
 	if err := s.userRepo.Update(ctx, user); err != nil {
 		return nil, errors.NewInternalError("failed to update password")
 	}
 
-	// Invalidate all other sessions for security
-	if err := s.sessionRepo.DeleteByUserID(ctx, user.ID); err != nil {
-		// Log error but don't fail the request
-	}
+	s.revokeOtherSessions(ctx, user.ID, claims.SessionID)
 
 	return &ChangePasswordResponse{
 		Message: "Password changed successfully",
 	}, nil
 }
+
+// changeVerifier replaces the user's salt and verifier after a fresh SRP
+// proof of the current password, so no password crosses the wire.
+func (s *Service) changeVerifier(ctx context.Context, claims *TokenClaims, req *ChangePasswordRequest) (*ChangePasswordResponse, error) {
+	// Check the new credentials before the challenge is consumed
+	updated := &model.User{}
+	if err := s.applyVerifierCredentials(updated, &req.VerifierCredentials); err != nil {
+		return nil, err
+	}
+
+	challenge, serverProof, err := s.verifyReauthProof(ctx, claims, &req.ReauthProof)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.OPAQUE != nil {
+		return nil, errors.NewValidationError("OPAQUE accounts change their password with a new record")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to retrieve user")
+	}
+
+	user.Salt = updated.Salt
+	user.Verifier = updated.Verifier
+	user.SRPGroup = updated.SRPGroup
+	user.HashAlgorithm = updated.HashAlgorithm
+	user.SRPMode = updated.SRPMode
+	user.KDF = updated.KDF
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, errors.NewInternalError("failed to update password")
+	}
+
+	s.revokeOtherSessions(ctx, user.ID, claims.SessionID)
+
+	return &ChangePasswordResponse{
+		Message:     "Password changed successfully",
+		ServerProof: hex.EncodeToString(serverProof),
+	}, nil
+}
+
+// revokeOtherSessions revokes the tokens of every session of the user except
+// keepSessionID, which may be empty, and deletes those sessions. Failures are
+// logged rather than returned because the caller's primary operation has
+// already succeeded.
+func (s *Service) revokeOtherSessions(ctx context.Context, userID, keepSessionID string) {
+	sessions, err := s.sessionRepo.GetActiveByUserID(ctx, userID)
+	if err != nil {
+		logger.Warn("Failed to list sessions for revocation",
+			zap.String("user_id", userID),
+			zap.Error(err))
+	}
+
+	for _, session := range sessions {
+		if session.ID == keepSessionID || session.Token == "" {
+			continue
+		}
+		s.revokeSessionToken(ctx, session)
+	}
+
+	if keepSessionID == "" {
+		err = s.sessionRepo.DeleteByUserID(ctx, userID)
+	} else {
+		err = s.sessionRepo.DeleteByUserIDExcept(ctx, userID, keepSessionID)
+	}
+	if err != nil {
+		logger.Warn("Failed to delete sessions",
+			zap.String("user_id", userID),
+			zap.Error(err))
+	}
+}
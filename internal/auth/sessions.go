@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"go.uber.org/zap"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ListSessions returns the caller's open sessions, newest first. Sessions
+// whose login has not completed are left out.
+func (s *Service) ListSessions(ctx context.Context, claims *TokenClaims) (*SessionListResponse, error) {
+	sessions, err := s.sessionRepo.GetActiveByUserID(ctx, claims.UserID)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to list sessions")
+	}
+
+	resp := &SessionListResponse{Sessions: []SessionInfo{}}
+	for _, session := range sessions {
+		if session.Token == "" {
+			continue
+		}
+		resp.Sessions = append(resp.Sessions, SessionInfo{
+			ID:         session.ID,
+			ClientID:   session.ClientID,
+			IPAddress:  session.IPAddress,
+			UserAgent:  session.UserAgent,
+			DeviceName: session.DeviceName,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == claims.SessionID,
+		})
+	}
+
+	return resp, nil
+}
+
+// RevokeSession ends one of the caller's sessions, which may be the current
+// one, and revokes its access token
+func (s *Service) RevokeSession(ctx context.Context, claims *TokenClaims, sessionID string) (*RevokeSessionsResponse, error) {
+	if !uuidPattern.MatchString(sessionID) {
+		return nil, errors.NewNotFoundError("session")
+	}
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("session")
+		}
+		return nil, errors.NewInternalError("failed to retrieve session")
+	}
+	if session.UserID != claims.UserID {
+		return nil, errors.NewNotFoundError("session")
+	}
+
+	s.revokeSessionToken(ctx, session)
+	if err := s.sessionRepo.Delete(ctx, session.ID); err != nil && err != sql.ErrNoRows {
+		return nil, errors.NewInternalError("failed to delete session")
+	}
+
+	logger.Info("Session revoked",
+		zap.String("user_id", claims.UserID),
+		zap.String("session_id", session.ID))
+
+	return &RevokeSessionsResponse{
+		Message: "Session revoked",
+	}, nil
+}
+
+// RevokeOtherSessions ends every session of the caller except the current one
+func (s *Service) RevokeOtherSessions(ctx context.Context, claims *TokenClaims) (*RevokeSessionsResponse, error) {
+	s.revokeOtherSessions(ctx, claims.UserID, claims.SessionID)
+
+	return &RevokeSessionsResponse{
+		Message: "Other sessions revoked",
+	}, nil
+}
@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/config"
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	// mfaTicketTTL bounds how long a user has to enter the second factor
+	// after the first one succeeded
+	mfaTicketTTL = 5 * time.Minute
+	// maxTOTPAttempts is the number of wrong codes a ticket survives
+	maxTOTPAttempts = 5
+	// totpSkew accepts codes from one step before or after the current one
+	totpSkew = 1
+)
+
+// EnrollTOTP generates a TOTP secret for the caller. It only becomes a second
+// factor once confirmed with a code; enrolling again before that replaces it.
+func (s *Service) EnrollTOTP(ctx context.Context, claims *TokenClaims) (*TOTPEnrollResponse, error) {
+	if s.mfaKey == nil {
+		return nil, errTOTPDisabled()
+	}
+
+	secret, err := crypto.GenerateRandomBytes(crypto.TOTPSecretLength)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to generate TOTP secret")
+	}
+	encrypted, err := crypto.Seal(s.mfaKey, secret, []byte(claims.UserID))
+	if err != nil {
+		return nil, errors.NewInternalError("failed to encrypt TOTP secret")
+	}
+
+	err = s.totpRepo.SetPending(ctx, &model.TOTPCredential{
+		UserID:          claims.UserID,
+		SecretEncrypted: encrypted,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewConflictError("TOTP is already enabled")
+		}
+		return nil, errors.NewInternalError("failed to store TOTP secret")
+	}
+
+	return &TOTPEnrollResponse{
+		Secret:          crypto.EncodeTOTPSecret(secret),
+		ProvisioningURI: crypto.TOTPProvisioningURI(secret, s.config.MFA.Issuer, claims.Username),
+	}, nil
+}
+
+// ConfirmTOTP enables the enrolled secret once the caller proves their
+// authenticator produces its codes
+func (s *Service) ConfirmTOTP(ctx context.Context, claims *TokenClaims, req *TOTPConfirmRequest) (*TOTPConfirmResponse, error) {
+	if s.mfaKey == nil {
+		return nil, errTOTPDisabled()
+	}
+
+	credential, err := s.totpRepo.GetByUserID(ctx, claims.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewNotFoundError("TOTP enrollment")
+		}
+		return nil, errors.NewInternalError("failed to retrieve TOTP secret")
+	}
+	if credential.ConfirmedAt != nil {
+		return nil, errors.NewConflictError("TOTP is already enabled")
+	}
+
+	if err := s.checkTOTP(ctx, credential, req.Code, true); err != nil {
+		return nil, err
+	}
+
+	logger.Info("TOTP enabled", zap.String("user_id", claims.UserID))
+
+	return &TOTPConfirmResponse{
+		Message: "TOTP enabled",
+	}, nil
+}
+
+// VerifyTOTP redeems an MFA ticket with a TOTP code and issues the session's
+// tokens. A wrong code keeps the ticket until maxTOTPAttempts is reached and
+// counts as a failed login for the user, like a wrong password.
+func (s *Service) VerifyTOTP(ctx context.Context, req *MFATOTPRequest) (*MFATOTPResponse, error) {
+	ticket, err := s.takeChallenge(ctx, req.MFATicket)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.PendingSessionID == "" {
+		return nil, errors.NewAuthenticationError("invalid or expired session")
+	}
+
+	attempt, err := s.beginMFAAttempt(ctx, ticket.Username)
+	if err != nil {
+		s.retryTicket(ctx, ticket, err)
+		return nil, err
+	}
+
+	credential, err := s.totpRepo.GetByUserID(ctx, ticket.UserID)
+	if err != nil {
+		return nil, errors.NewInternalError("failed to retrieve TOTP secret")
+	}
+
+	if err := s.checkTOTP(ctx, credential, req.Code, false); err != nil {
+		if failedProof(err) {
+			s.loginFailed(ticket.Username, attempt)
+		}
+		s.retryTicket(ctx, ticket, err)
+		return nil, err
+	}
+	s.resetLoginFailures(ctx, ticket.Username)
+
+	var challenge []byte
+	if ticket.ClientA != nil {
+		challenge = ticket.ClientA.Bytes()
+	}
+	tokens, err := s.openSession(ctx, ticket.PendingSessionID, ticket.Username, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &MFATOTPResponse{
+		Token:            tokens.AccessToken,
+		ExpiresAt:        tokens.ExpiresAt,
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt,
+	}
+
+	if ticket.Upgrade != nil {
+		upgraded := &model.User{}
+		if err := s.applyVerifierCredentials(upgraded, ticket.Upgrade); err == nil {
+			resp.Upgraded = s.upgradeVerifier(ctx, ticket.UserID, upgraded)
+		}
+	}
+	if !resp.Upgraded {
+		resp.Upgrade = s.upgradeHint(ctx, ticket.UserID)
+	}
+
+	return resp, nil
+}
+
+// mfaRequired reports whether the user has a confirmed second factor
+func (s *Service) mfaRequired(ctx context.Context, userID string) (bool, error) {
+	confirmed, err := s.totpRepo.IsConfirmed(ctx, userID)
+	if err != nil {
+		return false, errors.NewInternalError("failed to check second factor")
+	}
+	return confirmed, nil
+}
+
+// startMFA parks a login whose first factor succeeded and returns the ticket
+// that redeems it. The session is kept alive until the ticket expires.
+func (s *Service) startMFA(ctx context.Context, challenge *AuthChallenge, upgrade *VerifierCredentials) (string, error) {
+	ticketID, err := newRandomID()
+	if err != nil {
+		return "", errors.NewInternalError("failed to generate MFA ticket")
+	}
+
+	session, err := s.sessionRepo.GetByID(ctx, challenge.SessionID)
+	if err != nil {
+		return "", errors.NewInternalError("failed to retrieve session")
+	}
+	session.ExpiresAt = time.Now().Add(mfaTicketTTL)
+	if err := s.sessionRepo.Update(ctx, session); err != nil {
+		return "", errors.NewInternalError("failed to update session")
+	}
+
+	ticket := &AuthChallenge{
+		SessionID:        ticketID,
+		PendingSessionID: challenge.SessionID,
+		UserID:           challenge.UserID,
+		Username:         challenge.Username,
+		ClientA:          challenge.ClientA,
+		Upgrade:          upgrade,
+		CreatedAt:        time.Now(),
+	}
+	if err := s.challenges.Put(ctx, ticket, mfaTicketTTL); err != nil {
+		return "", errors.NewInternalError("failed to store MFA ticket")
+	}
+
+	return ticketID, nil
+}
+
+// retryTicket puts a ticket back after a wrong code or a locked attempt,
+// unless it has used up its attempts or its lifetime
+func (s *Service) retryTicket(ctx context.Context, ticket *AuthChallenge, cause error) {
+	appErr, ok := cause.(*errors.AppError)
+	if !ok || appErr.StatusCode >= 500 {
+		return
+	}
+
+	if appErr.Code != errors.ErrCodeAccountLocked {
+		// Waiting out the delay doesn't use up the ticket
+		ticket.Attempts++
+	}
+	remaining := time.Until(ticket.CreatedAt.Add(mfaTicketTTL))
+	if ticket.Attempts >= maxTOTPAttempts || remaining <= 0 {
+		logger.Warn("MFA ticket discarded after failed attempts",
+			zap.String("user_id", ticket.UserID))
+		return
+	}
+
+	if err := s.challenges.Put(ctx, ticket, remaining); err != nil {
+		logger.Warn("Failed to store MFA ticket", zap.Error(err))
+	}
+}
+
+// checkTOTP accepts code if it matches the credential's secret and its step
+// has not been used before. confirm also marks the credential confirmed.
+func (s *Service) checkTOTP(ctx context.Context, credential *model.TOTPCredential, code string, confirm bool) error {
+	if s.mfaKey == nil {
+		return errTOTPDisabled()
+	}
+
+	secret, err := crypto.Open(s.mfaKey, credential.SecretEncrypted, []byte(credential.UserID))
+	if err != nil {
+		return errors.NewInternalError("failed to decrypt TOTP secret")
+	}
+
+	step, ok := crypto.ValidateTOTP(secret, code, time.Now(), totpSkew)
+	if !ok {
+		return errors.NewAuthenticationError("invalid code")
+	}
+
+	used, err := s.totpRepo.UseStep(ctx, credential.UserID, step, confirm)
+	if err != nil {
+		return errors.NewInternalError("failed to record TOTP use")
+	}
+	if !used {
+		return errors.NewAuthenticationError("invalid code")
+	}
+	return nil
+}
+
+func errTOTPDisabled() *errors.AppError {
+	return errors.NewNotFoundError("TOTP")
+}
+
+// mfaKeyFromConfig returns the key encrypting TOTP secrets, or nil if none
+// is set
+func mfaKeyFromConfig(cfg *config.MFAConfig) ([]byte, error) {
+	if cfg.EncryptionKey == "" {
+		return nil, nil
+	}
+
+	key, err := hex.DecodeString(cfg.EncryptionKey)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("MFA_ENCRYPTION_KEY must be 32 hex encoded bytes")
+	}
+	return key, nil
+}
@@ -1,26 +1,63 @@
 package auth
 
 import (
+	"encoding/json"
 	"math/big"
 	"time"
 
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type AuthChallenge struct {
-	SessionID    string
-	Username     string
-	ClientA      *big.Int
-	ServerB      *big.Int
-	ServerSecret *big.Int
-	Salt         []byte
-	Verifier     []byte
-	CreatedAt    time.Time
+	SessionID     string
+	UserID        string
+	Username      string
+	ClientA       *big.Int
+	ServerB       *big.Int
+	ServerSecret  *big.Int
+	Salt          []byte
+	Verifier      []byte
+	SRPGroup      int
+	HashAlgorithm string
+	SRPMode       string
+	CreatedAt     time.Time
+	// BoundSessionID is set for re-authentication challenges and holds the
+	// authenticated session that requested them.
+	BoundSessionID string
+	// OPAQUE holds the server state of an OPAQUE login instead of SRP values
+	OPAQUE *crypto.OPAQUEServerLogin
+	// PendingSessionID is set on MFA tickets, which are stored as challenges
+	// under their own ID, and holds the session opened by the first factor.
+	PendingSessionID string
+	// Upgrade holds verifier credentials to store once the login completes
+	Upgrade  *VerifierCredentials
+	Attempts int
+}
+
+// VerifierCredentials carries an SRP salt and verifier computed on the client,
+// hex encoded, so the password itself never reaches the server.
+type VerifierCredentials struct {
+	Salt          string            `json:"salt,omitempty"`
+	Verifier      string            `json:"verifier,omitempty"`
+	SRPGroup      int               `json:"srp_group,omitempty"`
+	HashAlgorithm string            `json:"hash_algorithm,omitempty"`
+	SRPMode       string            `json:"srp_mode,omitempty"`
+	KDF           *crypto.KDFParams `json:"kdf,omitempty"`
+}
+
+// RegistrationParamsResponse lists the parameters new verifiers are computed with
+type RegistrationParamsResponse struct {
+	SRPGroup      int               `json:"srp_group"`
+	HashAlgorithm string            `json:"hash_algorithm"`
+	SRPMode       string            `json:"srp_mode"`
+	KDF           *crypto.KDFParams `json:"kdf"`
 }
 
 type RegisterRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
-	Password string `json:"password" validate:"required,min=8"`
+	Password string `json:"password,omitempty"`
+	VerifierCredentials
 }
 
 type RegisterResponse struct {
@@ -29,26 +66,47 @@ type RegisterResponse struct {
 	Message  string `json:"message"`
 }
 
+// ChallengeRequest starts a login. ClientA may be omitted, in which case the
+// client sends it with its proof once it has learned the account's SRP group.
 type ChallengeRequest struct {
-	Username string `json:"username" validate:"required"`
-	ClientA  string `json:"client_a" validate:"required"`
+	Username   string `json:"username" validate:"required"`
+	ClientA    string `json:"client_a,omitempty"`
+	DeviceName string `json:"device_name,omitempty"`
 }
 
 type ChallengeResponse struct {
-	SessionID string `json:"session_id"`
-	Salt      string `json:"salt"`
-	ServerB   string `json:"server_b"`
+	SessionID     string           `json:"session_id"`
+	Salt          string           `json:"salt"`
+	ServerB       string           `json:"server_b"`
+	SRPGroup      int              `json:"srp_group"`
+	HashAlgorithm string           `json:"hash_algorithm"`
+	SRPMode       string           `json:"srp_mode"`
+	KDF           crypto.KDFParams `json:"kdf"`
 }
 
 type VerifyRequest struct {
-	SessionID   string `json:"session_id" validate:"required"`
-	ClientProof string `json:"client_proof" validate:"required"`
+	SessionID   string               `json:"session_id" validate:"required"`
+	ClientA     string               `json:"client_a,omitempty"`
+	ClientProof string               `json:"client_proof" validate:"required"`
+	Upgrade     *VerifierCredentials `json:"upgrade,omitempty"`
 }
 
+// VerifyResponse carries the session tokens, or an MFA ticket instead when
+// the account has a second factor
 type VerifyResponse struct {
-	Token       string    `json:"token"`
-	ServerProof string    `json:"server_proof"`
-	ExpiresAt   time.Time `json:"expires_at"`
+	Token            string    `json:"token,omitempty"`
+	ServerProof      string    `json:"server_proof"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token,omitempty"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	Upgraded         bool      `json:"upgraded,omitempty"`
+	// Upgrade is set when the stored verifier no longer meets the current
+	// parameters. The client can store a verifier computed with them by
+	// changing its password to the same one, or send one with its next
+	// proof.
+	Upgrade    *RegistrationParamsResponse `json:"upgrade,omitempty"`
+	MFAPending bool                        `json:"mfa_pending,omitempty"`
+	MFATicket  string                      `json:"mfa_ticket,omitempty"`
 }
 
 type LogoutRequest struct {
@@ -60,26 +118,240 @@ type LogoutResponse struct {
 }
 
 type RefreshRequest struct {
-	Token string `json:"token" validate:"required"`
+	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
 type RefreshResponse struct {
-	Token     string    `json:"token"`
-	ExpiresAt time.Time `json:"expires_at"`
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// SessionInfo describes one of the caller's sessions
+type SessionInfo struct {
+	ID         string     `json:"id"`
+	ClientID   string     `json:"client_id,omitempty"`
+	IPAddress  string     `json:"ip_address,omitempty"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	DeviceName string     `json:"device_name,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Current    bool       `json:"current"`
+}
+
+type SessionListResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+type RevokeSessionsResponse struct {
+	Message string `json:"message"`
+}
+
+type ReauthChallengeRequest struct {
+	ClientA string `json:"client_a,omitempty"`
+}
+
+type ReauthChallengeResponse struct {
+	ChallengeID   string           `json:"challenge_id"`
+	Salt          string           `json:"salt"`
+	ServerB       string           `json:"server_b"`
+	SRPGroup      int              `json:"srp_group"`
+	HashAlgorithm string           `json:"hash_algorithm"`
+	SRPMode       string           `json:"srp_mode"`
+	KDF           crypto.KDFParams `json:"kdf"`
+}
+
+// ReauthProof answers a re-authentication challenge with a fresh SRP proof,
+// or with KE3 for an OPAQUE challenge.
+type ReauthProof struct {
+	ChallengeID string `json:"challenge_id,omitempty"`
+	ClientA     string `json:"client_a,omitempty"`
+	ClientProof string `json:"client_proof,omitempty"`
+	KE3         string `json:"ke3,omitempty"`
 }
 
+// ChangePasswordRequest either carries a re-authentication proof and the new
+// verifier or OPAQUE record, or the legacy current and new plaintext
+// passwords.
 type ChangePasswordRequest struct {
-	CurrentPassword string `json:"current_password" validate:"required"`
-	NewPassword     string `json:"new_password" validate:"required,min=8"`
+	CurrentPassword string `json:"current_password,omitempty"`
+	NewPassword     string `json:"new_password,omitempty"`
+	ReauthProof
+	VerifierCredentials
+	// Record and KSF replace the record of an OPAQUE account
+	Record string            `json:"record,omitempty"`
+	KSF    *crypto.KDFParams `json:"ksf,omitempty"`
 }
 
-type ChangePasswordResponse struct {
+type RecoveryCodesRequest struct {
+	ReauthProof
+}
+
+type RecoveryCodesResponse struct {
+	Codes       []string `json:"codes"`
+	ServerProof string   `json:"server_proof,omitempty"`
+}
+
+// UpdateProfileRequest changes the fields that are present. Empty strings
+// clear a field.
+type UpdateProfileRequest struct {
+	DisplayName *string         `json:"display_name,omitempty"`
+	Email       *string         `json:"email,omitempty"`
+	Locale      *string         `json:"locale,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+}
+
+type ProfileResponse struct {
+	UserID                 string           `json:"user_id"`
+	Username               string           `json:"username"`
+	DisplayName            string           `json:"display_name"`
+	Email                  string           `json:"email"`
+	Locale                 string           `json:"locale"`
+	Metadata               json.RawMessage  `json:"metadata"`
+	SessionID              string           `json:"session_id"`
+	ExpiresAt              *jwt.NumericDate `json:"expires_at"`
+	RecoveryCodesRemaining int              `json:"recovery_codes_remaining"`
+	DeleteAfter            *time.Time       `json:"delete_after,omitempty"`
+}
+
+type UserRolesResponse struct {
+	UserID      string   `json:"user_id"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+type DeleteAccountRequest struct {
+	ReauthProof
+}
+
+// DeleteAccountResponse carries DeleteAfter when the deletion waits out a
+// grace period
+type DeleteAccountResponse struct {
+	Message     string     `json:"message"`
+	DeleteAfter *time.Time `json:"delete_after,omitempty"`
+	ServerProof string     `json:"server_proof,omitempty"`
+}
+
+type CancelAccountDeletionResponse struct {
+	Message string `json:"message"`
+}
+
+// RecoverAccountRequest spends a recovery code to replace a forgotten
+// password's verifier
+type RecoverAccountRequest struct {
+	Username     string `json:"username" validate:"required"`
+	RecoveryCode string `json:"recovery_code" validate:"required"`
+	VerifierCredentials
+}
+
+type RecoverAccountResponse struct {
 	Message string `json:"message"`
 }
 
+type ChangePasswordResponse struct {
+	Message     string `json:"message"`
+	ServerProof string `json:"server_proof,omitempty"`
+}
+
 type TokenClaims struct {
 	UserID    string `json:"user_id"`
 	Username  string `json:"username"`
 	SessionID string `json:"session_id"`
+	// AuthTime is when the session logged in, which bounds its lifetime
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// Roles held by the user when the token was issued, and the
+	// permissions they grant
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	// ClientID is the OpenID Connect client a token was issued to, which is
+	// also its audience. Such tokens carry no roles and are only accepted by
+	// the userinfo endpoint.
+	ClientID string `json:"client_id,omitempty"`
 	jwt.RegisteredClaims
 }
+
+// OPAQUE messages are hex encoded as defined in RFC 9807
+type OPAQUERegisterStartRequest struct {
+	Username            string `json:"username"`
+	RegistrationRequest string `json:"registration_request"`
+}
+
+type OPAQUERegisterStartResponse struct {
+	RegistrationResponse string           `json:"registration_response"`
+	KSF                  crypto.KDFParams `json:"ksf"`
+}
+
+type OPAQUERegisterFinishRequest struct {
+	Username string            `json:"username"`
+	Record   string            `json:"record"`
+	KSF      *crypto.KDFParams `json:"ksf"`
+}
+
+type OPAQUELoginStartRequest struct {
+	Username   string `json:"username"`
+	KE1        string `json:"ke1"`
+	DeviceName string `json:"device_name,omitempty"`
+}
+
+type OPAQUELoginStartResponse struct {
+	SessionID string           `json:"session_id"`
+	KE2       string           `json:"ke2"`
+	KSF       crypto.KDFParams `json:"ksf"`
+}
+
+type OPAQUELoginFinishRequest struct {
+	SessionID string `json:"session_id"`
+	KE3       string `json:"ke3"`
+}
+
+type OPAQUELoginFinishResponse struct {
+	Token            string    `json:"token,omitempty"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token,omitempty"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	MFAPending       bool      `json:"mfa_pending,omitempty"`
+	MFATicket        string    `json:"mfa_ticket,omitempty"`
+}
+
+// OPAQUEReauthRequest starts a re-authentication of an OPAQUE account
+type OPAQUEReauthRequest struct {
+	KE1 string `json:"ke1"`
+}
+
+type OPAQUEReauthResponse struct {
+	ChallengeID string           `json:"challenge_id"`
+	KE2         string           `json:"ke2"`
+	KSF         crypto.KDFParams `json:"ksf"`
+}
+
+type TOTPEnrollResponse struct {
+	// Secret is base32 encoded for manual entry
+	Secret string `json:"secret"`
+	// ProvisioningURI is the otpauth URI to show as a QR code
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TOTPConfirmRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TOTPConfirmResponse struct {
+	Message string `json:"message"`
+}
+
+// MFATOTPRequest completes a login that is waiting for a TOTP code
+type MFATOTPRequest struct {
+	MFATicket string `json:"mfa_ticket" validate:"required"`
+	Code      string `json:"code" validate:"required"`
+}
+
+type MFATOTPResponse struct {
+	Token            string                      `json:"token"`
+	ExpiresAt        time.Time                   `json:"expires_at"`
+	RefreshToken     string                      `json:"refresh_token"`
+	RefreshExpiresAt time.Time                   `json:"refresh_expires_at"`
+	Upgraded         bool                        `json:"upgraded,omitempty"`
+	Upgrade          *RegistrationParamsResponse `json:"upgrade,omitempty"`
+}
@@ -1,19 +1,40 @@
 package auth
 
 import (
+	"fmt"
+
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
 	"github.com/francisco3ferraz/zk-auth/internal/errors"
 )
 
 func (h *Handler) validateRegisterRequest(req *RegisterRequest) *errors.AppError {
-	if len(req.Username) < 3 || len(req.Username) > 50 {
-		return errors.NewValidationError("username must be between 3 and 50 characters")
+	if err := validateUsername(req.Username); err != nil {
+		return err
+	}
+
+	if req.Salt != "" || req.Verifier != "" {
+		if req.Password != "" {
+			return errors.NewValidationError("provide either a password or a salt and verifier, not both")
+		}
+		if req.Salt == "" || req.Verifier == "" {
+			return errors.NewValidationError("salt and verifier are both required")
+		}
+		return nil
 	}
 
 	if len(req.Password) < 8 {
 		return errors.NewValidationError("password must be at least 8 characters")
 	}
 
-	for _, char := range req.Username {
+	return nil
+}
+
+func validateUsername(username string) *errors.AppError {
+	if len(username) < 3 || len(username) > 50 {
+		return errors.NewValidationError("username must be between 3 and 50 characters")
+	}
+
+	for _, char := range username {
 		if !isAlphanumeric(char) && char != '_' {
 			return errors.NewValidationError("username can only contain letters, numbers, and underscores")
 		}
@@ -22,8 +43,30 @@ func (h *Handler) validateRegisterRequest(req *RegisterRequest) *errors.AppError
 	return nil
 }
 
+// validateReauthProof requires a challenge ID and either an SRP client proof
+// or an OPAQUE KE3
+func validateReauthProof(proof *ReauthProof) *errors.AppError {
+	if proof.ChallengeID == "" || (proof.ClientProof == "" && proof.KE3 == "") {
+		return errors.NewValidationError("challenge_id and client_proof or ke3 are required")
+	}
+	return nil
+}
+
 func isAlphanumeric(char rune) bool {
 	return (char >= 'a' && char <= 'z') ||
 		(char >= 'A' && char <= 'Z') ||
 		(char >= '0' && char <= '9')
 }
+
+// newRandomID returns a random identifier formatted as a version 4 UUID,
+// matching the shape of the session IDs generated by the database.
+func newRandomID() (string, error) {
+	b, err := crypto.GenerateRandomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
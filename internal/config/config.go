@@ -14,6 +14,9 @@ type Config struct {
 	Server   ServerConfig
 	Security SecurityConfig
 	SRP      SRPConfig
+	OPAQUE   OPAQUEConfig
+	OIDC     OIDCConfig
+	MFA      MFAConfig
 }
 
 type DatabaseConfig struct {
@@ -33,16 +36,94 @@ type ServerConfig struct {
 }
 
 type SecurityConfig struct {
-	JWTSecret       string
-	JWTExpiry       time.Duration
-	BCryptCost      int
-	RateLimitReqs   int
-	RateLimitWindow time.Duration
+	JWTSecret string
+	// JWTPrivateKeyFile is a PEM Ed25519, P-256 or RSA key for signing tokens
+	// with EdDSA, ES256 or RS256. Tokens are signed with JWTSecret and HS256
+	// if empty.
+	JWTPrivateKeyFile string
+	// SigningKeyEncryptionKey (hex, 32 bytes) encrypts the rotated signing
+	// keys stored in the database. Required once a key has been rotated.
+	SigningKeyEncryptionKey string
+	JWTExpiry               time.Duration
+	// RefreshTokenExpiry is how long a refresh token stays valid
+	RefreshTokenExpiry time.Duration
+	// SessionIdleTimeout ends a session that has not been used for this
+	// long, and SessionMaxLifetime one this long after its login, however
+	// active. Zero disables either limit.
+	SessionIdleTimeout time.Duration
+	SessionMaxLifetime time.Duration
+	BCryptCost         int
+	RateLimitReqs      int
+	RateLimitWindow    time.Duration
+	// AllowPlaintextPasswords enables the legacy endpoints that accept a
+	// password and compute the SRP verifier on the server.
+	AllowPlaintextPasswords bool
+	// FakeCredentialSecret derives the salts and verifiers served for unknown
+	// usernames. Defaults to a key derived from JWTSecret.
+	FakeCredentialSecret string
+	// AuthResponseFloor is the minimum time spent answering requests whose
+	// timing could reveal whether a username exists.
+	AuthResponseFloor time.Duration
+	// ChallengeStore is "postgres" to share pending challenges between
+	// instances, or "memory" for a single instance.
+	ChallengeStore string
+	// ChallengeEncryptionKey (hex, 32 bytes) encrypts the challenges kept in
+	// Postgres. Defaults to a key derived from JWTSecret.
+	ChallengeEncryptionKey string
+	// LockoutThreshold is the number of consecutive failed logins after
+	// which a username is locked for LockoutDuration. Before that, each
+	// failure doubles the wait for the next attempt, from LoginBackoffBase
+	// up to LoginBackoffMax. Zero disables both.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	LoginBackoffBase time.Duration
+	LoginBackoffMax  time.Duration
+	// AccountDeletionGrace delays the deletion of an account its owner
+	// asked to delete, so that it can still be cancelled. Zero deletes
+	// immediately.
+	AccountDeletionGrace time.Duration
 }
 
 type SRPConfig struct {
 	KeyLength     int
 	HashAlgorithm string
+	// Mode is "legacy" or "rfc5054" for interop with standard SRP-6a clients
+	Mode string
+	// KDF used to derive the private key x of new verifiers
+	KDFAlgorithm  string
+	Argon2Time    int
+	Argon2Memory  int
+	Argon2Threads int
+	ScryptN       int
+	ScryptR       int
+	ScryptP       int
+}
+
+type OPAQUEConfig struct {
+	// ServerSecret (hex) seeds the server key pair and OPRF keys. Changing
+	// it invalidates every OPAQUE registration. OPAQUE is disabled if empty.
+	ServerSecret string
+}
+
+type OIDCConfig struct {
+	// Issuer is the public base URL of this server. The OpenID Connect
+	// provider is disabled if empty.
+	Issuer string
+	// RegistrationToken authorizes client registration. Registration is
+	// disabled if empty.
+	RegistrationToken string
+	CodeTTL           time.Duration
+	// StaticDir holds the login page assets, including the WebAssembly
+	// build of the SRP client.
+	StaticDir string
+}
+
+type MFAConfig struct {
+	// EncryptionKey (hex, 32 bytes) encrypts TOTP secrets at rest. TOTP
+	// enrollment is disabled if empty.
+	EncryptionKey string
+	// Issuer names this service in authenticator apps
+	Issuer string
 }
 
 func Load() (*Config, error) {
@@ -66,13 +147,46 @@ func Load() (*Config, error) {
 	if cfg.Security.JWTSecret == "" {
 		return nil, fmt.Errorf("JWT_SECRET is required")
 	}
-	cfg.Security.JWTExpiry = getEnvAsDuration("JWT_EXPIRY", 24*time.Hour)
+	cfg.Security.JWTPrivateKeyFile = getEnv("JWT_PRIVATE_KEY_FILE", "")
+	cfg.Security.SigningKeyEncryptionKey = getEnv("SIGNING_KEY_ENCRYPTION_KEY", "")
+	cfg.Security.JWTExpiry = getEnvAsDuration("JWT_EXPIRY", 15*time.Minute)
+	cfg.Security.RefreshTokenExpiry = getEnvAsDuration("REFRESH_TOKEN_EXPIRY", 30*24*time.Hour)
+	cfg.Security.SessionIdleTimeout = getEnvAsDuration("SESSION_IDLE_TIMEOUT", 7*24*time.Hour)
+	cfg.Security.SessionMaxLifetime = getEnvAsDuration("SESSION_MAX_LIFETIME", 30*24*time.Hour)
 	cfg.Security.BCryptCost = getEnvAsInt("BCRYPT_COST", 12)
 	cfg.Security.RateLimitReqs = getEnvAsInt("RATE_LIMIT_REQUESTS", 100)
 	cfg.Security.RateLimitWindow = getEnvAsDuration("RATE_LIMIT_WINDOW", time.Minute)
+	cfg.Security.AllowPlaintextPasswords = getEnvAsBool("ALLOW_PLAINTEXT_PASSWORDS", true)
+	cfg.Security.FakeCredentialSecret = getEnv("FAKE_CREDENTIAL_SECRET", "")
+	cfg.Security.AuthResponseFloor = getEnvAsDuration("AUTH_RESPONSE_FLOOR", 50*time.Millisecond)
+	cfg.Security.ChallengeStore = getEnv("CHALLENGE_STORE", "postgres")
+	cfg.Security.ChallengeEncryptionKey = getEnv("CHALLENGE_ENCRYPTION_KEY", "")
+	cfg.Security.LockoutThreshold = getEnvAsInt("LOCKOUT_THRESHOLD", 10)
+	cfg.Security.LockoutDuration = getEnvAsDuration("LOCKOUT_DURATION", 15*time.Minute)
+	cfg.Security.LoginBackoffBase = getEnvAsDuration("LOGIN_BACKOFF_BASE", time.Second)
+	cfg.Security.LoginBackoffMax = getEnvAsDuration("LOGIN_BACKOFF_MAX", 30*time.Second)
+	cfg.Security.AccountDeletionGrace = getEnvAsDuration("ACCOUNT_DELETION_GRACE", 0)
 
 	cfg.SRP.KeyLength = getEnvAsInt("SRP_KEY_LENGTH", 2048)
 	cfg.SRP.HashAlgorithm = getEnv("SRP_HASH_ALGORITHM", "SHA256")
+	cfg.SRP.Mode = getEnv("SRP_MODE", "legacy")
+	cfg.SRP.KDFAlgorithm = getEnv("SRP_KDF", "argon2id")
+	cfg.SRP.Argon2Time = getEnvAsInt("SRP_ARGON2_TIME", 3)
+	cfg.SRP.Argon2Memory = getEnvAsInt("SRP_ARGON2_MEMORY", 64*1024)
+	cfg.SRP.Argon2Threads = getEnvAsInt("SRP_ARGON2_THREADS", 4)
+	cfg.SRP.ScryptN = getEnvAsInt("SRP_SCRYPT_N", 1<<15)
+	cfg.SRP.ScryptR = getEnvAsInt("SRP_SCRYPT_R", 8)
+	cfg.SRP.ScryptP = getEnvAsInt("SRP_SCRYPT_P", 1)
+
+	cfg.OPAQUE.ServerSecret = getEnv("OPAQUE_SERVER_SECRET", "")
+
+	cfg.OIDC.Issuer = getEnv("OIDC_ISSUER", "")
+	cfg.OIDC.RegistrationToken = getEnv("OIDC_REGISTRATION_TOKEN", "")
+	cfg.OIDC.CodeTTL = getEnvAsDuration("OIDC_CODE_TTL", time.Minute)
+	cfg.OIDC.StaticDir = getEnv("OIDC_STATIC_DIR", "web")
+
+	cfg.MFA.EncryptionKey = getEnv("MFA_ENCRYPTION_KEY", "")
+	cfg.MFA.Issuer = getEnv("MFA_ISSUER", "zk-auth")
 
 	return cfg, nil
 }
@@ -99,3 +213,11 @@ func getEnvAsDuration(name string, defaultVal time.Duration) time.Duration {
 	}
 	return defaultVal
 }
+
+func getEnvAsBool(name string, defaultVal bool) bool {
+	valueStr := getEnv(name, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultVal
+}
@@ -0,0 +1,152 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// Hashing to P-256 following RFC 9380, suite P256_XMD:SHA-256_SSWU_RO_.
+// https://www.rfc-editor.org/rfc/rfc9380
+
+// h2cFieldLength is L for P-256: ceil((ceil(log2(p)) + k) / 8) with k = 128
+const h2cFieldLength = 48
+
+var (
+	p256 = elliptic.P256()
+
+	// Simplified SWU constants for P-256: A = -3, Z = -10
+	sswuA = new(big.Int).Sub(p256.Params().P, big.NewInt(3))
+	sswuZ = new(big.Int).Sub(p256.Params().P, big.NewInt(10))
+)
+
+// expandMessageXMD implements expand_message_xmd with SHA-256
+func expandMessageXMD(msg, dst []byte, length int) ([]byte, error) {
+	const bInBytes, sInBytes = sha256.Size, sha256.BlockSize
+
+	ell := (length + bInBytes - 1) / bInBytes
+	if ell > 255 || length > 65535 || len(dst) > 255 {
+		return nil, fmt.Errorf("expand_message_xmd: invalid lengths")
+	}
+
+	dstPrime := append(append([]byte{}, dst...), byte(len(dst)))
+
+	h := sha256.New()
+	h.Write(make([]byte, sInBytes))
+	h.Write(msg)
+	h.Write([]byte{byte(length >> 8), byte(length), 0})
+	h.Write(dstPrime)
+	b0 := h.Sum(nil)
+
+	h.Reset()
+	h.Write(b0)
+	h.Write([]byte{1})
+	h.Write(dstPrime)
+	bi := h.Sum(nil)
+
+	out := append([]byte{}, bi...)
+	for i := 2; i <= ell; i++ {
+		h.Reset()
+		for j := range b0 {
+			h.Write([]byte{b0[j] ^ bi[j]})
+		}
+		h.Write([]byte{byte(i)})
+		h.Write(dstPrime)
+		bi = h.Sum(nil)
+		out = append(out, bi...)
+	}
+
+	return out[:length], nil
+}
+
+// hashToField hashes msg to count elements of the integers modulo m
+func hashToField(msg, dst []byte, m *big.Int, count int) ([]*big.Int, error) {
+	uniform, err := expandMessageXMD(msg, dst, count*h2cFieldLength)
+	if err != nil {
+		return nil, err
+	}
+
+	elements := make([]*big.Int, count)
+	for i := range elements {
+		chunk := uniform[i*h2cFieldLength : (i+1)*h2cFieldLength]
+		elements[i] = new(big.Int).Mod(new(big.Int).SetBytes(chunk), m)
+	}
+	return elements, nil
+}
+
+// hashToCurve hashes msg to a point on P-256
+func hashToCurve(msg, dst []byte) (x, y *big.Int, err error) {
+	u, err := hashToField(msg, dst, p256.Params().P, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	x0, y0 := mapToCurveSSWU(u[0])
+	x1, y1 := mapToCurveSSWU(u[1])
+	// P-256 has cofactor 1, so no clearing is needed
+	x, y = p256.Add(x0, y0, x1, y1)
+	return x, y, nil
+}
+
+// mapToCurveSSWU is the simplified Shallue-van de Woestijne-Ulas map
+func mapToCurveSSWU(u *big.Int) (x, y *big.Int) {
+	params := p256.Params()
+	p := params.P
+
+	mod := func(n *big.Int) *big.Int { return n.Mod(n, p) }
+	mul := func(a, b *big.Int) *big.Int { return mod(new(big.Int).Mul(a, b)) }
+	add := func(a, b *big.Int) *big.Int { return mod(new(big.Int).Add(a, b)) }
+	gx := func(x *big.Int) *big.Int {
+		// x^3 + A*x + B
+		return add(add(mul(mul(x, x), x), mul(sswuA, x)), params.B)
+	}
+
+	u2 := mul(u, u)
+	zu2 := mul(sswuZ, u2)
+
+	// tv1 = inv0(Z^2 * u^4 + Z * u^2)
+	tv1 := add(mul(zu2, zu2), zu2)
+	if tv1.Sign() != 0 {
+		tv1.ModInverse(tv1, p)
+	}
+
+	// x1 = (-B / A) * (1 + tv1), or B / (Z * A) when tv1 == 0
+	var x1 *big.Int
+	aInv := new(big.Int).ModInverse(sswuA, p)
+	if tv1.Sign() == 0 {
+		x1 = mul(params.B, new(big.Int).ModInverse(mul(sswuZ, sswuA), p))
+	} else {
+		negB := mod(new(big.Int).Neg(params.B))
+		x1 = mul(mul(negB, aInv), add(big.NewInt(1), tv1))
+	}
+
+	gx1 := gx(x1)
+	if y1 := sqrtP256(gx1); y1 != nil {
+		x, y = x1, y1
+	} else {
+		x = mul(zu2, x1)
+		y = sqrtP256(gx(x))
+	}
+
+	// sgn0(u) must equal sgn0(y)
+	if u.Bit(0) != y.Bit(0) {
+		y = mod(new(big.Int).Neg(y))
+	}
+
+	return x, y
+}
+
+// sqrtP256 returns a square root of a modulo p, or nil if a is not a square.
+// p = 3 mod 4, so the root is a^((p+1)/4).
+func sqrtP256(a *big.Int) *big.Int {
+	p := p256.Params().P
+	exp := new(big.Int).Add(p, big.NewInt(1))
+	exp.Rsh(exp, 2)
+
+	root := new(big.Int).Exp(a, exp, p)
+	if new(big.Int).Exp(root, big.NewInt(2), p).Cmp(new(big.Int).Mod(a, p)) != 0 {
+		return nil
+	}
+	return root
+}
@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// HashAlgorithm names a hash function SRP can be instantiated with
+type HashAlgorithm string
+
+const (
+	// SHA1 is only meant for interoperability with RFC 5054 clients
+	SHA1    HashAlgorithm = "SHA1"
+	SHA256  HashAlgorithm = "SHA256"
+	SHA512  HashAlgorithm = "SHA512"
+	BLAKE2b HashAlgorithm = "BLAKE2B"
+)
+
+// DefaultHashAlgorithm is used when no other is configured
+const DefaultHashAlgorithm = SHA256
+
+// ParseHashAlgorithm accepts names such as "SHA256", "sha-256" or "blake2b"
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	normalized := strings.ToUpper(strings.ReplaceAll(name, "-", ""))
+
+	switch HashAlgorithm(normalized) {
+	case SHA1, SHA256, SHA512, BLAKE2b:
+		return HashAlgorithm(normalized), nil
+	default:
+		return "", fmt.Errorf("unsupported hash algorithm: %s", name)
+	}
+}
+
+// HashAlgorithms returns all supported hash algorithms
+func HashAlgorithms() []HashAlgorithm {
+	return []HashAlgorithm{SHA1, SHA256, SHA512, BLAKE2b}
+}
+
+// hashStrength orders the algorithms by output size
+var hashStrength = map[HashAlgorithm]int{SHA1: 0, SHA256: 1, SHA512: 2, BLAKE2b: 2}
+
+// AtLeast reports whether a is at least as strong as min
+func (a HashAlgorithm) AtLeast(min HashAlgorithm) bool {
+	return hashStrength[a] >= hashStrength[min]
+}
+
+// New returns a new hash.Hash computing the algorithm
+func (a HashAlgorithm) New() hash.Hash {
+	switch a {
+	case SHA1:
+		return sha1.New()
+	case SHA512:
+		return sha512.New()
+	case BLAKE2b:
+		// A nil key never makes New512 fail
+		h, _ := blake2b.New512(nil)
+		return h
+	default:
+		return sha256.New()
+	}
+}
+
+// Sum hashes the concatenation of data
+func (a HashAlgorithm) Sum(data ...[]byte) []byte {
+	h := a.New()
+
+	for _, d := range data {
+		h.Write(d)
+	}
+
+	return h.Sum(nil)
+}
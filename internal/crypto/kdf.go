@@ -0,0 +1,118 @@
+package crypto
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDFAlgorithm names the password KDF used when deriving the SRP private key x
+type KDFAlgorithm string
+
+const (
+	// KDFNone hashes the credentials once, x = H(salt | H(username | ":" | password))
+	KDFNone     KDFAlgorithm = "none"
+	KDFArgon2id KDFAlgorithm = "argon2id"
+	KDFScrypt   KDFAlgorithm = "scrypt"
+)
+
+// kdfKeyLength is the length of the stretched credentials fed into x
+const kdfKeyLength = 32
+
+// Bounds for KDF parameters. The lower bounds follow current OWASP guidance,
+// the upper bounds stop a server from making clients exhaust their memory.
+const (
+	minArgon2Memory = 19 * 1024
+	maxArgon2Memory = 4 * 1024 * 1024
+	maxArgon2Time   = 16
+	minScryptN      = 1 << 14
+	maxScryptMemory = 1 << 30
+)
+
+// KDFParams selects the password KDF and its cost parameters. They are stored
+// per user and sent to clients with every challenge.
+type KDFParams struct {
+	Algorithm KDFAlgorithm `json:"algorithm"`
+
+	// Argon2id: iterations, memory in KiB and parallelism
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+
+	// scrypt: CPU/memory cost, block size and parallelism
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+}
+
+// Validate checks that the parameters are within the accepted bounds
+func (p KDFParams) Validate() error {
+	switch p.Algorithm {
+	case KDFNone:
+		return nil
+	case KDFArgon2id:
+		if p.Time < 1 || p.Time > maxArgon2Time {
+			return fmt.Errorf("argon2id time must be between 1 and %d", maxArgon2Time)
+		}
+		if p.Memory < minArgon2Memory || p.Memory > maxArgon2Memory {
+			return fmt.Errorf("argon2id memory must be between %d and %d KiB", minArgon2Memory, maxArgon2Memory)
+		}
+		if p.Threads < 1 {
+			return fmt.Errorf("argon2id threads must be at least 1")
+		}
+		return nil
+	case KDFScrypt:
+		if p.N < minScryptN || p.N&(p.N-1) != 0 {
+			return fmt.Errorf("scrypt N must be a power of two of at least %d", minScryptN)
+		}
+		if p.R < 1 || p.P < 1 {
+			return fmt.Errorf("scrypt r and p must be at least 1")
+		}
+		if 128*p.N*p.R > maxScryptMemory {
+			return fmt.Errorf("scrypt parameters require more than %d bytes of memory", maxScryptMemory)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported KDF algorithm: %s", p.Algorithm)
+	}
+}
+
+// AtLeast reports whether p is at least as costly as min. Any memory-hard
+// KDF satisfies a policy naming a different memory-hard KDF.
+func (p KDFParams) AtLeast(min KDFParams) bool {
+	if min.Algorithm == KDFNone {
+		return true
+	}
+	if p.Algorithm == KDFNone {
+		return false
+	}
+	if p.Algorithm != min.Algorithm {
+		return true
+	}
+
+	switch p.Algorithm {
+	case KDFArgon2id:
+		return p.Time >= min.Time && p.Memory >= min.Memory
+	case KDFScrypt:
+		return p.N >= min.N && p.R >= min.R
+	default:
+		return false
+	}
+}
+
+// Stretch derives a key from secret and salt with the memory-hard KDF.
+// It must not be called with KDFNone, which has no stretching step.
+func (p KDFParams) Stretch(secret, salt []byte) ([]byte, error) {
+	switch p.Algorithm {
+	case KDFArgon2id:
+		if p.Time < 1 || p.Threads < 1 {
+			return nil, fmt.Errorf("invalid argon2id parameters")
+		}
+		return argon2.IDKey(secret, salt, p.Time, p.Memory, p.Threads, kdfKeyLength), nil
+	case KDFScrypt:
+		return scrypt.Key(secret, salt, p.N, p.R, p.P, kdfKeyLength)
+	default:
+		return nil, fmt.Errorf("unsupported KDF algorithm: %s", p.Algorithm)
+	}
+}
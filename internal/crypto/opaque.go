@@ -0,0 +1,526 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// OPAQUE-3DH from RFC 9807 with P256-SHA256 as OPRF, HKDF-SHA256, HMAC-SHA256,
+// SHA-256 and 3DH over P-256. The key stretching function is configured with
+// KDFParams; KDFNone selects the identity KSF.
+// https://www.rfc-editor.org/rfc/rfc9807
+
+const (
+	opaqueNonceLength = 32 // Nn
+	opaqueSeedLength  = 32 // Nseed
+	opaqueHashLength  = 32 // Nh, Nm and Nx
+
+	opaqueEnvelopeLength = opaqueNonceLength + opaqueHashLength
+	// masked_response is server_public_key || envelope
+	opaqueMaskedLength = elementLength + opaqueEnvelopeLength
+
+	// OPAQUERecordLength is the size of a serialized registration record
+	OPAQUERecordLength = elementLength + opaqueHashLength + opaqueEnvelopeLength
+
+	opaqueKE1Length = elementLength + opaqueNonceLength + elementLength
+	opaqueKE2Length = elementLength + opaqueNonceLength + opaqueMaskedLength +
+		opaqueNonceLength + elementLength + opaqueHashLength
+)
+
+// ksfSalt is the fixed salt RFC 9807 uses for memory-hard KSFs
+var ksfSalt = make([]byte, 16)
+
+// OPAQUERecord is what the server stores for a client after registration
+type OPAQUERecord struct {
+	ClientPublicKey []byte
+	MaskingKey      []byte
+	Envelope        []byte
+}
+
+// ParseOPAQUERecord decodes a serialized registration record
+func ParseOPAQUERecord(b []byte) (*OPAQUERecord, error) {
+	if len(b) != OPAQUERecordLength {
+		return nil, fmt.Errorf("invalid OPAQUE record length")
+	}
+	if _, err := parsePoint(b[:elementLength]); err != nil {
+		return nil, fmt.Errorf("invalid client public key: %w", err)
+	}
+
+	return &OPAQUERecord{
+		ClientPublicKey: b[:elementLength],
+		MaskingKey:      b[elementLength : elementLength+opaqueHashLength],
+		Envelope:        b[elementLength+opaqueHashLength:],
+	}, nil
+}
+
+// Bytes serializes the record
+func (r *OPAQUERecord) Bytes() []byte {
+	return concat(r.ClientPublicKey, r.MaskingKey, r.Envelope)
+}
+
+// OPAQUEServer holds the server's long-term OPAQUE key material
+type OPAQUEServer struct {
+	privateKey *big.Int
+	publicKey  *point
+	oprfSeed   []byte
+	context    []byte
+}
+
+// NewOPAQUEServer derives the server key pair and OPRF seed from secret, so
+// every instance configured with the same secret can serve the same records.
+// context binds the handshake to this deployment.
+func NewOPAQUEServer(secret []byte, context string) (*OPAQUEServer, error) {
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("OPAQUE server secret must be at least 32 bytes")
+	}
+
+	prk := hkdf.Extract(sha256.New, secret, []byte("zk-auth OPAQUE"))
+	keySeed := expand(prk, []byte("ServerKey"), opaqueSeedLength)
+	privateKey, publicKey, err := deriveDiffieHellmanKeyPair(keySeed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OPAQUEServer{
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		oprfSeed:   expand(prk, []byte("OprfSeed"), opaqueHashLength),
+		context:    []byte(context),
+	}, nil
+}
+
+// PublicKey returns the server's long-term public key
+func (s *OPAQUEServer) PublicKey() []byte {
+	return s.publicKey.bytes()
+}
+
+// RegistrationResponse evaluates the client's blinded password for
+// credentialID and returns evaluated_message || server_public_key
+func (s *OPAQUEServer) RegistrationResponse(credentialID, request []byte) ([]byte, error) {
+	evaluated, err := s.evaluate(credentialID, request)
+	if err != nil {
+		return nil, err
+	}
+	return concat(evaluated, s.PublicKey()), nil
+}
+
+// OPAQUEServerLogin is the server state between KE2 and KE3
+type OPAQUEServerLogin struct {
+	ExpectedClientMAC []byte
+	SessionKey        []byte
+}
+
+// LoginResponse answers the client's KE1 with KE2. For unknown clients pass a
+// record from FakeRecord so the response looks the same.
+func (s *OPAQUEServer) LoginResponse(credentialID []byte, record *OPAQUERecord, clientIdentity, ke1 []byte) ([]byte, *OPAQUEServerLogin, error) {
+	maskingNonce, err := GenerateRandomBytes(opaqueNonceLength)
+	if err != nil {
+		return nil, nil, err
+	}
+	serverNonce, err := GenerateRandomBytes(opaqueNonceLength)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyshareSeed, err := GenerateRandomBytes(opaqueSeedLength)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.loginResponseWith(credentialID, record, clientIdentity, ke1, maskingNonce, serverNonce, keyshareSeed)
+}
+
+func (s *OPAQUEServer) loginResponseWith(credentialID []byte, record *OPAQUERecord, clientIdentity, ke1, maskingNonce, serverNonce, keyshareSeed []byte) ([]byte, *OPAQUEServerLogin, error) {
+	if len(ke1) != opaqueKE1Length {
+		return nil, nil, fmt.Errorf("invalid KE1 length")
+	}
+	blindedMessage := ke1[:elementLength]
+	clientKeyshare, err := parsePoint(ke1[elementLength+opaqueNonceLength:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid client keyshare: %w", err)
+	}
+	clientPublicKey, err := parsePoint(record.ClientPublicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid client public key: %w", err)
+	}
+
+	// CredentialResponse
+	evaluated, err := s.evaluate(credentialID, blindedMessage)
+	if err != nil {
+		return nil, nil, err
+	}
+	pad := expand(record.MaskingKey, concat(maskingNonce, []byte("CredentialResponsePad")), opaqueMaskedLength)
+	maskedResponse := xorBytes(pad, concat(s.PublicKey(), record.Envelope))
+	credentialResponse := concat(evaluated, maskingNonce, maskedResponse)
+
+	// AuthResponse
+	serverSecret, serverKeyshare, err := deriveDiffieHellmanKeyPair(keyshareSeed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if clientIdentity == nil {
+		clientIdentity = record.ClientPublicKey
+	}
+	preamble := s.preamble(clientIdentity, ke1, s.PublicKey(), credentialResponse, serverNonce, serverKeyshare.bytes())
+	ikm := concat(
+		clientKeyshare.mul(serverSecret).bytes(),
+		clientKeyshare.mul(s.privateKey).bytes(),
+		clientPublicKey.mul(serverSecret).bytes(),
+	)
+	km2, km3, sessionKey := deriveOPAQUEKeys(ikm, preamble)
+
+	serverMAC := mac(km2, sha256Sum(preamble))
+	expectedClientMAC := mac(km3, sha256Sum(concat(preamble, serverMAC)))
+
+	ke2 := concat(credentialResponse, serverNonce, serverKeyshare.bytes(), serverMAC)
+	return ke2, &OPAQUEServerLogin{
+		ExpectedClientMAC: expectedClientMAC,
+		SessionKey:        sessionKey,
+	}, nil
+}
+
+// Finish checks the client's KE3 and returns the session key
+func (l *OPAQUEServerLogin) Finish(ke3 []byte) ([]byte, error) {
+	if !ConstantTimeCompare(ke3, l.ExpectedClientMAC) {
+		return nil, fmt.Errorf("invalid client MAC")
+	}
+	return l.SessionKey, nil
+}
+
+// FakeRecord returns a record for credentialID that no password opens. It is
+// derived from the server secret so repeated logins see the same record.
+func (s *OPAQUEServer) FakeRecord(credentialID []byte) (*OPAQUERecord, error) {
+	seed := expand(s.oprfSeed, concat(credentialID, []byte("FakeRecord")), opaqueSeedLength+opaqueHashLength)
+	_, publicKey, err := deriveDiffieHellmanKeyPair(seed[:opaqueSeedLength])
+	if err != nil {
+		return nil, err
+	}
+
+	return &OPAQUERecord{
+		ClientPublicKey: publicKey.bytes(),
+		MaskingKey:      seed[opaqueSeedLength:],
+		Envelope:        make([]byte, opaqueEnvelopeLength),
+	}, nil
+}
+
+// evaluate runs the OPRF with the key derived for credentialID
+func (s *OPAQUEServer) evaluate(credentialID, blindedMessage []byte) ([]byte, error) {
+	blinded, err := parsePoint(blindedMessage)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blinded message: %w", err)
+	}
+
+	seed := expand(s.oprfSeed, concat(credentialID, []byte("OprfKey")), scalarLength)
+	oprfKey, _, err := oprfDeriveKeyPair(seed, []byte("OPAQUE-DeriveKeyPair"))
+	if err != nil {
+		return nil, err
+	}
+
+	return blinded.mul(oprfKey).bytes(), nil
+}
+
+func (s *OPAQUEServer) preamble(clientIdentity, ke1, serverIdentity, credentialResponse, serverNonce, serverKeyshare []byte) []byte {
+	return opaquePreamble(s.context, clientIdentity, ke1, serverIdentity, credentialResponse, serverNonce, serverKeyshare)
+}
+
+// OPAQUEClient runs the client side of registration and login
+type OPAQUEClient struct {
+	context []byte
+}
+
+// NewOPAQUEClient creates a client for a server using the same context
+func NewOPAQUEClient(context string) *OPAQUEClient {
+	return &OPAQUEClient{context: []byte(context)}
+}
+
+// OPAQUEClientRegistration is the client state between the registration
+// request and the server's response
+type OPAQUEClientRegistration struct {
+	password []byte
+	blind    *big.Int
+}
+
+// StartRegistration blinds password and returns the registration request
+func (c *OPAQUEClient) StartRegistration(password []byte) (*OPAQUEClientRegistration, []byte, error) {
+	blind, blinded, err := oprfBlind(password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &OPAQUEClientRegistration{password: password, blind: blind}, blinded.bytes(), nil
+}
+
+// Finish builds the registration record from the server's response, with ksf
+// as the key stretching function. The export key is a secret only the client
+// can recompute at login.
+func (r *OPAQUEClientRegistration) Finish(ksf KDFParams, clientIdentity, response []byte) (*OPAQUERecord, []byte, error) {
+	envelopeNonce, err := GenerateRandomBytes(opaqueNonceLength)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.finishWith(ksf, clientIdentity, response, envelopeNonce)
+}
+
+func (r *OPAQUEClientRegistration) finishWith(ksf KDFParams, clientIdentity, response, envelopeNonce []byte) (*OPAQUERecord, []byte, error) {
+	if len(response) != 2*elementLength {
+		return nil, nil, fmt.Errorf("invalid registration response length")
+	}
+	evaluated, err := parsePoint(response[:elementLength])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid evaluated message: %w", err)
+	}
+	serverPublicKey := response[elementLength:]
+	if _, err := parsePoint(serverPublicKey); err != nil {
+		return nil, nil, fmt.Errorf("invalid server public key: %w", err)
+	}
+
+	randomizedPassword, err := randomizedPassword(ksf, r.password, r.blind, evaluated)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, clientPublicKey, authKey, exportKey, err := envelopeKeys(randomizedPassword, envelopeNonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	if clientIdentity == nil {
+		clientIdentity = clientPublicKey.bytes()
+	}
+
+	authTag := mac(authKey, concat(envelopeNonce, cleartextCredentials(serverPublicKey, clientIdentity)))
+
+	return &OPAQUERecord{
+		ClientPublicKey: clientPublicKey.bytes(),
+		MaskingKey:      expand(randomizedPassword, []byte("MaskingKey"), opaqueHashLength),
+		Envelope:        concat(envelopeNonce, authTag),
+	}, exportKey, nil
+}
+
+// OPAQUEClientLogin is the client state between KE1 and KE2
+type OPAQUEClientLogin struct {
+	client       *OPAQUEClient
+	password     []byte
+	blind        *big.Int
+	clientSecret *big.Int
+	ke1          []byte
+}
+
+// StartLogin returns KE1
+func (c *OPAQUEClient) StartLogin(password []byte) (*OPAQUEClientLogin, []byte, error) {
+	blind, err := randomScalar()
+	if err != nil {
+		return nil, nil, err
+	}
+	clientNonce, err := GenerateRandomBytes(opaqueNonceLength)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyshareSeed, err := GenerateRandomBytes(opaqueSeedLength)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.startLoginWith(password, blind, clientNonce, keyshareSeed)
+}
+
+func (c *OPAQUEClient) startLoginWith(password []byte, blind *big.Int, clientNonce, keyshareSeed []byte) (*OPAQUEClientLogin, []byte, error) {
+	blind, blinded, err := oprfBlindWith(password, blind)
+	if err != nil {
+		return nil, nil, err
+	}
+	clientSecret, clientKeyshare, err := deriveDiffieHellmanKeyPair(keyshareSeed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ke1 := concat(blinded.bytes(), clientNonce, clientKeyshare.bytes())
+	return &OPAQUEClientLogin{
+		client:       c,
+		password:     password,
+		blind:        blind,
+		clientSecret: clientSecret,
+		ke1:          ke1,
+	}, ke1, nil
+}
+
+// Finish recovers the client's credentials from KE2 using the key stretching
+// function they were registered with, authenticates the server and returns
+// KE3 along with the session and export keys
+func (l *OPAQUEClientLogin) Finish(ksf KDFParams, clientIdentity, ke2 []byte) (ke3, sessionKey, exportKey []byte, err error) {
+	if len(ke2) != opaqueKE2Length {
+		return nil, nil, nil, fmt.Errorf("invalid KE2 length")
+	}
+	credentialResponse := ke2[:elementLength+opaqueNonceLength+opaqueMaskedLength]
+	rest := ke2[len(credentialResponse):]
+	serverNonce := rest[:opaqueNonceLength]
+	serverKeyshareBytes := rest[opaqueNonceLength : opaqueNonceLength+elementLength]
+	serverMAC := rest[opaqueNonceLength+elementLength:]
+
+	evaluated, err := parsePoint(credentialResponse[:elementLength])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid evaluated message: %w", err)
+	}
+	serverKeyshare, err := parsePoint(serverKeyshareBytes)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid server keyshare: %w", err)
+	}
+
+	// Recover the envelope
+	randomizedPassword, err := randomizedPassword(ksf, l.password, l.blind, evaluated)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	maskingKey := expand(randomizedPassword, []byte("MaskingKey"), opaqueHashLength)
+	maskingNonce := credentialResponse[elementLength : elementLength+opaqueNonceLength]
+	pad := expand(maskingKey, concat(maskingNonce, []byte("CredentialResponsePad")), opaqueMaskedLength)
+	unmasked := xorBytes(pad, credentialResponse[elementLength+opaqueNonceLength:])
+	serverPublicKeyBytes := unmasked[:elementLength]
+	envelope := unmasked[elementLength:]
+
+	serverPublicKey, err := parsePoint(serverPublicKeyBytes)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("envelope recovery failed")
+	}
+
+	envelopeNonce := envelope[:opaqueNonceLength]
+	clientPrivateKey, clientPublicKey, authKey, exportKey, err := envelopeKeys(randomizedPassword, envelopeNonce)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if clientIdentity == nil {
+		clientIdentity = clientPublicKey.bytes()
+	}
+	expectedTag := mac(authKey, concat(envelopeNonce, cleartextCredentials(serverPublicKeyBytes, clientIdentity)))
+	if !ConstantTimeCompare(envelope[opaqueNonceLength:], expectedTag) {
+		return nil, nil, nil, fmt.Errorf("envelope recovery failed")
+	}
+
+	// 3DH
+	preamble := opaquePreamble(l.client.context, clientIdentity, l.ke1, serverPublicKeyBytes, credentialResponse, serverNonce, serverKeyshareBytes)
+	ikm := concat(
+		serverKeyshare.mul(l.clientSecret).bytes(),
+		serverPublicKey.mul(l.clientSecret).bytes(),
+		serverKeyshare.mul(clientPrivateKey).bytes(),
+	)
+	km2, km3, sessionKey := deriveOPAQUEKeys(ikm, preamble)
+
+	if !ConstantTimeCompare(serverMAC, mac(km2, sha256Sum(preamble))) {
+		return nil, nil, nil, fmt.Errorf("invalid server MAC")
+	}
+
+	ke3 = mac(km3, sha256Sum(concat(preamble, serverMAC)))
+	return ke3, sessionKey, exportKey, nil
+}
+
+// randomizedPassword = Extract("", oprf_output || Stretch(oprf_output))
+func randomizedPassword(ksf KDFParams, password []byte, blind *big.Int, evaluated *point) ([]byte, error) {
+	oprfOutput := oprfFinalize(password, blind, evaluated)
+
+	stretched := oprfOutput
+	if ksf.Algorithm != KDFNone && ksf.Algorithm != "" {
+		var err error
+		if stretched, err = ksf.Stretch(oprfOutput, ksfSalt); err != nil {
+			return nil, fmt.Errorf("failed to stretch OPRF output: %w", err)
+		}
+	}
+
+	return hkdf.Extract(sha256.New, concat(oprfOutput, stretched), nil), nil
+}
+
+// envelopeKeys derives the client key pair, auth key and export key
+func envelopeKeys(randomizedPassword, nonce []byte) (*big.Int, *point, []byte, []byte, error) {
+	seed := expand(randomizedPassword, concat(nonce, []byte("PrivateKey")), opaqueSeedLength)
+	privateKey, publicKey, err := deriveDiffieHellmanKeyPair(seed)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	authKey := expand(randomizedPassword, concat(nonce, []byte("AuthKey")), opaqueHashLength)
+	exportKey := expand(randomizedPassword, concat(nonce, []byte("ExportKey")), opaqueHashLength)
+	return privateKey, publicKey, authKey, exportKey, nil
+}
+
+// cleartextCredentials serializes the credentials bound by the envelope. The
+// server identity is always its public key.
+func cleartextCredentials(serverPublicKey, clientIdentity []byte) []byte {
+	return concat(serverPublicKey, lengthPrefixed(serverPublicKey), lengthPrefixed(clientIdentity))
+}
+
+func opaquePreamble(context, clientIdentity, ke1, serverIdentity, credentialResponse, serverNonce, serverKeyshare []byte) []byte {
+	return concat(
+		[]byte("OPAQUEv1-"),
+		lengthPrefixed(context),
+		lengthPrefixed(clientIdentity),
+		ke1,
+		lengthPrefixed(serverIdentity),
+		credentialResponse,
+		serverNonce,
+		serverKeyshare,
+	)
+}
+
+// deriveOPAQUEKeys returns the server MAC key, client MAC key and session key
+func deriveOPAQUEKeys(ikm, preamble []byte) (km2, km3, sessionKey []byte) {
+	prk := hkdf.Extract(sha256.New, ikm, nil)
+	preambleHash := sha256Sum(preamble)
+
+	handshakeSecret := deriveSecret(prk, "HandshakeSecret", preambleHash)
+	sessionKey = deriveSecret(prk, "SessionKey", preambleHash)
+	km2 = deriveSecret(handshakeSecret, "ServerMAC", nil)
+	km3 = deriveSecret(handshakeSecret, "ClientMAC", nil)
+	return km2, km3, sessionKey
+}
+
+// deriveSecret is Expand-Label(secret, label, context, Nx)
+func deriveSecret(secret []byte, label string, context []byte) []byte {
+	fullLabel := "OPAQUE-" + label
+	info := concat(
+		[]byte{0, opaqueHashLength},
+		[]byte{byte(len(fullLabel))}, []byte(fullLabel),
+		[]byte{byte(len(context))}, context,
+	)
+	return expand(secret, info, opaqueHashLength)
+}
+
+func deriveDiffieHellmanKeyPair(seed []byte) (*big.Int, *point, error) {
+	return oprfDeriveKeyPair(seed, []byte("OPAQUE-DeriveDiffieHellmanKeyPair"))
+}
+
+func expand(prk, info []byte, length int) []byte {
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, info), out); err != nil {
+		// Only reachable for lengths above 255 * Nh
+		panic(err)
+	}
+	return out
+}
+
+func mac(key, msg []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(msg)
+	return h.Sum(nil)
+}
+
+func sha256Sum(b []byte) []byte {
+	sum := sha256.Sum256(b)
+	return sum[:]
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func xorBytes(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
@@ -0,0 +1,229 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestHashToCurve_P256Vectors(t *testing.T) {
+	dst := []byte("QUUX-V01-CS02-with-P256_XMD:SHA-256_SSWU_RO_")
+	vectors := []struct{ msg, x, y string }{
+		{"", "2c15230b26dbc6fc9a37051158c95b79656e17a1a920b11394ca91c44247d3e4", "8a7a74985cc5c776cdfe4b1f19884970453912e9d31528c060be9ab5c43e8415"},
+		{"abc", "0bb8b87485551aa43ed54f009230450b492fead5f1cc91658775dac4a3388a0f", "5c41b3d0731a27a7b14bc0bf0ccded2d8751f83493404c84a88e71ffd424212e"},
+	}
+
+	for _, v := range vectors {
+		x, y, err := hashToCurve([]byte(v.msg), dst)
+		if err != nil {
+			t.Fatalf("hash to curve failed: %v", err)
+		}
+		if got := hex.EncodeToString(PadTo(x.Bytes(), 32)); got != v.x {
+			t.Errorf("%q: x = %s, want %s", v.msg, got, v.x)
+		}
+		if got := hex.EncodeToString(PadTo(y.Bytes(), 32)); got != v.y {
+			t.Errorf("%q: y = %s, want %s", v.msg, got, v.y)
+		}
+	}
+}
+
+func TestOPRF_P256Vector(t *testing.T) {
+	seed := bytes.Repeat([]byte{0xa3}, 32)
+	sk, _, err := oprfDeriveKeyPair(seed, []byte("test key"))
+	if err != nil {
+		t.Fatalf("failed to derive key pair: %v", err)
+	}
+	if got := hex.EncodeToString(scalarBytes(sk)); got != "159749d750713afe245d2d39ccfaae8381c53ce92d098a9375ee70739c7ac0bf" {
+		t.Errorf("skS = %s", got)
+	}
+
+	input := []byte{0x00}
+	blind, blinded, err := oprfBlindWith(input, hexInt("3338fa65ec36e0290022b48eb562889d89dbfa691d1cde91517fa222ed7ad364"))
+	if err != nil {
+		t.Fatalf("failed to blind: %v", err)
+	}
+	if got := hex.EncodeToString(blinded.bytes()); got != "03723a1e5c09b8b9c18d1dcbca29e8007e95f14f4732d9346d490ffc195110368d" {
+		t.Errorf("blinded element = %s", got)
+	}
+
+	evaluated := blinded.mul(sk)
+	if got := hex.EncodeToString(evaluated.bytes()); got != "030de02ffec47a1fd53efcdd1c6faf5bdc270912b8749e783c7ca75bb412958832" {
+		t.Errorf("evaluated element = %s", got)
+	}
+
+	if got := hex.EncodeToString(oprfFinalize(input, blind, evaluated)); got != "a0b34de5fa4c5b6da07e72af73cc507cceeb48981b97b7285fc375345fe495dd" {
+		t.Errorf("output = %s", got)
+	}
+}
+
+func TestOPAQUE_RegisterAndLogin(t *testing.T) {
+	server, err := NewOPAQUEServer(bytes.Repeat([]byte{0x42}, 32), "test")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	client := NewOPAQUEClient("test")
+	credentialID, identity := []byte("alice"), []byte("alice")
+
+	record, exportKey := register(t, server, client, credentialID, identity, "password123")
+
+	login, ke1, err := client.StartLogin([]byte("password123"))
+	if err != nil {
+		t.Fatalf("failed to start login: %v", err)
+	}
+	ke2, serverLogin, err := server.LoginResponse(credentialID, record, identity, ke1)
+	if err != nil {
+		t.Fatalf("failed to create KE2: %v", err)
+	}
+	ke3, clientKey, loginExportKey, err := login.Finish(noKDF, identity, ke2)
+	if err != nil {
+		t.Fatalf("client failed to finish login: %v", err)
+	}
+	serverKey, err := serverLogin.Finish(ke3)
+	if err != nil {
+		t.Fatalf("server rejected KE3: %v", err)
+	}
+
+	if !bytes.Equal(clientKey, serverKey) {
+		t.Error("client and server session keys differ")
+	}
+	if !bytes.Equal(exportKey, loginExportKey) {
+		t.Error("export key differs between registration and login")
+	}
+}
+
+// RFC 9807 Appendix C.1.5, OPAQUE-3DH with P256-SHA256 and the identity KSF
+func TestOPAQUE_RFC9807Vector(t *testing.T) {
+	unhex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatalf("invalid hex %q: %v", s, err)
+		}
+		return b
+	}
+	check := func(name string, got []byte, want string) {
+		t.Helper()
+		if g := hex.EncodeToString(got); g != want {
+			t.Errorf("%s = %s, want %s", name, g, want)
+		}
+	}
+
+	context := []byte("OPAQUE-POC")
+	credentialID := unhex("31323334")
+	password := unhex("436f7272656374486f72736542617474657279537461706c65")
+	serverPrivateKey := hexInt("c36139381df63bfc91c850db0b9cfbec7a62e86d80040a41aa7725bf0e79d5e5")
+	server := &OPAQUEServer{
+		privateKey: serverPrivateKey,
+		publicKey:  baseMul(serverPrivateKey),
+		oprfSeed:   unhex("62f60b286d20ce4fd1d64809b0021dad6ed5d52a2c8cf27ae6582543a0a8dce2"),
+		context:    context,
+	}
+	client := &OPAQUEClient{context: context}
+	check("server_public_key", server.PublicKey(), "035f40ff9cf88aa1f5cd4fe5fd3da9ea65a4923a5594f84fd9f2092d6067784874")
+
+	// Registration
+	blind, blinded, err := oprfBlindWith(password, hexInt("411bf1a62d119afe30df682b91a0a33d777972d4f2daa4b34ca527d597078153"))
+	if err != nil {
+		t.Fatalf("failed to blind: %v", err)
+	}
+	check("registration_request", blinded.bytes(), "029e949a29cfa0bf7c1287333d2fb3dc586c41aa652f5070d26a5315a1b50229f8")
+
+	response, err := server.RegistrationResponse(credentialID, blinded.bytes())
+	if err != nil {
+		t.Fatalf("failed to create registration response: %v", err)
+	}
+	check("registration_response", response, "0350d3694c00978f00a5ce7cd08a00547e4ab5fb5fc2b2f6717cdaa6c89136efef035f40ff9cf88aa1f5cd4fe5fd3da9ea65a4923a5594f84fd9f2092d6067784874")
+
+	registration := &OPAQUEClientRegistration{password: password, blind: blind}
+	record, exportKey, err := registration.finishWith(noKDF, nil, response, unhex("a921f2a014513bd8a90e477a629794e89fec12d12206dde662ebdcf65670e51f"))
+	if err != nil {
+		t.Fatalf("failed to finish registration: %v", err)
+	}
+	check("registration_upload", record.Bytes(), "03b218507d978c3db570ca994aaf36695a731ddb2db272c817f79746fc37ae52147f0ed53532d3ae8e505ecc70d42d2b814b6b0e48156def71ea029148b2803aafa921f2a014513bd8a90e477a629794e89fec12d12206dde662ebdcf65670e51fad30bbcfc1f8eda0211553ab9aaf26345ad59a128e80188f035fe4924fad67b8")
+	check("export_key", exportKey, "c3c9a1b0e33ac84dd83d0b7e8af6794e17e7a3caadff289fbd9dc769a853c64b")
+
+	// Login
+	login, ke1, err := client.startLoginWith(password,
+		hexInt("c497fddf6056d241e6cf9fb7ac37c384f49b357a221eb0a802c989b9942256c1"),
+		unhex("ab3d33bde0e93eda72392346a7a73051110674bbf6b1b7ffab8be4f91fdaeeb1"),
+		unhex("633b875d74d1556d2a2789309972b06db21dfcc4f5ad51d7e74d783b7cfab8dc"))
+	if err != nil {
+		t.Fatalf("failed to start login: %v", err)
+	}
+	check("KE1", ke1, "037342f0bcb3ecea754c1e67576c86aa90c1de3875f390ad599a26686cdfee6e07ab3d33bde0e93eda72392346a7a73051110674bbf6b1b7ffab8be4f91fdaeeb1022ed3f32f318f81bab80da321fecab3cd9b6eea11a95666dfa6beeaab321280b6")
+
+	ke2, serverLogin, err := server.loginResponseWith(credentialID, record, nil, ke1,
+		unhex("38fe59af0df2c79f57b8780278f5ae47355fe1f817119041951c80f612fdfc6d"),
+		unhex("71cd9960ecef2fe0d0f7494986fa3d8b2bb01963537e60efb13981e138e3d4a1"),
+		unhex("05a4f54206eef1ba2f615bc0aa285cb22f26d1153b5b40a1e85ff80da12f982f"))
+	if err != nil {
+		t.Fatalf("failed to create KE2: %v", err)
+	}
+	check("KE2", ke2, "0246da9fe4d41d5ba69faa6c509a1d5bafd49a48615a47a8dd4b0823cc1476481138fe59af0df2c79f57b8780278f5ae47355fe1f817119041951c80f612fdfc6d2f0c547f70deaeca54d878c14c1aa5e1ab405dec833777132eea905c2fbb12504a67dcbe0e66740c76b62c13b04a38a77926e19072953319ec65e41f9bfd2ae26837b6ce688bf9af2542f04eec9ab96a1b9328812dc2f5c89182ed47fead61f09f71cd9960ecef2fe0d0f7494986fa3d8b2bb01963537e60efb13981e138e3d4a103c1701353219b53acf337bf6456a83cefed8f563f1040b65afbf3b65d3bc9a19b50a73b145bc87a157e8c58c0342e2047ee22ae37b63db17e0a82a30fcc4ecf7b")
+
+	ke3, clientKey, loginExportKey, err := login.Finish(noKDF, nil, ke2)
+	if err != nil {
+		t.Fatalf("client failed to finish login: %v", err)
+	}
+	check("KE3", ke3, "e97cab4433aa39d598e76f13e768bba61c682947bdcf9936035e8a3a3ebfb66e")
+	check("session_key", clientKey, "484ad345715ccce138ca49e4ea362c6183f0949aaaa1125dc3bc3f80876e7cd1")
+	check("export_key at login", loginExportKey, "c3c9a1b0e33ac84dd83d0b7e8af6794e17e7a3caadff289fbd9dc769a853c64b")
+
+	serverKey, err := serverLogin.Finish(ke3)
+	if err != nil {
+		t.Fatalf("server rejected KE3: %v", err)
+	}
+	check("server session_key", serverKey, "484ad345715ccce138ca49e4ea362c6183f0949aaaa1125dc3bc3f80876e7cd1")
+}
+
+func TestOPAQUE_RejectsWrongPasswordAndFakeRecords(t *testing.T) {
+	server, err := NewOPAQUEServer(bytes.Repeat([]byte{0x42}, 32), "test")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	client := NewOPAQUEClient("test")
+	credentialID := []byte("alice")
+
+	record, _ := register(t, server, client, credentialID, nil, "password123")
+	fake, err := server.FakeRecord([]byte("mallory"))
+	if err != nil {
+		t.Fatalf("failed to create fake record: %v", err)
+	}
+
+	cases := map[string]*OPAQUERecord{"wrong password": record, "fake record": fake}
+	for name, rec := range cases {
+		login, ke1, err := client.StartLogin([]byte("wrong password"))
+		if err != nil {
+			t.Fatalf("failed to start login: %v", err)
+		}
+		ke2, _, err := server.LoginResponse(credentialID, rec, nil, ke1)
+		if err != nil {
+			t.Fatalf("%s: failed to create KE2: %v", name, err)
+		}
+		if _, _, _, err := login.Finish(noKDF, nil, ke2); err == nil {
+			t.Errorf("%s: login should fail", name)
+		}
+	}
+}
+
+func register(t *testing.T, server *OPAQUEServer, client *OPAQUEClient, credentialID, identity []byte, password string) (*OPAQUERecord, []byte) {
+	t.Helper()
+
+	registration, request, err := client.StartRegistration([]byte(password))
+	if err != nil {
+		t.Fatalf("failed to start registration: %v", err)
+	}
+	response, err := server.RegistrationResponse(credentialID, request)
+	if err != nil {
+		t.Fatalf("failed to create registration response: %v", err)
+	}
+	record, exportKey, err := registration.Finish(noKDF, identity, response)
+	if err != nil {
+		t.Fatalf("failed to finish registration: %v", err)
+	}
+
+	parsed, err := ParseOPAQUERecord(record.Bytes())
+	if err != nil {
+		t.Fatalf("failed to parse record: %v", err)
+	}
+	return parsed, exportKey
+}
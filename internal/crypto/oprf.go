@@ -0,0 +1,144 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// OPRF in base mode with the P256-SHA256 suite from RFC 9497.
+// https://www.rfc-editor.org/rfc/rfc9497
+
+// oprfContext is contextString = "OPRFV1-" || I2OSP(mode, 1) || "-" || identifier
+var oprfContext = append(append([]byte("OPRFV1-"), 0x00), []byte("-P256-SHA256")...)
+
+// Sizes of serialized P-256 elements and scalars
+const (
+	elementLength = 33
+	scalarLength  = 32
+)
+
+// point is an element of P-256 in affine coordinates
+type point struct {
+	x, y *big.Int
+}
+
+func (p *point) isIdentity() bool {
+	return p.x.Sign() == 0 && p.y.Sign() == 0
+}
+
+func (p *point) mul(k *big.Int) *point {
+	x, y := p256.ScalarMult(p.x, p.y, scalarBytes(k))
+	return &point{x, y}
+}
+
+// bytes returns the compressed SEC1 encoding
+func (p *point) bytes() []byte {
+	return elliptic.MarshalCompressed(p256, p.x, p.y)
+}
+
+func baseMul(k *big.Int) *point {
+	x, y := p256.ScalarBaseMult(scalarBytes(k))
+	return &point{x, y}
+}
+
+// parsePoint decodes a compressed element, rejecting the identity and
+// points that are not on the curve
+func parsePoint(b []byte) (*point, error) {
+	if len(b) != elementLength {
+		return nil, fmt.Errorf("invalid element length")
+	}
+	x, y := elliptic.UnmarshalCompressed(p256, b)
+	if x == nil {
+		return nil, fmt.Errorf("invalid element")
+	}
+	return &point{x, y}, nil
+}
+
+func scalarBytes(k *big.Int) []byte {
+	return PadTo(k.Bytes(), scalarLength)
+}
+
+// randomScalar returns a uniformly random non-zero scalar
+func randomScalar() (*big.Int, error) {
+	n := p256.Params().N
+	k, err := rand.Int(rand.Reader, new(big.Int).Sub(n, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, big.NewInt(1)), nil
+}
+
+func oprfHashToGroup(input []byte) (*point, error) {
+	x, y, err := hashToCurve(input, append([]byte("HashToGroup-"), oprfContext...))
+	if err != nil {
+		return nil, err
+	}
+	return &point{x, y}, nil
+}
+
+func oprfHashToScalar(input, dst []byte) (*big.Int, error) {
+	scalars, err := hashToField(input, dst, p256.Params().N, 1)
+	if err != nil {
+		return nil, err
+	}
+	return scalars[0], nil
+}
+
+// oprfDeriveKeyPair deterministically derives an OPRF or Diffie-Hellman key
+// pair from seed and info
+func oprfDeriveKeyPair(seed, info []byte) (*big.Int, *point, error) {
+	deriveInput := append(append([]byte{}, seed...), lengthPrefixed(info)...)
+	dst := append([]byte("DeriveKeyPair"), oprfContext...)
+
+	for counter := 0; counter < 256; counter++ {
+		sk, err := oprfHashToScalar(append(deriveInput, byte(counter)), dst)
+		if err != nil {
+			return nil, nil, err
+		}
+		if sk.Sign() != 0 {
+			return sk, baseMul(sk), nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("failed to derive key pair")
+}
+
+// oprfBlind maps input to the group and blinds it with a random scalar
+func oprfBlind(input []byte) (blind *big.Int, blinded *point, err error) {
+	blind, err = randomScalar()
+	if err != nil {
+		return nil, nil, err
+	}
+	return oprfBlindWith(input, blind)
+}
+
+func oprfBlindWith(input []byte, blind *big.Int) (*big.Int, *point, error) {
+	element, err := oprfHashToGroup(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	if element.isIdentity() {
+		return nil, nil, fmt.Errorf("input maps to the identity element")
+	}
+	return blind, element.mul(blind), nil
+}
+
+// oprfFinalize unblinds the server's evaluation and hashes it with input
+func oprfFinalize(input []byte, blind *big.Int, evaluated *point) []byte {
+	inverse := new(big.Int).ModInverse(blind, p256.Params().N)
+	unblinded := evaluated.mul(inverse).bytes()
+
+	h := sha256.New()
+	h.Write(lengthPrefixed(input))
+	h.Write(lengthPrefixed(unblinded))
+	h.Write([]byte("Finalize"))
+	return h.Sum(nil)
+}
+
+// lengthPrefixed returns I2OSP(len(b), 2) || b
+func lengthPrefixed(b []byte) []byte {
+	return append([]byte{byte(len(b) >> 8), byte(len(b))}, b...)
+}
@@ -0,0 +1,166 @@
+package crypto
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// RFC 5054 appendix B test vectors, computed with SHA-1 over the 1024-bit
+// group from appendix A. That group is too small to offer for new accounts,
+// so it is only defined here.
+// https://tools.ietf.org/html/rfc5054#appendix-B
+var (
+	rfc5054Group = newGroup(1024, 2, `
+		EEAF0AB9 ADB38DD6 9C33F80A FA8FC5E8 60726187 75FF3C0B 9EA2314C
+		9C256576 D674DF74 96EA81D3 383B4813 D692C6E0 E0D5D8E2 50B98BE4
+		8E495C1D 6089DAD1 5DC7D7B4 6154D6B6 CE8EF4AD 69B15D49 82559B29
+		7BCF1885 C529F566 660E57EC 68EDBC3C 05726CC0 2FD4CBF4 976EAA9A
+		FD5138FE 8376435B 9FC61D2F C0EB06E3`)
+
+	rfc5054I = "alice"
+	rfc5054P = "password123"
+	rfc5054S = hexBytes(`BEB25379 D1A8581E B5A72767 3A2441EE`)
+	rfc5054K = hexInt(`7556AA04 5AEF2CDD 07ABAF0F 665C3E81 8913186F`)
+	rfc5054X = hexInt(`94B7555A ABE9127C C58CCF49 93DB6CF8 4D16C124`)
+	rfc5054V = hexInt(`
+		7E273DE8 696FFC4F 4E337D05 B4B375BE B0DDE156 9E8FA00A 9886D812
+		9BADA1F1 822223CA 1A605B53 0E379BA4 729FDC59 F105B478 7E5186F5
+		C671085A 1447B52A 48CF1970 B4FB6F84 00BBF4CE BFBB1681 52E08AB5
+		EA53D15C 1AFF87B2 B9DA6E04 E058AD51 CC72BFC9 033B564E 26480D78
+		E955A5E2 9E7AB245 DB2BE315 E2099AFB`)
+	rfc5054a = hexInt(`
+		60975527 035CF2AD 1989806F 0407210B C81EDC04 E2762A56 AFD529DD
+		DA2D4393`)
+	rfc5054b = hexInt(`
+		E487CB59 D31AC550 471E81F0 0F6928E0 1DDA08E9 74A004F4 9E61F5D1
+		05284D20`)
+	rfc5054A = hexInt(`
+		61D5E490 F6F1B795 47B0704C 436F523D D0E560F0 C64115BB 72557EC4
+		4352E890 3211C046 92272D8B 2D1A5358 A2CF1B6E 0BFCF99F 921530EC
+		8E393561 79EAE45E 42BA92AE ACED8251 71E1E8B9 AF6D9C03 E1327F44
+		BE087EF0 6530E69F 66615261 EEF54073 CA11CF58 58F0EDFD FE15EFEA
+		B349EF5D 76988A36 72FAC47B 0769447B`)
+	rfc5054B = hexInt(`
+		BD0C6151 2C692C0C B6D041FA 01BB152D 4916A1E7 7AF46AE1 05393011
+		BAF38964 DC46A067 0DD125B9 5A981652 236F99D9 B681CBF8 7837EC99
+		6C6DA044 53728610 D0C6DDB5 8B318885 D7D82C7F 8DEB75CE 7BD4FBAA
+		37089E6F 9C6059F3 88838E7A 00030B33 1EB76840 910440B1 B27AAEAE
+		EB4012B7 D7665238 A8E3FB00 4B117B58`)
+	rfc5054U       = hexInt(`CE38B959 3487DA98 554ED47D 70A7AE5F 462EF019`)
+	rfc5054Premast = hexInt(`
+		B0DC82BA BCF30674 AE450C02 87745E79 90A3381F 63B387AA F271A10D
+		233861E3 59B48220 F7C4693C 9AE12B0A 6F67809F 0876E2D0 13800D6C
+		41BB59B6 D5979B5C 00A172B4 A2A5903A 0BDCAF8A 709585EB 2AFAFA8F
+		3499B200 210DCC1F 10EB3394 3CD67FC8 8A2F39A4 BE5BEC4E C0A3212D
+		C346D7E4 74B29EDE 8A469FFE CA686E5A`)
+)
+
+func TestRFC5054_Vectors(t *testing.T) {
+	srp := NewSRP(rfc5054Group, SHA1, ModeRFC5054)
+
+	if srp.K.Cmp(rfc5054K) != 0 {
+		t.Errorf("k = %X, want %X", srp.K, rfc5054K)
+	}
+
+	x, err := srp.ComputeX(rfc5054I, rfc5054P, rfc5054S, noKDF)
+	if err != nil {
+		t.Fatalf("failed to compute x: %v", err)
+	}
+	if x.Cmp(rfc5054X) != 0 {
+		t.Errorf("x = %X, want %X", x, rfc5054X)
+	}
+
+	v, err := srp.ComputeVerifier(rfc5054I, rfc5054P, rfc5054S, noKDF)
+	if err != nil {
+		t.Fatalf("failed to compute verifier: %v", err)
+	}
+	if v.Cmp(rfc5054V) != 0 {
+		t.Errorf("v = %X, want %X", v, rfc5054V)
+	}
+
+	if A := new(big.Int).Exp(srp.G, rfc5054a, srp.N); A.Cmp(rfc5054A) != 0 {
+		t.Errorf("A = %X, want %X", A, rfc5054A)
+	}
+	if B := srp.computeB(rfc5054V, rfc5054b); B.Cmp(rfc5054B) != 0 {
+		t.Errorf("B = %X, want %X", B, rfc5054B)
+	}
+
+	u := srp.ComputeU(rfc5054A, rfc5054B)
+	if u.Cmp(rfc5054U) != 0 {
+		t.Errorf("u = %X, want %X", u, rfc5054U)
+	}
+
+	clientS, err := srp.computeClientS(rfc5054B, rfc5054a, rfc5054X, rfc5054U)
+	if err != nil {
+		t.Fatalf("failed to compute client premaster secret: %v", err)
+	}
+	if clientS.Cmp(rfc5054Premast) != 0 {
+		t.Errorf("client S = %X, want %X", clientS, rfc5054Premast)
+	}
+
+	serverS, err := srp.computeServerS(rfc5054A, rfc5054b, rfc5054V, rfc5054U)
+	if err != nil {
+		t.Fatalf("failed to compute server premaster secret: %v", err)
+	}
+	if serverS.Cmp(rfc5054Premast) != 0 {
+		t.Errorf("server S = %X, want %X", serverS, rfc5054Premast)
+	}
+}
+
+func TestRFC5054_SessionKeyAndProofsArePadded(t *testing.T) {
+	srp := NewSRP(rfc5054Group, SHA1, ModeRFC5054)
+	size := rfc5054Group.Size()
+
+	K, err := srp.ComputeClientSessionKey(rfc5054B, rfc5054a, rfc5054X, rfc5054U)
+	if err != nil {
+		t.Fatalf("failed to compute client session key: %v", err)
+	}
+	if want := SHA1.Sum(PadTo(rfc5054Premast.Bytes(), size)); !bytes.Equal(K, want) {
+		t.Errorf("K = %X, want H(PAD(S)) = %X", K, want)
+	}
+
+	serverK, err := srp.ComputeServerSessionKey(rfc5054A, rfc5054b, rfc5054V, rfc5054U)
+	if err != nil {
+		t.Fatalf("failed to compute server session key: %v", err)
+	}
+	if !bytes.Equal(K, serverK) {
+		t.Fatalf("client and server session keys differ")
+	}
+
+	hN := SHA1.Sum(srp.N.Bytes())
+	hG := SHA1.Sum(srp.G.Bytes())
+	for i := range hN {
+		hN[i] ^= hG[i]
+	}
+	M1 := srp.ComputeClientProof(rfc5054I, rfc5054S, rfc5054A, rfc5054B, K)
+	wantM1 := SHA1.Sum(hN, SHA1.Sum([]byte(rfc5054I)), rfc5054S,
+		PadTo(rfc5054A.Bytes(), size), PadTo(rfc5054B.Bytes(), size), K)
+	if !bytes.Equal(M1, wantM1) {
+		t.Errorf("M1 = %X, want %X", M1, wantM1)
+	}
+
+	M2 := srp.ComputeServerProof(rfc5054A, M1, K)
+	if want := SHA1.Sum(PadTo(rfc5054A.Bytes(), size), M1, K); !bytes.Equal(M2, want) {
+		t.Errorf("M2 = %X, want %X", M2, want)
+	}
+}
+
+func TestRFC5054_LegacyModeKeepsMultiplierThree(t *testing.T) {
+	if k := NewSRP(Group2048, SHA256, ModeLegacy).K; k.Cmp(big.NewInt(3)) != 0 {
+		t.Errorf("legacy k = %v, want 3", k)
+	}
+}
+
+func hexInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(strings.Join(strings.Fields(s), ""), 16)
+	if !ok {
+		panic("invalid hex integer: " + s)
+	}
+	return n
+}
+
+func hexBytes(s string) []byte {
+	return hexInt(s).Bytes()
+}
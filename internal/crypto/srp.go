@@ -1,23 +1,71 @@
 package crypto
 
 import (
-	"crypto/sha256"
 	"fmt"
 	"math/big"
+	"strings"
 )
 
+// Mode selects how group elements are encoded when they are hashed
+type Mode string
+
+const (
+	// ModeLegacy uses k = 3 and hashes A, B and S without padding (except in u)
+	ModeLegacy Mode = "legacy"
+	// ModeRFC5054 follows RFC 5054 strictly: k = H(N | PAD(g)), and A, B and S
+	// are padded to the length of N wherever they are hashed
+	ModeRFC5054 Mode = "rfc5054"
+)
+
+// DefaultMode is used for verifiers that predate mode selection
+const DefaultMode = ModeLegacy
+
+// ParseMode returns the mode matching name
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(name)); mode {
+	case ModeLegacy, ModeRFC5054:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported SRP mode: %s", name)
+	}
+}
+
+// AtLeast reports whether m is at least as strict as min. RFC 5054 mode
+// satisfies a policy requiring legacy mode, but not the other way round.
+func (m Mode) AtLeast(min Mode) bool {
+	return m == min || m == ModeRFC5054
+}
+
+// Modes returns all supported modes
+func Modes() []Mode {
+	return []Mode{ModeLegacy, ModeRFC5054}
+}
+
 type SRP struct {
-	N *big.Int
-	G *big.Int
-	K *big.Int
+	N     *big.Int
+	G     *big.Int
+	K     *big.Int
+	Group *Group
+	Hash  HashAlgorithm
+	Mode  Mode
 }
 
-func NewSRP() *SRP {
-	return &SRP{
-		N: N,
-		G: G,
-		K: K,
+func NewSRP(group *Group, hash HashAlgorithm, mode Mode) *SRP {
+	s := &SRP{
+		N:     group.N,
+		G:     group.G,
+		K:     K,
+		Group: group,
+		Hash:  hash,
+		Mode:  mode,
+	}
+
+	if mode == ModeRFC5054 {
+		// k = H(N | PAD(g))
+		s.K = new(big.Int).SetBytes(hash.Sum(group.N.Bytes(), s.pad(group.G)))
 	}
+
+	return s
 }
 
 func (s *SRP) GenerateSalt() ([]byte, error) {
@@ -25,19 +73,53 @@ func (s *SRP) GenerateSalt() ([]byte, error) {
 }
 
 // Compute the password verifier v = g^x mod N
-// x = H(salt | H(username | ":" | password))
-func (s *SRP) ComputeVerifier(username, password string, salt []byte) (*big.Int, error) {
-	x := s.computeX(username, password, salt)
+// x = H(salt | KDF(username | ":" | password, salt))
+func (s *SRP) ComputeVerifier(username, password string, salt []byte, kdf KDFParams) (*big.Int, error) {
+	x, err := s.ComputeX(username, password, salt, kdf)
+	if err != nil {
+		return nil, err
+	}
 	v := new(big.Int).Exp(s.G, x, s.N)
 	return v, nil
 }
 
+// ValidateVerifier checks that a client-supplied verifier v is a usable group
+// element: 1 < v < N-1, and when g only generates the subgroup of quadratic
+// residues, v must belong to that subgroup as well.
+func (s *SRP) ValidateVerifier(v *big.Int) error {
+	one := big.NewInt(1)
+	nMinusOne := new(big.Int).Sub(s.N, one)
+
+	if v.Cmp(one) <= 0 || v.Cmp(nMinusOne) >= 0 {
+		return fmt.Errorf("verifier out of range")
+	}
+
+	// For a safe prime N = 2q + 1, g^q == 1 means g has order q.
+	q := new(big.Int).Rsh(nMinusOne, 1)
+	if new(big.Int).Exp(s.G, q, s.N).Cmp(one) == 0 &&
+		new(big.Int).Exp(v, q, s.N).Cmp(one) != 0 {
+		return fmt.Errorf("verifier is not in the subgroup generated by g")
+	}
+
+	return nil
+}
+
 // Compute the server's session key
 // S = (A * v^u)^b mod N
 // K = H(S)
 func (s *SRP) ComputeServerSessionKey(A, b, v *big.Int, u *big.Int) ([]byte, error) {
+	S, err := s.computeServerS(A, b, v, u)
+	if err != nil {
+		return nil, err
+	}
+
+	// K = H(S)
+	return s.Hash.Sum(s.encode(S)), nil
+}
+
+func (s *SRP) computeServerS(A, b, v, u *big.Int) (*big.Int, error) {
 	// Validate A
-	if A.Mod(A, s.N).Sign() == 0 {
+	if new(big.Int).Mod(A, s.N).Sign() == 0 {
 		return nil, fmt.Errorf("invalid A value")
 	}
 
@@ -45,32 +127,29 @@ func (s *SRP) ComputeServerSessionKey(A, b, v *big.Int, u *big.Int) ([]byte, err
 	vu := new(big.Int).Exp(v, u, s.N)
 	Avu := new(big.Int).Mul(A, vu)
 	Avu.Mod(Avu, s.N)
-	S := new(big.Int).Exp(Avu, b, s.N)
-
-	// K = H(S)
-	return Hash(S.Bytes()), nil
+	return new(big.Int).Exp(Avu, b, s.N), nil
 }
 
 // Compute the server proof M2 = H(A | M1 | K)
 func (s *SRP) ComputeServerProof(A *big.Int, M1, K []byte) []byte {
-	return Hash(A.Bytes(), M1, K)
+	return s.Hash.Sum(s.encode(A), M1, K)
 }
 
 // Compute the client proof M1 = H(H(N) XOR H(g) | H(username) | salt | A | B | K)
 func (s *SRP) ComputeClientProof(username string, salt []byte, A, B *big.Int, K []byte) []byte {
 	// H(N) XOR H(g)
-	hN := Hash(s.N.Bytes())
-	hG := Hash(s.G.Bytes())
+	hN := s.Hash.Sum(s.N.Bytes())
+	hG := s.Hash.Sum(s.G.Bytes())
 	hNxorG := make([]byte, len(hN))
 	for i := range hN {
 		hNxorG[i] = hN[i] ^ hG[i]
 	}
 
 	// H(username)
-	hU := Hash([]byte(username))
+	hU := s.Hash.Sum([]byte(username))
 
 	// M1 = H(H(N) XOR H(g) | H(username) | salt | A | B | K)
-	return Hash(hNxorG, hU, salt, A.Bytes(), B.Bytes(), K)
+	return s.Hash.Sum(hNxorG, hU, salt, s.encode(A), s.encode(B), K)
 }
 
 // Generates server ephemeral keys (b, B)
@@ -81,12 +160,7 @@ func (s *SRP) GenerateServerKeys(verifier *big.Int) (b, B *big.Int, err error) {
 		return nil, nil, fmt.Errorf("failed to generate b: %w", err)
 	}
 
-	// B = k*v + g^b mod N
-	gb := new(big.Int).Exp(s.G, b, s.N)
-	kv := new(big.Int).Mul(s.K, verifier)
-	B = new(big.Int).Add(kv, gb)
-	B.Mod(B, s.N)
-
+	B = s.computeB(verifier, b)
 	if B.Sign() == 0 {
 		return s.GenerateServerKeys(verifier) // Retry
 	}
@@ -94,27 +168,110 @@ func (s *SRP) GenerateServerKeys(verifier *big.Int) (b, B *big.Int, err error) {
 	return b, B, nil
 }
 
+// B = k*v + g^b mod N
+func (s *SRP) computeB(verifier, b *big.Int) *big.Int {
+	gb := new(big.Int).Exp(s.G, b, s.N)
+	kv := new(big.Int).Mul(s.K, verifier)
+	B := new(big.Int).Add(kv, gb)
+	return B.Mod(B, s.N)
+}
+
+// Generates client ephemeral keys (a, A)
+// A = g^a mod N
+func (s *SRP) GenerateClientKeys() (a, A *big.Int, err error) {
+	a, err = GenerateRandomBigInt(256)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate a: %w", err)
+	}
+
+	A = new(big.Int).Exp(s.G, a, s.N)
+	if A.Sign() == 0 {
+		return s.GenerateClientKeys() // Retry
+	}
+
+	return a, A, nil
+}
+
+// Compute the client's session key
+// S = (B - k*g^x)^(a + u*x) mod N
+// K = H(S)
+func (s *SRP) ComputeClientSessionKey(B, a, x, u *big.Int) ([]byte, error) {
+	S, err := s.computeClientS(B, a, x, u)
+	if err != nil {
+		return nil, err
+	}
+
+	// K = H(S)
+	return s.Hash.Sum(s.encode(S)), nil
+}
+
+func (s *SRP) computeClientS(B, a, x, u *big.Int) (*big.Int, error) {
+	// Validate B and u, a malicious server could otherwise force S
+	if new(big.Int).Mod(B, s.N).Sign() == 0 {
+		return nil, fmt.Errorf("invalid B value")
+	}
+	if u.Sign() == 0 {
+		return nil, fmt.Errorf("invalid u value")
+	}
+
+	// S = (B - k*g^x)^(a + u*x) mod N
+	kgx := new(big.Int).Mul(s.K, new(big.Int).Exp(s.G, x, s.N))
+	base := new(big.Int).Sub(B, kgx)
+	base.Mod(base, s.N)
+	exp := new(big.Int).Mul(u, x)
+	exp.Add(exp, a)
+	return new(big.Int).Exp(base, exp, s.N), nil
+}
+
+// Verify the server's proof M2 against the client's view of the handshake
+func (s *SRP) VerifyServerProof(A *big.Int, M1, K, serverM2 []byte) bool {
+	expectedM2 := s.ComputeServerProof(A, M1, K)
+	return ConstantTimeCompare(expectedM2, serverM2)
+}
+
 // Verify the client's proof
 func (s *SRP) VerifyClientProof(username string, salt []byte, A, B *big.Int, K, clientM1 []byte) bool {
 	expectedM1 := s.ComputeClientProof(username, salt, A, B, K)
 	return ConstantTimeCompare(expectedM1, clientM1)
 }
 
-// Compute x = H(salt | H(username | ":" | password))
-func (s *SRP) computeX(username, password string, salt []byte) *big.Int {
-	// H(username | ":" | password)
-	credentials := fmt.Sprintf("%s:%s", username, password)
-	hCred := Hash([]byte(credentials))
+// Compute x = H(salt | KDF(username | ":" | password, salt))
+// With KDFNone the inner step is a plain hash, x = H(salt | H(username | ":" | password))
+func (s *SRP) ComputeX(username, password string, salt []byte, kdf KDFParams) (*big.Int, error) {
+	credentials := []byte(fmt.Sprintf("%s:%s", username, password))
+
+	var inner []byte
+	if kdf.Algorithm == KDFNone || kdf.Algorithm == "" {
+		// H(username | ":" | password)
+		inner = s.Hash.Sum(credentials)
+	} else {
+		stretched, err := kdf.Stretch(credentials, salt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stretch password: %w", err)
+		}
+		inner = stretched
+	}
 
-	// x = H(salt | H(username | ":" | password))
-	xBytes := Hash(salt, hCred)
-	return new(big.Int).SetBytes(xBytes)
+	// x = H(salt | inner)
+	xBytes := s.Hash.Sum(salt, inner)
+	return new(big.Int).SetBytes(xBytes), nil
 }
 
-// Compute u = H(A | B)
+// Compute u = H(PAD(A) | PAD(B))
 func (s *SRP) ComputeU(A, B *big.Int) *big.Int {
-	h := sha256.New()
-	h.Write(PadTo(A.Bytes(), 256))
-	h.Write(PadTo(B.Bytes(), 256))
-	return new(big.Int).SetBytes(h.Sum(nil))
+	uBytes := s.Hash.Sum(s.pad(A), s.pad(B))
+	return new(big.Int).SetBytes(uBytes)
+}
+
+// pad left-pads a group element to the length of N
+func (s *SRP) pad(n *big.Int) []byte {
+	return PadTo(n.Bytes(), s.Group.Size())
+}
+
+// encode returns the bytes of a group element as hashed in proofs and K
+func (s *SRP) encode(n *big.Int) []byte {
+	if s.Mode == ModeRFC5054 {
+		return s.pad(n)
+	}
+	return n.Bytes()
 }
@@ -0,0 +1,176 @@
+package crypto
+
+import (
+	"math/big"
+	"testing"
+)
+
+var noKDF = KDFParams{Algorithm: KDFNone}
+
+func TestSRP_ValidateVerifierAcceptsComputedVerifier(t *testing.T) {
+	srp := NewSRP(Group2048, SHA256, ModeLegacy)
+
+	salt, err := srp.GenerateSalt()
+	if err != nil {
+		t.Fatalf("failed to generate salt: %v", err)
+	}
+
+	v, err := srp.ComputeVerifier("alice", "password123", salt, noKDF)
+	if err != nil {
+		t.Fatalf("failed to compute verifier: %v", err)
+	}
+
+	if err := srp.ValidateVerifier(v); err != nil {
+		t.Errorf("computed verifier should be valid: %v", err)
+	}
+}
+
+func TestSRP_ValidateVerifierRejectsDegenerateValues(t *testing.T) {
+	srp := NewSRP(Group2048, SHA256, ModeLegacy)
+
+	invalid := map[string]*big.Int{
+		"zero":        big.NewInt(0),
+		"one":         big.NewInt(1),
+		"N minus one": new(big.Int).Sub(srp.N, big.NewInt(1)),
+		"N":           new(big.Int).Set(srp.N),
+		"above N":     new(big.Int).Add(srp.N, big.NewInt(5)),
+	}
+
+	for name, v := range invalid {
+		if err := srp.ValidateVerifier(v); err == nil {
+			t.Errorf("%s should be rejected", name)
+		}
+	}
+}
+
+func TestSRP_HandshakeAgreesOnSessionKey(t *testing.T) {
+	for _, group := range Groups() {
+		for _, hash := range HashAlgorithms() {
+			for _, mode := range Modes() {
+				testHandshake(t, NewSRP(group, hash, mode))
+			}
+		}
+	}
+}
+
+func testHandshake(t *testing.T, srp *SRP) {
+	t.Helper()
+	username, password := "alice", "password123"
+
+	salt, err := srp.GenerateSalt()
+	if err != nil {
+		t.Fatalf("failed to generate salt: %v", err)
+	}
+	v, err := srp.ComputeVerifier(username, password, salt, noKDF)
+	if err != nil {
+		t.Fatalf("failed to compute verifier: %v", err)
+	}
+	if err := srp.ValidateVerifier(v); err != nil {
+		t.Fatalf("%d/%s/%s: computed verifier should be valid: %v", srp.Group.Bits, srp.Hash, srp.Mode, err)
+	}
+
+	a, A, err := srp.GenerateClientKeys()
+	if err != nil {
+		t.Fatalf("failed to generate client keys: %v", err)
+	}
+	b, B, err := srp.GenerateServerKeys(v)
+	if err != nil {
+		t.Fatalf("failed to generate server keys: %v", err)
+	}
+
+	u := srp.ComputeU(A, B)
+	x, err := srp.ComputeX(username, password, salt, noKDF)
+	if err != nil {
+		t.Fatalf("failed to compute x: %v", err)
+	}
+	clientK, err := srp.ComputeClientSessionKey(B, a, x, u)
+	if err != nil {
+		t.Fatalf("failed to compute client session key: %v", err)
+	}
+	serverK, err := srp.ComputeServerSessionKey(new(big.Int).Set(A), b, v, u)
+	if err != nil {
+		t.Fatalf("failed to compute server session key: %v", err)
+	}
+
+	M1 := srp.ComputeClientProof(username, salt, A, B, clientK)
+	if !srp.VerifyClientProof(username, salt, A, B, serverK, M1) {
+		t.Fatalf("%d/%s/%s: server should accept the client proof", srp.Group.Bits, srp.Hash, srp.Mode)
+	}
+
+	M2 := srp.ComputeServerProof(A, M1, serverK)
+	if !srp.VerifyServerProof(A, M1, clientK, M2) {
+		t.Fatalf("%d/%s/%s: client should accept the server proof", srp.Group.Bits, srp.Hash, srp.Mode)
+	}
+}
+
+func TestSRP_ClientRejectsWrongPassword(t *testing.T) {
+	srp := NewSRP(Group2048, SHA256, ModeLegacy)
+
+	salt, _ := srp.GenerateSalt()
+	v, _ := srp.ComputeVerifier("alice", "password123", salt, noKDF)
+
+	a, A, _ := srp.GenerateClientKeys()
+	b, B, _ := srp.GenerateServerKeys(v)
+	u := srp.ComputeU(A, B)
+
+	x, _ := srp.ComputeX("alice", "wrong-password", salt, noKDF)
+	clientK, err := srp.ComputeClientSessionKey(B, a, x, u)
+	if err != nil {
+		t.Fatalf("failed to compute client session key: %v", err)
+	}
+	serverK, _ := srp.ComputeServerSessionKey(new(big.Int).Set(A), b, v, u)
+
+	M1 := srp.ComputeClientProof("alice", salt, A, B, clientK)
+	if srp.VerifyClientProof("alice", salt, A, B, serverK, M1) {
+		t.Fatal("server should reject a proof derived from the wrong password")
+	}
+}
+
+func TestSRP_ComputeXDependsOnKDF(t *testing.T) {
+	srp := NewSRP(Group2048, SHA256, ModeLegacy)
+	salt := []byte("0123456789abcdef")
+
+	kdfs := []KDFParams{
+		noKDF,
+		{Algorithm: KDFArgon2id, Time: 1, Memory: minArgon2Memory, Threads: 1},
+		{Algorithm: KDFScrypt, N: minScryptN, R: 8, P: 1},
+	}
+
+	seen := make(map[string]KDFAlgorithm)
+	for _, kdf := range kdfs {
+		if err := kdf.Validate(); err != nil {
+			t.Fatalf("%s parameters should be valid: %v", kdf.Algorithm, err)
+		}
+
+		x1, err := srp.ComputeX("alice", "password123", salt, kdf)
+		if err != nil {
+			t.Fatalf("failed to compute x with %s: %v", kdf.Algorithm, err)
+		}
+		x2, _ := srp.ComputeX("alice", "password123", salt, kdf)
+		if x1.Cmp(x2) != 0 {
+			t.Errorf("%s: x should be deterministic", kdf.Algorithm)
+		}
+
+		if other, ok := seen[x1.String()]; ok {
+			t.Errorf("%s and %s produced the same x", kdf.Algorithm, other)
+		}
+		seen[x1.String()] = kdf.Algorithm
+	}
+}
+
+func TestKDFParams_AtLeast(t *testing.T) {
+	policy := KDFParams{Algorithm: KDFArgon2id, Time: 3, Memory: 65536, Threads: 4}
+
+	if noKDF.AtLeast(policy) {
+		t.Error("an unstretched verifier should not satisfy an argon2id policy")
+	}
+	if (KDFParams{Algorithm: KDFArgon2id, Time: 1, Memory: 65536, Threads: 4}).AtLeast(policy) {
+		t.Error("fewer argon2id iterations should not satisfy the policy")
+	}
+	if !policy.AtLeast(policy) {
+		t.Error("the policy should satisfy itself")
+	}
+	if !(KDFParams{Algorithm: KDFScrypt, N: 1 << 15, R: 8, P: 1}).AtLeast(policy) {
+		t.Error("scrypt should satisfy an argon2id policy")
+	}
+}
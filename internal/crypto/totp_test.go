@@ -0,0 +1,64 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// Test vectors from RFC 6238 appendix B (SHA-1), truncated to six digits
+func TestTOTPCode_RFC6238(t *testing.T) {
+	secret := []byte("12345678901234567890")
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got := TOTPCode(secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if got != tt.want {
+			t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP_Skew(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1234567890, 0)
+	previous := TOTPCode(secret, TOTPStep(now)-1)
+
+	step, ok := ValidateTOTP(secret, previous, now, 1)
+	if !ok || step != TOTPStep(now)-1 {
+		t.Errorf("ValidateTOTP() = %d, %v, want previous step", step, ok)
+	}
+	if _, ok := ValidateTOTP(secret, previous, now, 0); ok {
+		t.Error("code of the previous step accepted without skew")
+	}
+	if _, ok := ValidateTOTP(secret, "12345", now, 1); ok {
+		t.Error("short code accepted")
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+
+	sealed, err := Seal(key, []byte("secret"), []byte("user-1"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	opened, err := Open(key, sealed, []byte("user-1"))
+	if err != nil || string(opened) != "secret" {
+		t.Errorf("Open() = %q, %v", opened, err)
+	}
+	if _, err := Open(key, sealed, []byte("user-2")); err == nil {
+		t.Error("Open() accepted different additional data")
+	}
+}
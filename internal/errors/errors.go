@@ -3,7 +3,10 @@ package errors
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type ErrorCode string
@@ -15,8 +18,10 @@ const (
 	ErrCodeConflict        ErrorCode = "CONFLICT"
 	ErrCodeValidation      ErrorCode = "VALIDATION_ERROR"
 	ErrCodeAuthentication  ErrorCode = "AUTHENTICATION_ERROR"
+	ErrCodeForbidden       ErrorCode = "FORBIDDEN"
 	ErrCodeSessionExpired  ErrorCode = "SESSION_EXPIRED"
 	ErrCodeTooManyRequests ErrorCode = "TOO_MANY_REQUESTS"
+	ErrCodeAccountLocked   ErrorCode = "ACCOUNT_LOCKED"
 )
 
 type AppError struct {
@@ -24,6 +29,8 @@ type AppError struct {
 	Message    string    `json:"message"`
 	Details    string    `json:"details,omitempty"`
 	StatusCode int       `json:"-"`
+	// RetryAfter is sent as the Retry-After header, in seconds, when set
+	RetryAfter int `json:"retry_after,omitempty"`
 }
 
 func (e *AppError) Error() string {
@@ -32,6 +39,9 @@ func (e *AppError) Error() string {
 
 func (e *AppError) WriteResponse(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
+	if e.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(e.RetryAfter))
+	}
 	w.WriteHeader(e.StatusCode)
 	json.NewEncoder(w).Encode(e)
 }
@@ -85,6 +95,14 @@ func NewAuthenticationError(message string) *AppError {
 	}
 }
 
+func NewForbiddenError(message string) *AppError {
+	return &AppError{
+		Code:       ErrCodeForbidden,
+		Message:    message,
+		StatusCode: http.StatusForbidden,
+	}
+}
+
 func NewSessionExpiredError() *AppError {
 	return &AppError{
 		Code:       ErrCodeSessionExpired,
@@ -100,3 +118,14 @@ func NewTooManyRequestsError() *AppError {
 		StatusCode: http.StatusTooManyRequests,
 	}
 }
+
+// NewAccountLockedError is returned while a username has to wait after
+// failed logins
+func NewAccountLockedError(retryAfter time.Duration) *AppError {
+	return &AppError{
+		Code:       ErrCodeAccountLocked,
+		Message:    "Too many failed login attempts, please try again later",
+		StatusCode: http.StatusTooManyRequests,
+		RetryAfter: int(math.Ceil(retryAfter.Seconds())),
+	}
+}
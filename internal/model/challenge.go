@@ -0,0 +1,82 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Challenge is a pending authentication challenge in serialized form
+type Challenge struct {
+	ID        string    `json:"id"`
+	Data      []byte    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ChallengeRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewChallengeRepository(db *pgxpool.Pool) *ChallengeRepository {
+	return &ChallengeRepository{db: db}
+}
+
+func (r *ChallengeRepository) Create(ctx context.Context, challenge *Challenge) error {
+	query := `
+		INSERT INTO auth_challenges (id, data, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING created_at
+	`
+
+	return r.db.QueryRow(ctx, query,
+		challenge.ID,
+		challenge.Data,
+		challenge.ExpiresAt,
+	).Scan(&challenge.CreatedAt)
+}
+
+// Take deletes the challenge and returns it if it has not expired. Deleting
+// and reading in one statement means concurrent callers cannot both get it.
+func (r *ChallengeRepository) Take(ctx context.Context, id string) (*Challenge, error) {
+	query := `
+		DELETE FROM auth_challenges
+		WHERE id = $1
+		RETURNING id, data, expires_at, created_at
+	`
+
+	var challenge Challenge
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&challenge.ID,
+		&challenge.Data,
+		&challenge.ExpiresAt,
+		&challenge.CreatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, sql.ErrNoRows
+	}
+
+	return &challenge, nil
+}
+
+func (r *ChallengeRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `DELETE FROM auth_challenges WHERE expires_at < NOW()`
+
+	result, err := r.db.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LoginFailure counts the consecutive failed logins of a username
+type LoginFailure struct {
+	Username     string    `json:"username"`
+	FailedCount  int       `json:"failed_count"`
+	LastFailedAt time.Time `json:"last_failed_at"`
+}
+
+type LoginFailureRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewLoginFailureRepository(db *pgxpool.Pool) *LoginFailureRepository {
+	return &LoginFailureRepository{db: db}
+}
+
+func (r *LoginFailureRepository) Get(ctx context.Context, username string) (*LoginFailure, error) {
+	query := `
+		SELECT username, failed_count, last_failed_at
+		FROM login_failures
+		WHERE username = $1
+	`
+
+	var failure LoginFailure
+	err := r.db.QueryRow(ctx, query, username).Scan(
+		&failure.Username,
+		&failure.FailedCount,
+		&failure.LastFailedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	return &failure, nil
+}
+
+// Attempt counts a login attempt against username before its proof is
+// checked, unless allow rejects it. The row stays locked while allow
+// decides, so parallel attempts are decided one after the other and each
+// sees the count of the ones before it. Failures from before staleBefore no
+// longer count, so the counter starts over.
+func (r *LoginFailureRepository) Attempt(ctx context.Context, username string, staleBefore time.Time, allow func(*LoginFailure) error) (*LoginFailure, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Makes sure there is a row to lock for a first attempt
+	insertQuery := `
+		INSERT INTO login_failures (username, failed_count, last_failed_at)
+		VALUES ($1, 0, NOW())
+		ON CONFLICT (username) DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, insertQuery, username); err != nil {
+		return nil, err
+	}
+
+	selectQuery := `
+		SELECT username, failed_count, last_failed_at
+		FROM login_failures
+		WHERE username = $1
+		FOR UPDATE
+	`
+
+	var failure LoginFailure
+	err = tx.QueryRow(ctx, selectQuery, username).Scan(
+		&failure.Username,
+		&failure.FailedCount,
+		&failure.LastFailedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if failure.LastFailedAt.Before(staleBefore) {
+		failure.FailedCount = 0
+	}
+
+	if err := allow(&failure); err != nil {
+		return nil, err
+	}
+
+	updateQuery := `
+		UPDATE login_failures
+		SET failed_count = $2, last_failed_at = NOW()
+		WHERE username = $1
+		RETURNING failed_count, last_failed_at
+	`
+
+	err = tx.QueryRow(ctx, updateQuery, username, failure.FailedCount+1).Scan(
+		&failure.FailedCount,
+		&failure.LastFailedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &failure, tx.Commit(ctx)
+}
+
+// Delete clears the failures of username, after a successful login or to
+// unlock it
+func (r *LoginFailureRepository) Delete(ctx context.Context, username string) error {
+	query := `DELETE FROM login_failures WHERE username = $1`
+
+	result, err := r.db.Exec(ctx, query, username)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// DeleteStale removes failures last recorded before staleBefore
+func (r *LoginFailureRepository) DeleteStale(ctx context.Context, staleBefore time.Time) (int64, error) {
+	query := `DELETE FROM login_failures WHERE last_failed_at < $1`
+
+	result, err := r.db.Exec(ctx, query, staleBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
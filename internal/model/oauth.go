@@ -0,0 +1,155 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OAuthClient is a relying party registered with the OpenID Connect provider
+type OAuthClient struct {
+	ID           string    `json:"client_id"`
+	SecretHash   []byte    `json:"-"` // nil for public clients
+	Name         string    `json:"client_name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// AuthorizationCode is a pending code of the authorization code flow
+type AuthorizationCode struct {
+	CodeHash      []byte
+	ClientID      string
+	UserID        string
+	RedirectURI   string
+	Scope         string
+	Nonce         string
+	CodeChallenge string
+	AuthTime      time.Time
+	IPAddress     string // where the user authorized the code from
+	UserAgent     string
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+}
+
+type OAuthRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOAuthRepository(db *pgxpool.Pool) *OAuthRepository {
+	return &OAuthRepository{db: db}
+}
+
+func (r *OAuthRepository) CreateClient(ctx context.Context, client *OAuthClient) error {
+	query := `
+		INSERT INTO oauth_clients (client_id, client_secret_hash, client_name, redirect_uris)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at, updated_at
+	`
+
+	return r.db.QueryRow(ctx, query,
+		client.ID,
+		client.SecretHash,
+		client.Name,
+		client.RedirectURIs,
+	).Scan(&client.CreatedAt, &client.UpdatedAt)
+}
+
+func (r *OAuthRepository) GetClient(ctx context.Context, clientID string) (*OAuthClient, error) {
+	query := `
+		SELECT client_id, client_secret_hash, client_name, redirect_uris, created_at, updated_at
+		FROM oauth_clients
+		WHERE client_id = $1
+	`
+
+	var client OAuthClient
+	err := r.db.QueryRow(ctx, query, clientID).Scan(
+		&client.ID,
+		&client.SecretHash,
+		&client.Name,
+		&client.RedirectURIs,
+		&client.CreatedAt,
+		&client.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	return &client, nil
+}
+
+func (r *OAuthRepository) CreateCode(ctx context.Context, code *AuthorizationCode) error {
+	query := `
+		INSERT INTO authorization_codes
+			(code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, auth_time, ip_address, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING created_at
+	`
+
+	return r.db.QueryRow(ctx, query,
+		code.CodeHash,
+		code.ClientID,
+		code.UserID,
+		code.RedirectURI,
+		code.Scope,
+		code.Nonce,
+		code.CodeChallenge,
+		code.AuthTime,
+		code.IPAddress,
+		code.UserAgent,
+		code.ExpiresAt,
+	).Scan(&code.CreatedAt)
+}
+
+// TakeCode deletes and returns the code so that it can be redeemed once.
+// Expired codes are returned too; the caller checks ExpiresAt.
+func (r *OAuthRepository) TakeCode(ctx context.Context, codeHash []byte) (*AuthorizationCode, error) {
+	query := `
+		DELETE FROM authorization_codes
+		WHERE code_hash = $1
+		RETURNING code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, auth_time, ip_address, user_agent, expires_at, created_at
+	`
+
+	var code AuthorizationCode
+	err := r.db.QueryRow(ctx, query, codeHash).Scan(
+		&code.CodeHash,
+		&code.ClientID,
+		&code.UserID,
+		&code.RedirectURI,
+		&code.Scope,
+		&code.Nonce,
+		&code.CodeChallenge,
+		&code.AuthTime,
+		&code.IPAddress,
+		&code.UserAgent,
+		&code.ExpiresAt,
+		&code.CreatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+func (r *OAuthRepository) DeleteExpiredCodes(ctx context.Context) (int64, error) {
+	query := `DELETE FROM authorization_codes WHERE expires_at < NOW()`
+
+	result, err := r.db.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OPAQUERecord is the registration record of an account using OPAQUE
+type OPAQUERecord struct {
+	UserID    string           `json:"user_id"`
+	Record    []byte           `json:"-"`
+	KSF       crypto.KDFParams `json:"-"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+type OPAQUERepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOPAQUERepository(db *pgxpool.Pool) *OPAQUERepository {
+	return &OPAQUERepository{db: db}
+}
+
+// CreateWithUser inserts user and its OPAQUE record in one transaction. It
+// returns ErrUsernameTaken if the username exists.
+func (r *OPAQUERepository) CreateWithUser(ctx context.Context, user *User, record *OPAQUERecord) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	userQuery := `
+		INSERT INTO users (username, srp_group, hash_algorithm, srp_mode, kdf)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`
+
+	err = tx.QueryRow(ctx, userQuery, user.Username, user.SRPGroup, user.HashAlgorithm, user.SRPMode, user.KDF).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		return usernameError(err)
+	}
+
+	recordQuery := `
+		INSERT INTO opaque_records (user_id, record, ksf)
+		VALUES ($1, $2, $3)
+		RETURNING created_at, updated_at
+	`
+
+	record.UserID = user.ID
+	err = tx.QueryRow(ctx, recordQuery, record.UserID, record.Record, record.KSF).Scan(
+		&record.CreatedAt,
+		&record.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *OPAQUERepository) GetByUserID(ctx context.Context, userID string) (*OPAQUERecord, error) {
+	query := `
+		SELECT user_id, record, ksf, created_at, updated_at
+		FROM opaque_records
+		WHERE user_id = $1
+	`
+
+	var record OPAQUERecord
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&record.UserID,
+		&record.Record,
+		&record.KSF,
+		&record.CreatedAt,
+		&record.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// Update replaces the record of an account after a password change. It
+// returns sql.ErrNoRows if the account has no OPAQUE record.
+func (r *OPAQUERepository) Update(ctx context.Context, record *OPAQUERecord) error {
+	query := `
+		UPDATE opaque_records
+		SET record = $2, ksf = $3, updated_at = NOW()
+		WHERE user_id = $1
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRow(ctx, query, record.UserID, record.Record, record.KSF).Scan(&record.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return sql.ErrNoRows
+		}
+		return err
+	}
+
+	return nil
+}
+
+// KSFCount is the number of OPAQUE records sharing key stretching parameters
+type KSFCount struct {
+	KSF   crypto.KDFParams
+	Count int64
+}
+
+// CountByKSF counts the OPAQUE records by their key stretching parameters
+func (r *OPAQUERepository) CountByKSF(ctx context.Context) ([]*KSFCount, error) {
+	query := `SELECT ksf, COUNT(*) FROM opaque_records GROUP BY ksf`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []*KSFCount
+	for rows.Next() {
+		var count KSFCount
+		if err := rows.Scan(&count.KSF, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, &count)
+	}
+
+	return counts, rows.Err()
+}
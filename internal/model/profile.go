@@ -0,0 +1,80 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Profile holds the attributes a user edits about themselves
+type Profile struct {
+	UserID      string          `json:"user_id"`
+	DisplayName string          `json:"display_name"`
+	Email       string          `json:"email"`
+	Locale      string          `json:"locale"`
+	Metadata    json.RawMessage `json:"metadata"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+type ProfileRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewProfileRepository(db *pgxpool.Pool) *ProfileRepository {
+	return &ProfileRepository{db: db}
+}
+
+func (r *ProfileRepository) GetByUserID(ctx context.Context, userID string) (*Profile, error) {
+	query := `
+		SELECT user_id, display_name, email, locale, metadata, created_at, updated_at
+		FROM user_profiles
+		WHERE user_id = $1
+	`
+
+	var profile Profile
+	var metadata []byte
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&profile.UserID,
+		&profile.DisplayName,
+		&profile.Email,
+		&profile.Locale,
+		&metadata,
+		&profile.CreatedAt,
+		&profile.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	profile.Metadata = metadata
+	return &profile, nil
+}
+
+// Upsert creates or replaces the user's profile
+func (r *ProfileRepository) Upsert(ctx context.Context, profile *Profile) error {
+	query := `
+		INSERT INTO user_profiles (user_id, display_name, email, locale, metadata)
+		VALUES ($1, $2, $3, $4, $5::jsonb)
+		ON CONFLICT (user_id) DO UPDATE
+		SET display_name = EXCLUDED.display_name, email = EXCLUDED.email,
+		    locale = EXCLUDED.locale, metadata = EXCLUDED.metadata
+		RETURNING created_at, updated_at
+	`
+
+	return r.db.QueryRow(ctx, query,
+		profile.UserID,
+		profile.DisplayName,
+		profile.Email,
+		profile.Locale,
+		string(profile.Metadata),
+	).Scan(&profile.CreatedAt, &profile.UpdatedAt)
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RecoveryCodeRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRecoveryCodeRepository(db *pgxpool.Pool) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{db: db}
+}
+
+// Replace deletes the user's recovery codes and stores codeHashes in their
+// place
+func (r *RecoveryCodeRepository) Replace(ctx context.Context, userID string, codeHashes [][]byte) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(ctx, query, userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// CountUnused returns how many of the user's recovery codes are left
+func (r *RecoveryCodeRepository) CountUnused(ctx context.Context, userID string) (int, error) {
+	query := `SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	var count int
+	err := r.db.QueryRow(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+// Recover spends a recovery code and sets the user's new salt and verifier
+// in one transaction. The OPAQUE record, which still opens with the
+// forgotten password, is deleted. It returns false if the code is unknown
+// or was already used.
+func (r *RecoveryCodeRepository) Recover(ctx context.Context, codeHash []byte, user *User) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, user.ID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE users
+		SET salt = $2, verifier = $3, srp_group = $4, hash_algorithm = $5, srp_mode = $6, kdf = $7, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at
+	`, user.ID, user.Salt, user.Verifier, user.SRPGroup, user.HashAlgorithm, user.SRPMode, user.KDF).Scan(&user.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM opaque_records WHERE user_id = $1`, user.ID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
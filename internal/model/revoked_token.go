@@ -0,0 +1,122 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RevocationChannel is the Postgres notification channel carrying each newly
+// revoked token as JSON
+const RevocationChannel = "token_revoked"
+
+type RevokedToken struct {
+	JTI       string    `json:"jti"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+type RevokedTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRevokedTokenRepository(db *pgxpool.Pool) *RevokedTokenRepository {
+	return &RevokedTokenRepository{db: db}
+}
+
+// Create records the revocation and notifies listeners on RevocationChannel
+func (r *RevokedTokenRepository) Create(ctx context.Context, jti string, expiresAt time.Time) error {
+	query := `
+		WITH revoked AS (
+			INSERT INTO revoked_tokens (jti, expires_at)
+			VALUES ($1, $2)
+			ON CONFLICT (jti) DO NOTHING
+			RETURNING jti
+		)
+		SELECT pg_notify($3, json_build_object('jti', jti, 'expires_at', $2::timestamptz)::text)
+		FROM revoked
+	`
+
+	_, err := r.db.Exec(ctx, query, jti, expiresAt, RevocationChannel)
+	return err
+}
+
+// GetActive returns every revocation whose token has not expired yet
+func (r *RevokedTokenRepository) GetActive(ctx context.Context) ([]*RevokedToken, error) {
+	query := `
+		SELECT jti, expires_at, revoked_at
+		FROM revoked_tokens
+		WHERE expires_at > NOW()
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*RevokedToken
+	for rows.Next() {
+		var token RevokedToken
+		if err := rows.Scan(&token.JTI, &token.ExpiresAt, &token.RevokedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, &token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// IsRevoked reports whether the token with the given jti has been revoked
+func (r *RevokedTokenRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`
+
+	var revoked bool
+	err := r.db.QueryRow(ctx, query, jti).Scan(&revoked)
+	return revoked, err
+}
+
+func (r *RevokedTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `DELETE FROM revoked_tokens WHERE expires_at < NOW()`
+
+	result, err := r.db.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// Listen subscribes to RevocationChannel on a dedicated connection. ready is
+// called once the subscription is active, then notify for each revocation,
+// until ctx is cancelled or the connection fails.
+func (r *RevokedTokenRepository) Listen(ctx context.Context, ready func() error, notify func(*RevokedToken)) error {
+	conn, err := r.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+RevocationChannel); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), "UNLISTEN "+RevocationChannel)
+
+	if err := ready(); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var token RevokedToken
+		if err := json.Unmarshal([]byte(notification.Payload), &token); err != nil {
+			return err
+		}
+		notify(&token)
+	}
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RoleRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRoleRepository(db *pgxpool.Pool) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// RolesForUser returns the names of the user's roles, sorted
+func (r *RoleRepository) RolesForUser(ctx context.Context, userID string) ([]string, error) {
+	query := `SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role`
+
+	return r.strings(ctx, query, userID)
+}
+
+// PermissionsForUser returns the permissions granted by any of the user's
+// roles, sorted and without duplicates
+func (r *RoleRepository) PermissionsForUser(ctx context.Context, userID string) ([]string, error) {
+	query := `
+		SELECT DISTINCT rp.permission
+		FROM user_roles ur
+		JOIN role_permissions rp ON rp.role = ur.role
+		WHERE ur.user_id = $1
+		ORDER BY rp.permission
+	`
+
+	return r.strings(ctx, query, userID)
+}
+
+// Grant gives the user a role. It returns sql.ErrNoRows if the role does
+// not exist; granting a role the user already holds is not an error.
+func (r *RoleRepository) Grant(ctx context.Context, userID, role string) error {
+	query := `
+		WITH granted AS (
+			INSERT INTO user_roles (user_id, role)
+			SELECT $1, name FROM roles WHERE name = $2
+			ON CONFLICT (user_id, role) DO NOTHING
+		)
+		SELECT EXISTS(SELECT 1 FROM roles WHERE name = $2)
+	`
+
+	var exists bool
+	if err := r.db.QueryRow(ctx, query, userID, role).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Revoke takes a role from the user. It returns sql.ErrNoRows if the user
+// did not hold it.
+func (r *RoleRepository) Revoke(ctx context.Context, userID, role string) error {
+	query := `DELETE FROM user_roles WHERE user_id = $1 AND role = $2`
+
+	result, err := r.db.Exec(ctx, query, userID, role)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *RoleRepository) strings(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
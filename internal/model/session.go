@@ -10,13 +10,18 @@ import (
 )
 
 type Session struct {
-	ID           string    `json:"id"`
-	UserID       string    `json:"user_id"`
-	Challenge    []byte    `json:"-"`
-	ServerSecret []byte    `json:"-"`
-	Token        string    `json:"token,omitempty"`
-	ExpiresAt    time.Time `json:"expires_at"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID           string     `json:"id"`
+	UserID       string     `json:"user_id"`
+	ClientID     string     `json:"client_id,omitempty"` // OpenID Connect client, empty for first-party sessions
+	Challenge    []byte     `json:"-"`
+	ServerSecret []byte     `json:"-"`
+	Token        string     `json:"token,omitempty"`
+	IPAddress    string     `json:"ip_address"`
+	UserAgent    string     `json:"user_agent"`
+	DeviceName   string     `json:"device_name,omitempty"`
+	LastSeenAt   *time.Time `json:"last_seen_at,omitempty"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
 }
 
 type SessionRepository struct {
@@ -29,17 +34,28 @@ func NewSessionRepository(db *pgxpool.Pool) *SessionRepository {
 
 func (r *SessionRepository) Create(ctx context.Context, session *Session) error {
 	query := `
-		INSERT INTO sessions (user_id, challenge, server_secret, token, expires_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO sessions (user_id, client_id, challenge, server_secret, token, ip_address, user_agent, device_name, expires_at, created_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9, COALESCE($10, NOW()))
 		RETURNING id, created_at
 	`
 
+	// created_at defaults to now unless the session logged in earlier
+	var createdAt *time.Time
+	if !session.CreatedAt.IsZero() {
+		createdAt = &session.CreatedAt
+	}
+
 	err := r.db.QueryRow(ctx, query,
 		session.UserID,
+		session.ClientID,
 		session.Challenge,
 		session.ServerSecret,
 		session.Token,
+		session.IPAddress,
+		session.UserAgent,
+		session.DeviceName,
 		session.ExpiresAt,
+		createdAt,
 	).Scan(&session.ID, &session.CreatedAt)
 
 	if err != nil {
@@ -51,7 +67,7 @@ func (r *SessionRepository) Create(ctx context.Context, session *Session) error
 
 func (r *SessionRepository) GetByID(ctx context.Context, id string) (*Session, error) {
 	query := `
-		SELECT id, user_id, challenge, server_secret, token, expires_at, created_at
+		SELECT id, user_id, COALESCE(client_id, ''), challenge, server_secret, token, ip_address, user_agent, device_name, last_seen_at, expires_at, created_at
 		FROM sessions
 		WHERE id = $1
 	`
@@ -60,9 +76,14 @@ func (r *SessionRepository) GetByID(ctx context.Context, id string) (*Session, e
 	err := r.db.QueryRow(ctx, query, id).Scan(
 		&session.ID,
 		&session.UserID,
+		&session.ClientID,
 		&session.Challenge,
 		&session.ServerSecret,
 		&session.Token,
+		&session.IPAddress,
+		&session.UserAgent,
+		&session.DeviceName,
+		&session.LastSeenAt,
 		&session.ExpiresAt,
 		&session.CreatedAt,
 	)
@@ -79,7 +100,7 @@ func (r *SessionRepository) GetByID(ctx context.Context, id string) (*Session, e
 
 func (r *SessionRepository) GetByToken(ctx context.Context, token string) (*Session, error) {
 	query := `
-		SELECT id, user_id, challenge, server_secret, token, expires_at, created_at
+		SELECT id, user_id, COALESCE(client_id, ''), challenge, server_secret, token, ip_address, user_agent, device_name, last_seen_at, expires_at, created_at
 		FROM sessions
 		WHERE token = $1 AND expires_at > NOW()
 	`
@@ -88,9 +109,14 @@ func (r *SessionRepository) GetByToken(ctx context.Context, token string) (*Sess
 	err := r.db.QueryRow(ctx, query, token).Scan(
 		&session.ID,
 		&session.UserID,
+		&session.ClientID,
 		&session.Challenge,
 		&session.ServerSecret,
 		&session.Token,
+		&session.IPAddress,
+		&session.UserAgent,
+		&session.DeviceName,
+		&session.LastSeenAt,
 		&session.ExpiresAt,
 		&session.CreatedAt,
 	)
@@ -107,7 +133,7 @@ func (r *SessionRepository) GetByToken(ctx context.Context, token string) (*Sess
 
 func (r *SessionRepository) GetActiveByUserID(ctx context.Context, userID string) ([]*Session, error) {
 	query := `
-		SELECT id, user_id, challenge, server_secret, token, expires_at, created_at
+		SELECT id, user_id, COALESCE(client_id, ''), challenge, server_secret, token, ip_address, user_agent, device_name, last_seen_at, expires_at, created_at
 		FROM sessions
 		WHERE user_id = $1 AND expires_at > NOW()
 		ORDER BY created_at DESC
@@ -125,9 +151,14 @@ func (r *SessionRepository) GetActiveByUserID(ctx context.Context, userID string
 		err := rows.Scan(
 			&session.ID,
 			&session.UserID,
+			&session.ClientID,
 			&session.Challenge,
 			&session.ServerSecret,
 			&session.Token,
+			&session.IPAddress,
+			&session.UserAgent,
+			&session.DeviceName,
+			&session.LastSeenAt,
 			&session.ExpiresAt,
 			&session.CreatedAt,
 		)
@@ -143,7 +174,8 @@ func (r *SessionRepository) GetActiveByUserID(ctx context.Context, userID string
 func (r *SessionRepository) Update(ctx context.Context, session *Session) error {
 	query := `
 		UPDATE sessions
-		SET challenge = $2, server_secret = $3, token = $4, expires_at = $5
+		SET challenge = $2, server_secret = $3, token = $4, ip_address = $5,
+		    user_agent = $6, device_name = $7, expires_at = $8
 		WHERE id = $1
 	`
 
@@ -152,6 +184,9 @@ func (r *SessionRepository) Update(ctx context.Context, session *Session) error
 		session.Challenge,
 		session.ServerSecret,
 		session.Token,
+		session.IPAddress,
+		session.UserAgent,
+		session.DeviceName,
 		session.ExpiresAt,
 	)
 
@@ -166,6 +201,36 @@ func (r *SessionRepository) Update(ctx context.Context, session *Session) error
 	return nil
 }
 
+// MarkLoggedIn moves created_at to now once the login of a session has
+// completed. The row is created with the challenge, but the session's
+// lifetime starts at the login.
+func (r *SessionRepository) MarkLoggedIn(ctx context.Context, id string) (time.Time, error) {
+	query := `UPDATE sessions SET created_at = NOW() WHERE id = $1 RETURNING created_at`
+
+	var createdAt time.Time
+	if err := r.db.QueryRow(ctx, query, id).Scan(&createdAt); err != nil {
+		if err == pgx.ErrNoRows {
+			return time.Time{}, sql.ErrNoRows
+		}
+		return time.Time{}, err
+	}
+
+	return createdAt, nil
+}
+
+// TouchLastSeen sets last_seen_at to now unless it is already later than
+// after, so that busy sessions are not written on every request
+func (r *SessionRepository) TouchLastSeen(ctx context.Context, id string, after time.Time) error {
+	query := `
+		UPDATE sessions
+		SET last_seen_at = NOW()
+		WHERE id = $1 AND (last_seen_at IS NULL OR last_seen_at < $2)
+	`
+
+	_, err := r.db.Exec(ctx, query, id, after)
+	return err
+}
+
 func (r *SessionRepository) Delete(ctx context.Context, id string) error {
 	query := `DELETE FROM sessions WHERE id = $1`
 
@@ -198,3 +263,11 @@ func (r *SessionRepository) DeleteByUserID(ctx context.Context, userID string) e
 	_, err := r.db.Exec(ctx, query, userID)
 	return err
 }
+
+// DeleteByUserIDExcept deletes every session of the user except keepID
+func (r *SessionRepository) DeleteByUserIDExcept(ctx context.Context, userID, keepID string) error {
+	query := `DELETE FROM sessions WHERE user_id = $1 AND id <> $2`
+
+	_, err := r.db.Exec(ctx, query, userID, keepID)
+	return err
+}
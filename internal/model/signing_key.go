@@ -0,0 +1,118 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Signing key states
+const (
+	SigningKeyActive  = "active"
+	SigningKeyRetired = "retired"
+)
+
+// SigningKey is a token signing key. The PEM encoded private key is
+// encrypted by the auth service.
+type SigningKey struct {
+	KID                 string     `json:"kid"`
+	Algorithm           string     `json:"algorithm"`
+	PrivateKeyEncrypted []byte     `json:"-"`
+	Status              string     `json:"status"`
+	AcceptUntil         *time.Time `json:"accept_until,omitempty"`
+	RetiredAt           *time.Time `json:"retired_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+type SigningKeyRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSigningKeyRepository(db *pgxpool.Pool) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+// GetUsable returns the active key and the retired keys that are still
+// accepted, oldest first
+func (r *SigningKeyRepository) GetUsable(ctx context.Context) ([]*SigningKey, error) {
+	query := `
+		SELECT kid, algorithm, private_key_encrypted, status, accept_until, retired_at, created_at
+		FROM signing_keys
+		WHERE status = 'active' OR accept_until > NOW()
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*SigningKey
+	for rows.Next() {
+		var key SigningKey
+		err := rows.Scan(
+			&key.KID,
+			&key.Algorithm,
+			&key.PrivateKeyEncrypted,
+			&key.Status,
+			&key.AcceptUntil,
+			&key.RetiredAt,
+			&key.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+
+	return keys, rows.Err()
+}
+
+// FirstCreatedAt returns when the first key was stored, or nil if there is
+// none
+func (r *SigningKeyRepository) FirstCreatedAt(ctx context.Context) (*time.Time, error) {
+	query := `SELECT MIN(created_at) FROM signing_keys`
+
+	var createdAt *time.Time
+	if err := r.db.QueryRow(ctx, query).Scan(&createdAt); err != nil {
+		return nil, err
+	}
+
+	return createdAt, nil
+}
+
+// Rotate retires the active key, accepting it until acceptUntil, and stores
+// key as the new active key
+func (r *SigningKeyRepository) Rotate(ctx context.Context, key *SigningKey, acceptUntil time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	retireQuery := `
+		UPDATE signing_keys
+		SET status = 'retired', retired_at = NOW(), accept_until = $1
+		WHERE status = 'active'
+	`
+
+	if _, err := tx.Exec(ctx, retireQuery, acceptUntil); err != nil {
+		return err
+	}
+
+	insertQuery := `
+		INSERT INTO signing_keys (kid, algorithm, private_key_encrypted, status)
+		VALUES ($1, $2, $3, 'active')
+		RETURNING created_at
+	`
+
+	key.Status = SigningKeyActive
+	err = tx.QueryRow(ctx, insertQuery, key.KID, key.Algorithm, key.PrivateKeyEncrypted).Scan(&key.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TOTPCredential is a user's TOTP secret, encrypted by the auth service
+type TOTPCredential struct {
+	UserID          string     `json:"user_id"`
+	SecretEncrypted []byte     `json:"-"`
+	ConfirmedAt     *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep    int64      `json:"-"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type TOTPRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewTOTPRepository(db *pgxpool.Pool) *TOTPRepository {
+	return &TOTPRepository{db: db}
+}
+
+// SetPending stores a new unconfirmed secret, replacing an earlier
+// unconfirmed one. It returns sql.ErrNoRows if the user already has a
+// confirmed credential.
+func (r *TOTPRepository) SetPending(ctx context.Context, credential *TOTPCredential) error {
+	query := `
+		INSERT INTO totp_credentials (user_id, secret_encrypted)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret_encrypted = EXCLUDED.secret_encrypted, last_used_step = 0
+		WHERE totp_credentials.confirmed_at IS NULL
+		RETURNING created_at, updated_at
+	`
+
+	err := r.db.QueryRow(ctx, query, credential.UserID, credential.SecretEncrypted).Scan(
+		&credential.CreatedAt,
+		&credential.UpdatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+func (r *TOTPRepository) GetByUserID(ctx context.Context, userID string) (*TOTPCredential, error) {
+	query := `
+		SELECT user_id, secret_encrypted, confirmed_at, last_used_step, created_at, updated_at
+		FROM totp_credentials
+		WHERE user_id = $1
+	`
+
+	var credential TOTPCredential
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&credential.UserID,
+		&credential.SecretEncrypted,
+		&credential.ConfirmedAt,
+		&credential.LastUsedStep,
+		&credential.CreatedAt,
+		&credential.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// IsConfirmed reports whether the user has a confirmed TOTP credential
+func (r *TOTPRepository) IsConfirmed(ctx context.Context, userID string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM totp_credentials WHERE user_id = $1 AND confirmed_at IS NOT NULL)`
+
+	var confirmed bool
+	err := r.db.QueryRow(ctx, query, userID).Scan(&confirmed)
+	return confirmed, err
+}
+
+// UseStep records step as used and, if confirm is set, confirms the
+// credential. It returns false if step is not newer than the last one used,
+// so each code is accepted once.
+func (r *TOTPRepository) UseStep(ctx context.Context, userID string, step int64, confirm bool) (bool, error) {
+	query := `
+		UPDATE totp_credentials
+		SET last_used_step = $2,
+		    confirmed_at = CASE WHEN $3 THEN COALESCE(confirmed_at, NOW()) ELSE confirmed_at END
+		WHERE user_id = $1 AND last_used_step < $2
+	`
+
+	result, err := r.db.Exec(ctx, query, userID, step, confirm)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
@@ -3,19 +3,43 @@ package model
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ErrUsernameTaken is returned when creating a user whose username exists
+var ErrUsernameTaken = errors.New("username already taken")
+
+// uniqueViolation is the PostgreSQL error code for a duplicate key
+const uniqueViolation = "23505"
+
+// usernameError maps a duplicate username on insert to ErrUsernameTaken
+func usernameError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return ErrUsernameTaken
+	}
+	return err
+}
+
 type User struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Salt      []byte    `json:"-"`
-	Verifier  []byte    `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            string           `json:"id"`
+	Username      string           `json:"username"`
+	Salt          []byte           `json:"-"`
+	Verifier      []byte           `json:"-"`
+	SRPGroup      int              `json:"-"`
+	HashAlgorithm string           `json:"-"`
+	SRPMode       string           `json:"-"`
+	KDF           crypto.KDFParams `json:"-"`
+	// DeleteAfter is set while the account is scheduled for deletion
+	DeleteAfter *time.Time `json:"delete_after,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
 type UserRepository struct {
@@ -26,21 +50,22 @@ func NewUserRepository(db *pgxpool.Pool) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// Create inserts user. It returns ErrUsernameTaken if the username exists.
 func (r *UserRepository) Create(ctx context.Context, user *User) error {
 	query := `
-		INSERT INTO users (username, salt, verifier)
-		VALUES ($1, $2, $3)
+		INSERT INTO users (username, salt, verifier, srp_group, hash_algorithm, srp_mode, kdf)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, created_at, updated_at
 	`
 
-	err := r.db.QueryRow(ctx, query, user.Username, user.Salt, user.Verifier).Scan(
+	err := r.db.QueryRow(ctx, query, user.Username, user.Salt, user.Verifier, user.SRPGroup, user.HashAlgorithm, user.SRPMode, user.KDF).Scan(
 		&user.ID,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 
 	if err != nil {
-		return err
+		return usernameError(err)
 	}
 
 	return nil
@@ -48,7 +73,7 @@ func (r *UserRepository) Create(ctx context.Context, user *User) error {
 
 func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*User, error) {
 	query := `
-		SELECT id, username, salt, verifier, created_at, updated_at
+		SELECT id, username, salt, verifier, srp_group, hash_algorithm, srp_mode, kdf, delete_after, created_at, updated_at
 		FROM users
 		WHERE username = $1
 	`
@@ -59,6 +84,11 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*U
 		&user.Username,
 		&user.Salt,
 		&user.Verifier,
+		&user.SRPGroup,
+		&user.HashAlgorithm,
+		&user.SRPMode,
+		&user.KDF,
+		&user.DeleteAfter,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -75,7 +105,7 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*U
 
 func (r *UserRepository) GetByID(ctx context.Context, id string) (*User, error) {
 	query := `
-		SELECT id, username, salt, verifier, created_at, updated_at
+		SELECT id, username, salt, verifier, srp_group, hash_algorithm, srp_mode, kdf, delete_after, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
@@ -86,6 +116,11 @@ func (r *UserRepository) GetByID(ctx context.Context, id string) (*User, error)
 		&user.Username,
 		&user.Salt,
 		&user.Verifier,
+		&user.SRPGroup,
+		&user.HashAlgorithm,
+		&user.SRPMode,
+		&user.KDF,
+		&user.DeleteAfter,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -103,12 +138,12 @@ func (r *UserRepository) GetByID(ctx context.Context, id string) (*User, error)
 func (r *UserRepository) Update(ctx context.Context, user *User) error {
 	query := `
 		UPDATE users
-		SET salt = $2, verifier = $3, updated_at = NOW()
+		SET salt = $2, verifier = $3, srp_group = $4, hash_algorithm = $5, srp_mode = $6, kdf = $7, updated_at = NOW()
 		WHERE id = $1
 		RETURNING updated_at
 	`
 
-	err := r.db.QueryRow(ctx, query, user.ID, user.Salt, user.Verifier).Scan(&user.UpdatedAt)
+	err := r.db.QueryRow(ctx, query, user.ID, user.Salt, user.Verifier, user.SRPGroup, user.HashAlgorithm, user.SRPMode, user.KDF).Scan(&user.UpdatedAt)
 	if err != nil {
 		return err
 	}
@@ -131,6 +166,70 @@ func (r *UserRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// ScheduleDeletion marks the user for deletion once deleteAfter has passed
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, id string, deleteAfter time.Time) error {
+	query := `UPDATE users SET delete_after = $2, updated_at = NOW() WHERE id = $1`
+
+	result, err := r.db.Exec(ctx, query, id, deleteAfter)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// CancelDeletion clears a scheduled deletion. It reports false if none was
+// scheduled.
+func (r *UserRepository) CancelDeletion(ctx context.Context, id string) (bool, error) {
+	query := `UPDATE users SET delete_after = NULL, updated_at = NOW() WHERE id = $1 AND delete_after IS NOT NULL`
+
+	result, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// ListDueForDeletion returns the IDs of users whose grace period has ended
+func (r *UserRepository) ListDueForDeletion(ctx context.Context) ([]string, error) {
+	query := `SELECT id FROM users WHERE delete_after <= NOW()`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// DeleteIfDue deletes the user if its scheduled deletion is due. It reports
+// false if the deletion was cancelled.
+func (r *UserRepository) DeleteIfDue(ctx context.Context, id string) (bool, error) {
+	query := `DELETE FROM users WHERE id = $1 AND delete_after <= NOW()`
+
+	result, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
 func (r *UserRepository) ExistsByUsername(ctx context.Context, username string) (bool, error) {
 	query := `SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)`
 
@@ -142,3 +241,50 @@ func (r *UserRepository) ExistsByUsername(ctx context.Context, username string)
 
 	return exists, nil
 }
+
+// ParameterCount is the number of users whose verifiers share a set of SRP
+// parameters and salt length
+type ParameterCount struct {
+	SRPGroup      int
+	HashAlgorithm string
+	SRPMode       string
+	KDF           crypto.KDFParams
+	SaltLength    int
+	Count         int64
+}
+
+// CountByParameters counts the users with an SRP verifier by their
+// parameters
+func (r *UserRepository) CountByParameters(ctx context.Context) ([]*ParameterCount, error) {
+	query := `
+		SELECT srp_group, hash_algorithm, srp_mode, kdf, octet_length(salt), COUNT(*)
+		FROM users
+		WHERE verifier IS NOT NULL
+		GROUP BY srp_group, hash_algorithm, srp_mode, kdf, octet_length(salt)
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []*ParameterCount
+	for rows.Next() {
+		var count ParameterCount
+		err := rows.Scan(
+			&count.SRPGroup,
+			&count.HashAlgorithm,
+			&count.SRPMode,
+			&count.KDF,
+			&count.SaltLength,
+			&count.Count,
+		)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, &count)
+	}
+
+	return counts, rows.Err()
+}
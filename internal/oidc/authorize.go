@@ -0,0 +1,162 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"net/url"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/auth"
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"go.uber.org/zap"
+)
+
+// AuthorizationRequest holds the parameters of an authorization request.
+// The login page posts them back as JSON once the user has logged in.
+type AuthorizationRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state,omitempty"`
+	Nonce               string `json:"nonce,omitempty"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+type AuthorizationResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+func authorizationRequestFromQuery(query url.Values) *AuthorizationRequest {
+	return &AuthorizationRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		Nonce:               query.Get("nonce"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+}
+
+// CheckClient verifies the client and its redirect URI. These errors must be
+// shown to the user rather than sent to a redirect URI that can't be trusted.
+func (s *Service) CheckClient(ctx context.Context, req *AuthorizationRequest) (*model.OAuthClient, error) {
+	if req.ClientID == "" {
+		return nil, errors.NewValidationError("client_id is required")
+	}
+
+	client, err := s.oauthRepo.GetClient(ctx, req.ClientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.NewBadRequestError("unknown client_id")
+		}
+		return nil, errors.NewInternalError("failed to retrieve client")
+	}
+
+	for _, uri := range client.RedirectURIs {
+		if subtle.ConstantTimeCompare([]byte(uri), []byte(req.RedirectURI)) == 1 {
+			return client, nil
+		}
+	}
+	return nil, errors.NewBadRequestError("redirect_uri is not registered for this client")
+}
+
+// CheckParams validates the remaining parameters. Its errors are reported
+// to the client through the redirect URI.
+func (s *Service) CheckParams(req *AuthorizationRequest) *Error {
+	if req.ResponseType != "code" {
+		return errUnsupportedResponseType()
+	}
+	if !hasScope(req.Scope, scopeOpenID) {
+		return errInvalidScope("the openid scope is required")
+	}
+	if req.CodeChallenge == "" {
+		return errInvalidRequest("code_challenge is required")
+	}
+	if req.CodeChallengeMethod != "S256" {
+		return errInvalidRequest("code_challenge_method must be S256")
+	}
+	if len(req.CodeChallenge) != 43 {
+		return errInvalidRequest("invalid code_challenge")
+	}
+	return nil
+}
+
+// ErrorRedirect returns where to send the user agent for a failed request
+func (s *Service) ErrorRedirect(req *AuthorizationRequest, oauthErr *Error) string {
+	return oauthErr.redirectURL(req.RedirectURI, req.State, s.Issuer())
+}
+
+// Authorize issues an authorization code to the user logged in with
+// accessToken. That token comes from the login page's own session, which is
+// ended here; the client gets a session of its own at the token endpoint.
+func (s *Service) Authorize(ctx context.Context, req *AuthorizationRequest, accessToken string) (*AuthorizationResponse, error) {
+	if _, err := s.CheckClient(ctx, req); err != nil {
+		return nil, err
+	}
+	if oauthErr := s.CheckParams(req); oauthErr != nil {
+		return nil, errors.NewValidationError(oauthErr.Error())
+	}
+
+	claims, err := s.auth.ValidateToken(accessToken)
+	if err != nil || claims.UserID == "" {
+		return nil, errors.NewAuthenticationError("invalid or expired token")
+	}
+
+	// Recorded on the client's session, the token request comes from the
+	// client rather than the user
+	client, _ := ctx.Value(auth.ClientContextKey).(*auth.ClientInfo)
+	if client == nil {
+		client = &auth.ClientInfo{}
+	}
+
+	code, err := newSecret()
+	if err != nil {
+		return nil, errors.NewInternalError("failed to generate code")
+	}
+
+	authTime := time.Now()
+	if claims.AuthTime != nil {
+		authTime = claims.AuthTime.Time
+	} else if claims.IssuedAt != nil {
+		authTime = claims.IssuedAt.Time
+	}
+
+	err = s.oauthRepo.CreateCode(ctx, &model.AuthorizationCode{
+		CodeHash:      hashSecret(code),
+		ClientID:      req.ClientID,
+		UserID:        claims.UserID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         grantedScope(req.Scope),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      authTime,
+		ExpiresAt:     time.Now().Add(s.config.OIDC.CodeTTL),
+		IPAddress:     client.IPAddress,
+		UserAgent:     client.UserAgent,
+	})
+	if err != nil {
+		return nil, errors.NewInternalError("failed to store code")
+	}
+
+	if _, err := s.auth.Logout(ctx, accessToken); err != nil {
+		logger.Warn("Failed to end login page session", zap.Error(err))
+	}
+
+	params := url.Values{}
+	params.Set("code", code)
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	params.Set("iss", s.Issuer())
+
+	return &AuthorizationResponse{
+		RedirectTo: withQuery(req.RedirectURI, params),
+	}, nil
+}
@@ -0,0 +1,97 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// Error is an OAuth 2.0 error response (RFC 6749 section 5.2). Endpoints
+// defined by OAuth answer with it instead of errors.AppError so that
+// standard clients understand them.
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	StatusCode  int    `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+func (e *Error) WriteResponse(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if e.StatusCode == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="zk-auth"`)
+	}
+	w.WriteHeader(e.StatusCode)
+	json.NewEncoder(w).Encode(e)
+}
+
+// redirectURL returns redirectURI with the error added to the query, as
+// authorization errors are reported to the client
+func (e *Error) redirectURL(redirectURI, state, issuer string) string {
+	params := url.Values{}
+	params.Set("error", e.Code)
+	if e.Description != "" {
+		params.Set("error_description", e.Description)
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+	params.Set("iss", issuer)
+	return withQuery(redirectURI, params)
+}
+
+func newError(code, description string, status int) *Error {
+	return &Error{Code: code, Description: description, StatusCode: status}
+}
+
+func errInvalidRequest(description string) *Error {
+	return newError("invalid_request", description, http.StatusBadRequest)
+}
+
+func errInvalidClient() *Error {
+	return newError("invalid_client", "client authentication failed", http.StatusUnauthorized)
+}
+
+func errInvalidGrant(description string) *Error {
+	return newError("invalid_grant", description, http.StatusBadRequest)
+}
+
+func errUnsupportedGrantType() *Error {
+	return newError("unsupported_grant_type", "", http.StatusBadRequest)
+}
+
+func errUnsupportedResponseType() *Error {
+	return newError("unsupported_response_type", "only the code response type is supported", http.StatusBadRequest)
+}
+
+func errInvalidScope(description string) *Error {
+	return newError("invalid_scope", description, http.StatusBadRequest)
+}
+
+func errInvalidToken() *Error {
+	return newError("invalid_token", "the access token is invalid or expired", http.StatusUnauthorized)
+}
+
+func errServer(description string) *Error {
+	return newError("server_error", description, http.StatusInternalServerError)
+}
+
+func withQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,255 @@
+package oidc
+
+import (
+	"embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+)
+
+//go:embed templates/login.html
+var templates embed.FS
+
+var loginPage = template.Must(template.ParseFS(templates, "templates/login.html"))
+
+// loginPageCSP allows the page to run its own scripts and the WebAssembly
+// client, and nothing else
+const loginPageCSP = "default-src 'self'; script-src 'self' 'wasm-unsafe-eval'; style-src 'unsafe-inline'; frame-ancestors 'none'"
+
+type Handler struct {
+	service *Service
+	static  http.Handler
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+		static:  http.StripPrefix("/oauth/static/", http.FileServer(http.Dir(service.config.OIDC.StaticDir))),
+	}
+}
+
+func (h *Handler) HandleDiscovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.service.Discovery())
+}
+
+// HandleAuthorizePage validates an authorization request and serves the
+// login page
+func (h *Handler) HandleAuthorizePage(w http.ResponseWriter, r *http.Request) {
+	req := authorizationRequestFromQuery(r.URL.Query())
+
+	client, err := h.service.CheckClient(r.Context(), req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("authorization failed").WriteResponse(w)
+		}
+		return
+	}
+
+	if oauthErr := h.service.CheckParams(req); oauthErr != nil {
+		http.Redirect(w, r, h.service.ErrorRedirect(req, oauthErr), http.StatusFound)
+		return
+	}
+
+	request, err := json.Marshal(req)
+	if err != nil {
+		errors.NewInternalError("authorization failed").WriteResponse(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", loginPageCSP)
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Cache-Control", "no-store")
+	loginPage.Execute(w, map[string]string{
+		"ClientName": client.Name,
+		"Request":    string(request),
+	})
+}
+
+// HandleAuthorize is called by the login page after the SRP login and
+// returns the redirect carrying the authorization code
+func (h *Handler) HandleAuthorize(w http.ResponseWriter, r *http.Request) {
+	token := bearerToken(r)
+	if token == "" {
+		errors.NewAuthenticationError("missing authorization token").WriteResponse(w)
+		return
+	}
+
+	var req AuthorizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.NewBadRequestError("invalid request body").WriteResponse(w)
+		return
+	}
+
+	resp, err := h.service.Authorize(r.Context(), &req, token)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			appErr.WriteResponse(w)
+		} else {
+			errors.NewInternalError("authorization failed").WriteResponse(w)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) HandleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		errInvalidRequest("invalid form body").WriteResponse(w)
+		return
+	}
+
+	client, oauthErr := h.authenticateClient(r)
+	if oauthErr != nil {
+		oauthErr.WriteResponse(w)
+		return
+	}
+
+	var resp *TokenResponse
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		resp, oauthErr = h.service.ExchangeCode(r.Context(), client,
+			r.PostForm.Get("code"),
+			r.PostForm.Get("redirect_uri"),
+			r.PostForm.Get("code_verifier"),
+		)
+	case "refresh_token":
+		resp, oauthErr = h.service.Refresh(r.Context(), client, r.PostForm.Get("refresh_token"))
+	default:
+		oauthErr = errUnsupportedGrantType()
+	}
+	if oauthErr != nil {
+		oauthErr.WriteResponse(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleIntrospect answers RFC 7662 token introspection requests
+func (h *Handler) HandleIntrospect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		errInvalidRequest("invalid form body").WriteResponse(w)
+		return
+	}
+
+	client, oauthErr := h.authenticateClient(r)
+	if oauthErr != nil {
+		oauthErr.WriteResponse(w)
+		return
+	}
+
+	resp, oauthErr := h.service.Introspect(r.Context(), client, r.PostForm.Get("token"))
+	if oauthErr != nil {
+		oauthErr.WriteResponse(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleRevoke answers RFC 7009 token revocation requests
+func (h *Handler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		errInvalidRequest("invalid form body").WriteResponse(w)
+		return
+	}
+
+	client, oauthErr := h.authenticateClient(r)
+	if oauthErr != nil {
+		oauthErr.WriteResponse(w)
+		return
+	}
+
+	if oauthErr := h.service.Revoke(r.Context(), client, r.PostForm.Get("token")); oauthErr != nil {
+		oauthErr.WriteResponse(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) HandleUserInfo(w http.ResponseWriter, r *http.Request) {
+	token := bearerToken(r)
+	if token == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="zk-auth"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	info, oauthErr := h.service.UserInfo(r.Context(), token)
+	if oauthErr != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(info)
+}
+
+// HandleRegister registers a client (RFC 7591). It requires the configured
+// initial access token as a bearer token.
+func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
+	if !h.service.CheckRegistrationToken(bearerToken(r)) {
+		errors.NewAuthenticationError("invalid registration token").WriteResponse(w)
+		return
+	}
+
+	var req ClientRegistrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		newError("invalid_client_metadata", "invalid request body", http.StatusBadRequest).WriteResponse(w)
+		return
+	}
+
+	resp, oauthErr := h.service.RegisterClient(r.Context(), &req)
+	if oauthErr != nil {
+		oauthErr.WriteResponse(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleStatic serves the login page assets
+func (h *Handler) HandleStatic(w http.ResponseWriter, r *http.Request) {
+	h.static.ServeHTTP(w, r)
+}
+
+// authenticateClient authenticates the client of a form request with HTTP
+// Basic credentials or client_id and client_secret form parameters
+func (h *Handler) authenticateClient(r *http.Request) (*model.OAuthClient, *Error) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+	return h.service.AuthenticateClient(r.Context(), clientID, clientSecret)
+}
+
+func bearerToken(r *http.Request) string {
+	parts := strings.Fields(r.Header.Get("Authorization"))
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,68 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+)
+
+// IntrospectionResponse is an RFC 7662 introspection response. Inactive
+// tokens carry only active=false.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	TokenType string `json:"token_type,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	JTI       string `json:"jti,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+}
+
+// Introspect reports whether token is live (RFC 7662). Only confidential
+// clients may introspect, since the endpoint reveals who a token belongs to.
+func (s *Service) Introspect(ctx context.Context, client *model.OAuthClient, token string) (*IntrospectionResponse, *Error) {
+	if client.SecretHash == nil {
+		return nil, newError("unauthorized_client", "public clients cannot introspect tokens", http.StatusForbidden)
+	}
+	if token == "" {
+		return nil, errInvalidRequest("token is required")
+	}
+
+	info, err := s.auth.IntrospectToken(ctx, token)
+	if err != nil {
+		return nil, errServer("failed to introspect token")
+	}
+	if info == nil {
+		return &IntrospectionResponse{Active: false}, nil
+	}
+
+	return &IntrospectionResponse{
+		Active:    true,
+		TokenType: info.TokenType,
+		Subject:   info.UserID,
+		ClientID:  info.ClientID,
+		Username:  info.Username,
+		SessionID: info.SessionID,
+		JTI:       info.JTI,
+		IssuedAt:  info.IssuedAt.Unix(),
+		ExpiresAt: info.ExpiresAt.Unix(),
+		Issuer:    s.Issuer(),
+	}, nil
+}
+
+// Revoke revokes an access or refresh token issued to client (RFC 7009).
+// Unknown tokens and tokens of other clients are not an error, they are
+// left alone (RFC 7009 section 2.1).
+func (s *Service) Revoke(ctx context.Context, client *model.OAuthClient, token string) *Error {
+	if token == "" {
+		return errInvalidRequest("token is required")
+	}
+	if err := s.auth.RevokeClientToken(ctx, client.ID, token); err != nil {
+		return errServer("failed to revoke token")
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	verifier := strings.Repeat("a", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	if !verifyCodeChallenge(verifier, challenge) {
+		t.Error("matching verifier rejected")
+	}
+	if verifyCodeChallenge(strings.Repeat("b", 43), challenge) {
+		t.Error("wrong verifier accepted")
+	}
+
+	short := "abc"
+	sum = sha256.Sum256([]byte(short))
+	if verifyCodeChallenge(short, base64.RawURLEncoding.EncodeToString(sum[:])) {
+		t.Error("verifier shorter than 43 characters accepted")
+	}
+}
+
+func TestValidRedirectURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want bool
+	}{
+		{"https://app.example.com/callback", true},
+		{"http://localhost:8000/callback", true},
+		{"http://127.0.0.1:8000/callback", true},
+		{"http://[::1]/callback", true},
+		{"http://app.example.com/callback", false},
+		{"https://app.example.com/callback#frag", false},
+		{"/callback", false},
+		{"javascript:alert(1)", false},
+	}
+
+	for _, tt := range tests {
+		if got := validRedirectURI(tt.uri); got != tt.want {
+			t.Errorf("validRedirectURI(%q) = %v, want %v", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestCheckParams(t *testing.T) {
+	s := &Service{}
+	valid := AuthorizationRequest{
+		ResponseType:        "code",
+		Scope:               "openid profile email",
+		CodeChallenge:       strings.Repeat("x", 43),
+		CodeChallengeMethod: "S256",
+	}
+	if err := s.CheckParams(&valid); err != nil {
+		t.Errorf("CheckParams() error = %v", err)
+	}
+	if got := grantedScope(valid.Scope); got != "openid profile" {
+		t.Errorf("grantedScope() = %q", got)
+	}
+
+	tests := map[string]func(*AuthorizationRequest){
+		"unsupported_response_type": func(r *AuthorizationRequest) { r.ResponseType = "token" },
+		"invalid_scope":             func(r *AuthorizationRequest) { r.Scope = "profile" },
+		"invalid_request":           func(r *AuthorizationRequest) { r.CodeChallengeMethod = "plain" },
+	}
+	for code, mutate := range tests {
+		req := valid
+		mutate(&req)
+		if err := s.CheckParams(&req); err == nil || err.Code != code {
+			t.Errorf("CheckParams() = %v, want %s", err, code)
+		}
+	}
+}
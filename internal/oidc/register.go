@@ -0,0 +1,115 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+)
+
+// maxRedirectURIs bounds the redirect URIs of a single client
+const maxRedirectURIs = 10
+
+// ClientRegistrationRequest is the client metadata of RFC 7591
+type ClientRegistrationRequest struct {
+	RedirectURIs            []string `json:"redirect_uris"`
+	ClientName              string   `json:"client_name,omitempty"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+}
+
+type ClientRegistrationResponse struct {
+	ClientID                string   `json:"client_id"`
+	ClientSecret            string   `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64    `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   int64    `json:"client_secret_expires_at"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	ClientName              string   `json:"client_name,omitempty"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+}
+
+// CheckRegistrationToken reports whether token is the configured initial
+// access token. Registration is closed when none is configured.
+func (s *Service) CheckRegistrationToken(token string) bool {
+	expected := s.config.OIDC.RegistrationToken
+	return expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// RegisterClient registers a relying party. Confidential clients get a
+// secret that is shown once; public clients authenticate with PKCE only.
+func (s *Service) RegisterClient(ctx context.Context, req *ClientRegistrationRequest) (*ClientRegistrationResponse, *Error) {
+	if len(req.RedirectURIs) == 0 || len(req.RedirectURIs) > maxRedirectURIs {
+		return nil, newError("invalid_redirect_uri", "between 1 and 10 redirect_uris are required", http.StatusBadRequest)
+	}
+	for _, uri := range req.RedirectURIs {
+		if !validRedirectURI(uri) {
+			return nil, newError("invalid_redirect_uri", "redirect_uris must be absolute https URLs without a fragment, or http on a loopback address", http.StatusBadRequest)
+		}
+	}
+	if len(req.ClientName) > 255 {
+		return nil, newError("invalid_client_metadata", "client_name is too long", http.StatusBadRequest)
+	}
+
+	method := req.TokenEndpointAuthMethod
+	if method == "" {
+		method = "client_secret_basic"
+	}
+	if method != "client_secret_basic" && method != "client_secret_post" && method != "none" {
+		return nil, newError("invalid_client_metadata", "unsupported token_endpoint_auth_method", http.StatusBadRequest)
+	}
+
+	clientID, err := newSecret()
+	if err != nil {
+		return nil, errServer("failed to generate client_id")
+	}
+	client := &model.OAuthClient{
+		ID:           clientID,
+		Name:         req.ClientName,
+		RedirectURIs: req.RedirectURIs,
+	}
+
+	var secret string
+	if method != "none" {
+		secret, err = newSecret()
+		if err != nil {
+			return nil, errServer("failed to generate client_secret")
+		}
+		client.SecretHash = hashSecret(secret)
+	}
+
+	if err := s.oauthRepo.CreateClient(ctx, client); err != nil {
+		return nil, errServer("failed to store client")
+	}
+
+	return &ClientRegistrationResponse{
+		ClientID:                client.ID,
+		ClientSecret:            secret,
+		ClientIDIssuedAt:        client.CreatedAt.Unix(),
+		RedirectURIs:            client.RedirectURIs,
+		ClientName:              client.Name,
+		TokenEndpointAuthMethod: method,
+	}, nil
+}
+
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || u.Host == "" {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		// Native apps listen on a loopback port (RFC 8252 section 7.3)
+		if u.Hostname() == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(u.Hostname())
+		return ip != nil && ip.IsLoopback()
+	default:
+		return false
+	}
+}
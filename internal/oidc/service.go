@@ -0,0 +1,149 @@
+// Package oidc is an OpenID Connect provider for the authorization code flow
+// with PKCE. Users log in with SRP on a hosted page; the handshake and the
+// session are handled by auth.Service, and this package binds the login to
+// an authorization code and issues ID tokens.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/auth"
+	"github.com/francisco3ferraz/zk-auth/internal/config"
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+	"github.com/francisco3ferraz/zk-auth/internal/logger"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	scopeOpenID  = "openid"
+	scopeProfile = "profile"
+
+	// secretLength is the number of random bytes in codes and client secrets
+	secretLength = 32
+)
+
+type Service struct {
+	auth      *auth.Service
+	userRepo  *model.UserRepository
+	oauthRepo *model.OAuthRepository
+	config    *config.Config
+}
+
+func NewService(authService *auth.Service, users *model.UserRepository, oauth *model.OAuthRepository, cfg *config.Config) *Service {
+	return &Service{
+		auth:      authService,
+		userRepo:  users,
+		oauthRepo: oauth,
+		config:    cfg,
+	}
+}
+
+// Issuer returns the issuer identifier without a trailing slash
+func (s *Service) Issuer() string {
+	return strings.TrimRight(s.config.OIDC.Issuer, "/")
+}
+
+// Discovery is the OpenID Provider metadata document
+type Discovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	RegistrationEndpoint              string   `json:"registration_endpoint,omitempty"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	AuthorizationResponseIssParameter bool     `json:"authorization_response_iss_parameter_supported"`
+}
+
+func (s *Service) Discovery() *Discovery {
+	issuer := s.Issuer()
+	doc := &Discovery{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserinfoEndpoint:                  issuer + "/oauth/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		RevocationEndpoint:                issuer + "/oauth/revoke",
+		ScopesSupported:                   []string{scopeOpenID, scopeProfile},
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{s.auth.SigningAlgorithm()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "preferred_username"},
+		AuthorizationResponseIssParameter: true,
+	}
+	if s.config.OIDC.RegistrationToken != "" {
+		doc.RegistrationEndpoint = issuer + "/oauth/register"
+	}
+	return doc
+}
+
+// StartCleanup removes expired authorization codes every minute until ctx
+// is cancelled
+func (s *Service) StartCleanup(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.oauthRepo.DeleteExpiredCodes(ctx); err != nil {
+					logger.Warn("Failed to delete expired authorization codes", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// newSecret returns a random URL-safe string for codes and client secrets
+func newSecret() (string, error) {
+	b, err := crypto.GenerateRandomBytes(secretLength)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// grantedScope keeps the supported scopes of a space separated list
+func grantedScope(requested string) string {
+	var granted []string
+	for _, scope := range strings.Fields(requested) {
+		if scope == scopeOpenID || scope == scopeProfile {
+			granted = append(granted, scope)
+		}
+	}
+	return strings.Join(granted, " ")
+}
+
+func hasScope(scopes, scope string) bool {
+	for _, s := range strings.Fields(scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/auth"
+	"github.com/francisco3ferraz/zk-auth/internal/errors"
+	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenResponse is a successful token endpoint response (RFC 6749 section 5.1)
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// IDTokenClaims are the claims of an OpenID Connect ID token
+type IDTokenClaims struct {
+	AuthTime          int64  `json:"auth_time"`
+	Nonce             string `json:"nonce,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// AuthenticateClient checks the client's credentials. Public clients have no
+// secret and must not send one.
+func (s *Service) AuthenticateClient(ctx context.Context, clientID, clientSecret string) (*model.OAuthClient, *Error) {
+	if clientID == "" {
+		return nil, errInvalidClient()
+	}
+
+	client, err := s.oauthRepo.GetClient(ctx, clientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errInvalidClient()
+		}
+		return nil, errServer("failed to retrieve client")
+	}
+
+	if client.SecretHash == nil {
+		if clientSecret != "" {
+			return nil, errInvalidClient()
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare(hashSecret(clientSecret), client.SecretHash) != 1 {
+		return nil, errInvalidClient()
+	}
+	return client, nil
+}
+
+// ExchangeCode redeems an authorization code for a new session's tokens and
+// an ID token. The access token is only good for the userinfo endpoint.
+func (s *Service) ExchangeCode(ctx context.Context, client *model.OAuthClient, code, redirectURI, codeVerifier string) (*TokenResponse, *Error) {
+	if code == "" || codeVerifier == "" {
+		return nil, errInvalidRequest("code and code_verifier are required")
+	}
+
+	stored, err := s.oauthRepo.TakeCode(ctx, hashSecret(code))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errInvalidGrant("invalid authorization code")
+		}
+		return nil, errServer("failed to retrieve authorization code")
+	}
+
+	if stored.ClientID != client.ID {
+		return nil, errInvalidGrant("authorization code was issued to another client")
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, errInvalidGrant("authorization code has expired")
+	}
+	if stored.RedirectURI != redirectURI {
+		return nil, errInvalidGrant("redirect_uri does not match the authorization request")
+	}
+	if !verifyCodeChallenge(codeVerifier, stored.CodeChallenge) {
+		return nil, errInvalidGrant("code_verifier does not match the code_challenge")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, errInvalidGrant("user no longer exists")
+	}
+
+	tokens, err := s.auth.CreateSession(ctx, &auth.ClientGrant{
+		UserID:   user.ID,
+		ClientID: client.ID,
+		AuthTime: stored.AuthTime,
+		Client: &auth.ClientInfo{
+			IPAddress: stored.IPAddress,
+			UserAgent: stored.UserAgent,
+		},
+	})
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.StatusCode < 500 {
+			return nil, errInvalidGrant(appErr.Message)
+		}
+		return nil, errServer("failed to create session")
+	}
+
+	now := time.Now()
+	claims := IDTokenClaims{
+		AuthTime: stored.AuthTime.Unix(),
+		Nonce:    stored.Nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.Issuer(),
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{client.ID},
+			ExpiresAt: jwt.NewNumericDate(tokens.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	if hasScope(stored.Scope, scopeProfile) {
+		claims.PreferredUsername = user.Username
+	}
+
+	idToken, err := s.auth.SignToken(claims)
+	if err != nil {
+		return nil, errServer("failed to sign ID token")
+	}
+
+	resp := tokenResponse(tokens)
+	resp.IDToken = idToken
+	resp.Scope = stored.Scope
+	return resp, nil
+}
+
+// Refresh rotates a refresh token that this endpoint issued to client
+func (s *Service) Refresh(ctx context.Context, client *model.OAuthClient, refreshToken string) (*TokenResponse, *Error) {
+	if refreshToken == "" {
+		return nil, errInvalidRequest("refresh_token is required")
+	}
+
+	refreshed, err := s.auth.RefreshClientToken(ctx, client.ID, refreshToken)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.StatusCode < 500 {
+			return nil, errInvalidGrant(appErr.Message)
+		}
+		return nil, errServer("failed to refresh token")
+	}
+
+	return tokenResponse(&auth.SessionTokens{
+		AccessToken:      refreshed.Token,
+		ExpiresAt:        refreshed.ExpiresAt,
+		RefreshToken:     refreshed.RefreshToken,
+		RefreshExpiresAt: refreshed.RefreshExpiresAt,
+	}), nil
+}
+
+func tokenResponse(tokens *auth.SessionTokens) *TokenResponse {
+	return &TokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(tokens.ExpiresAt).Seconds()),
+		RefreshToken: tokens.RefreshToken,
+	}
+}
+
+// verifyCodeChallenge checks an S256 PKCE verifier (RFC 7636 section 4.6)
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
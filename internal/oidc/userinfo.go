@@ -0,0 +1,29 @@
+package oidc
+
+import (
+	"context"
+)
+
+// UserInfo is the userinfo endpoint response
+type UserInfo struct {
+	Subject           string `json:"sub"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// UserInfo returns the claims about the owner of accessToken
+func (s *Service) UserInfo(ctx context.Context, accessToken string) (*UserInfo, *Error) {
+	claims, err := s.auth.ValidateClientToken(accessToken)
+	if err != nil || claims.UserID == "" {
+		return nil, errInvalidToken()
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, errInvalidToken()
+	}
+
+	return &UserInfo{
+		Subject:           user.ID,
+		PreferredUsername: user.Username,
+	}, nil
+}
@@ -7,26 +7,65 @@ import (
 
 	"github.com/francisco3ferraz/zk-auth/internal/auth"
 	"github.com/francisco3ferraz/zk-auth/internal/database"
+	"github.com/francisco3ferraz/zk-auth/internal/oidc"
 	"github.com/gorilla/mux"
 )
 
-func SetupRoutes(r *mux.Router, db *database.DB, authService *auth.Service, authHandler *auth.Handler) {
+func SetupRoutes(r *mux.Router, db *database.DB, authService *auth.Service, authHandler *auth.Handler, oidcHandler *oidc.Handler) {
 	r.HandleFunc("/health", handleHealth(db)).Methods("GET")
 	r.HandleFunc("/", handleAPIInfo).Methods("GET")
+	r.HandleFunc("/.well-known/jwks.json", authHandler.HandleJWKS).Methods("GET")
+
+	if oidcHandler != nil {
+		r.HandleFunc("/.well-known/openid-configuration", oidcHandler.HandleDiscovery).Methods("GET")
+		r.HandleFunc("/oauth/authorize", oidcHandler.HandleAuthorizePage).Methods("GET")
+		r.HandleFunc("/oauth/authorize", oidcHandler.HandleAuthorize).Methods("POST")
+		r.HandleFunc("/oauth/token", oidcHandler.HandleToken).Methods("POST")
+		r.HandleFunc("/oauth/introspect", oidcHandler.HandleIntrospect).Methods("POST")
+		r.HandleFunc("/oauth/revoke", oidcHandler.HandleRevoke).Methods("POST")
+		r.HandleFunc("/oauth/userinfo", oidcHandler.HandleUserInfo).Methods("GET", "POST")
+		r.HandleFunc("/oauth/register", oidcHandler.HandleRegister).Methods("POST")
+		r.PathPrefix("/oauth/static/").HandlerFunc(oidcHandler.HandleStatic).Methods("GET")
+	}
 
 	api := r.PathPrefix("/api/v1").Subrouter()
 
 	api.HandleFunc("/register", authHandler.HandleRegister).Methods("POST")
+	api.HandleFunc("/register/params", authHandler.HandleRegistrationParams).Methods("GET")
 	api.HandleFunc("/auth/challenge", authHandler.HandleChallenge).Methods("POST")
 	api.HandleFunc("/auth/verify", authHandler.HandleVerify).Methods("POST")
+	api.HandleFunc("/auth/refresh", authHandler.HandleRefresh).Methods("POST")
+	api.HandleFunc("/auth/mfa/totp", authHandler.HandleMFATOTP).Methods("POST")
+	api.HandleFunc("/auth/recover", authHandler.HandleRecoverAccount).Methods("POST")
+
+	api.HandleFunc("/opaque/register/start", authHandler.HandleOPAQUERegisterStart).Methods("POST")
+	api.HandleFunc("/opaque/register/finish", authHandler.HandleOPAQUERegisterFinish).Methods("POST")
+	api.HandleFunc("/opaque/login/start", authHandler.HandleOPAQUELoginStart).Methods("POST")
+	api.HandleFunc("/opaque/login/finish", authHandler.HandleOPAQUELoginFinish).Methods("POST")
 
 	protected := api.PathPrefix("").Subrouter()
 	protected.Use(AuthMiddleware(authService))
 
 	protected.HandleFunc("/auth/logout", authHandler.HandleLogout).Methods("POST")
-	protected.HandleFunc("/auth/refresh", authHandler.HandleRefresh).Methods("POST")
+	protected.HandleFunc("/auth/reauth", authHandler.HandleReauthChallenge).Methods("POST")
+	protected.HandleFunc("/opaque/reauth", authHandler.HandleOPAQUEReauth).Methods("POST")
 	protected.HandleFunc("/auth/password", authHandler.HandleChangePassword).Methods("PUT")
+	protected.HandleFunc("/auth/recovery-codes", authHandler.HandleRecoveryCodes).Methods("POST")
+	protected.HandleFunc("/account", authHandler.HandleDeleteAccount).Methods("DELETE")
+	protected.HandleFunc("/account/deletion", authHandler.HandleCancelAccountDeletion).Methods("DELETE")
+	protected.HandleFunc("/mfa/totp/enroll", authHandler.HandleTOTPEnroll).Methods("POST")
+	protected.HandleFunc("/mfa/totp/confirm", authHandler.HandleTOTPConfirm).Methods("POST")
 	protected.HandleFunc("/profile", authHandler.HandleProfile).Methods("GET")
+	protected.HandleFunc("/profile", authHandler.HandleUpdateProfile).Methods("PATCH")
+	protected.HandleFunc("/sessions", authHandler.HandleListSessions).Methods("GET")
+	protected.HandleFunc("/sessions", authHandler.HandleRevokeOtherSessions).Methods("DELETE")
+	protected.HandleFunc("/sessions/{id}", authHandler.HandleRevokeSession).Methods("DELETE")
+
+	readRoles := RequirePermission(auth.PermissionRolesRead)
+	manageRoles := RequirePermission(auth.PermissionRolesManage)
+	protected.Handle("/admin/users/{id}/roles", readRoles(http.HandlerFunc(authHandler.HandleUserRoles))).Methods("GET")
+	protected.Handle("/admin/users/{id}/roles/{role}", manageRoles(http.HandlerFunc(authHandler.HandleGrantRole))).Methods("PUT")
+	protected.Handle("/admin/users/{id}/roles/{role}", manageRoles(http.HandlerFunc(authHandler.HandleRevokeRole))).Methods("DELETE")
 
 	r.NotFoundHandler = http.HandlerFunc(handleNotFound)
 }
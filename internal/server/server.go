@@ -10,6 +10,7 @@ import (
 	"github.com/francisco3ferraz/zk-auth/internal/database"
 	"github.com/francisco3ferraz/zk-auth/internal/logger"
 	"github.com/francisco3ferraz/zk-auth/internal/model"
+	"github.com/francisco3ferraz/zk-auth/internal/oidc"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
@@ -19,15 +20,47 @@ type Server struct {
 	db         *database.DB
 	config     *config.Config
 	auth       *auth.Service
+	oidc       *oidc.Service // nil when the OpenID Connect provider is disabled
 }
 
 func New(cfg *config.Config, db *database.DB) (*Server, error) {
-	userRepo := model.NewUserRepository(db.Pool())
-	sessionRepo := model.NewSessionRepository(db.Pool())
+	repos := auth.Repositories{
+		Users:    model.NewUserRepository(db.Pool()),
+		Sessions: model.NewSessionRepository(db.Pool()),
+		OPAQUE:   model.NewOPAQUERepository(db.Pool()),
+		Refresh:  model.NewRefreshTokenRepository(db.Pool()),
+		TOTP:     model.NewTOTPRepository(db.Pool()),
+		Recovery: model.NewRecoveryCodeRepository(db.Pool()),
+		Profiles: model.NewProfileRepository(db.Pool()),
+		Roles:    model.NewRoleRepository(db.Pool()),
+	}
 
-	authService := auth.NewService(userRepo, sessionRepo, cfg)
+	challengeKey, err := auth.ChallengeEncryptionKey(&cfg.Security)
+	if err != nil {
+		return nil, err
+	}
+	challenges, err := auth.NewChallengeStore(cfg.Security.ChallengeStore, model.NewChallengeRepository(db.Pool()), challengeKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CHALLENGE_STORE: %w", err)
+	}
+	repos.Challenges = challenges
+	repos.RevokedTokens = model.NewRevokedTokenRepository(db.Pool())
+	repos.LoginFailures = model.NewLoginFailureRepository(db.Pool())
+	repos.SigningKeys = model.NewSigningKeyRepository(db.Pool())
+
+	authService, err := auth.NewService(repos, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create auth service: %w", err)
+	}
 	authHandler := auth.NewHandler(authService)
 
+	var oidcService *oidc.Service
+	var oidcHandler *oidc.Handler
+	if cfg.OIDC.Issuer != "" {
+		oidcService = oidc.NewService(authService, repos.Users, model.NewOAuthRepository(db.Pool()), cfg)
+		oidcHandler = oidc.NewHandler(oidcService)
+	}
+
 	// Create rate limiter
 	rateLimiter := NewRateLimiter(&cfg.Security)
 
@@ -38,9 +71,10 @@ func New(cfg *config.Config, db *database.DB) (*Server, error) {
 		LoggingMiddleware,
 		CORSMiddleware,
 		RateLimitMiddleware(rateLimiter),
+		ClientInfoMiddleware,
 	)
 
-	SetupRoutes(r, db, authService, authHandler)
+	SetupRoutes(r, db, authService, authHandler, oidcHandler)
 
 	srv := &http.Server{
 		Addr:           fmt.Sprintf(":%s", cfg.Server.Port),
@@ -56,6 +90,7 @@ func New(cfg *config.Config, db *database.DB) (*Server, error) {
 		db:         db,
 		config:     cfg,
 		auth:       authService,
+		oidc:       oidcService,
 	}
 
 	return server, nil
@@ -68,6 +103,11 @@ func (s *Server) Start(ctx context.Context) error {
 
 	// Start background cleanup for expired auth challenges
 	s.auth.StartCleanup(ctx)
+	s.auth.StartRevocationSync(ctx)
+	s.auth.StartKeyringSync(ctx)
+	if s.oidc != nil {
+		s.oidc.StartCleanup(ctx)
+	}
 
 	return s.httpServer.ListenAndServe()
 }
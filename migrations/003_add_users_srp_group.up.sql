@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN srp_group INTEGER NOT NULL DEFAULT 2048;
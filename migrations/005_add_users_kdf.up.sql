@@ -0,0 +1,2 @@
+-- Existing verifiers were derived without key stretching
+ALTER TABLE users ADD COLUMN kdf JSONB NOT NULL DEFAULT '{"algorithm": "none"}';
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN srp_mode VARCHAR(16) NOT NULL DEFAULT 'legacy';
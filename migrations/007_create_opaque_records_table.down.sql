@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS update_opaque_records_updated_at ON opaque_records;
+DROP TABLE IF EXISTS opaque_records;
+
+DELETE FROM users WHERE salt IS NULL OR verifier IS NULL;
+ALTER TABLE users ALTER COLUMN salt SET NOT NULL;
+ALTER TABLE users ALTER COLUMN verifier SET NOT NULL;
@@ -0,0 +1,14 @@
+-- Accounts registered with OPAQUE have no SRP salt or verifier
+ALTER TABLE users ALTER COLUMN salt DROP NOT NULL;
+ALTER TABLE users ALTER COLUMN verifier DROP NOT NULL;
+
+CREATE TABLE opaque_records (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    record BYTEA NOT NULL,
+    ksf JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TRIGGER update_opaque_records_updated_at BEFORE UPDATE ON opaque_records
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,9 @@
+-- Pending SRP and OPAQUE challenges, shared by all server instances
+CREATE TABLE auth_challenges (
+    id VARCHAR(64) PRIMARY KEY,
+    data BYTEA NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_auth_challenges_expires_at ON auth_challenges(expires_at);
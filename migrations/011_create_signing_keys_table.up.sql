@@ -0,0 +1,14 @@
+-- Token signing keys. One key is active at a time; retired keys still verify
+-- tokens until accept_until, when the last token they signed has expired.
+-- Private keys are encrypted with SIGNING_KEY_ENCRYPTION_KEY.
+CREATE TABLE signing_keys (
+    kid VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(16) NOT NULL,
+    private_key_encrypted BYTEA NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'active',
+    accept_until TIMESTAMP WITH TIME ZONE,
+    retired_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_signing_keys_active ON signing_keys(status) WHERE status = 'active';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS authorization_codes;
+DROP TRIGGER IF EXISTS update_oauth_clients_updated_at ON oauth_clients;
+DROP TABLE IF EXISTS oauth_clients;
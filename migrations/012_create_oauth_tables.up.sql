@@ -0,0 +1,28 @@
+-- OpenID Connect clients. Public clients have no secret and rely on PKCE.
+CREATE TABLE oauth_clients (
+    client_id VARCHAR(64) PRIMARY KEY,
+    client_secret_hash BYTEA,
+    client_name VARCHAR(255) NOT NULL DEFAULT '',
+    redirect_uris TEXT[] NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TRIGGER update_oauth_clients_updated_at BEFORE UPDATE ON oauth_clients
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Authorization codes are stored as SHA-256 hashes and used once
+CREATE TABLE authorization_codes (
+    code_hash BYTEA PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    nonce TEXT NOT NULL DEFAULT '',
+    code_challenge VARCHAR(128) NOT NULL,
+    auth_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_authorization_codes_expires_at ON authorization_codes(expires_at);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_totp_credentials_updated_at ON totp_credentials;
+DROP TABLE IF EXISTS totp_credentials;
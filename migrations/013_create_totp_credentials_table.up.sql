@@ -0,0 +1,14 @@
+-- TOTP secrets are encrypted with MFA_ENCRYPTION_KEY. A credential counts
+-- as a second factor once confirmed_at is set; last_used_step stops a code
+-- from being accepted twice.
+CREATE TABLE totp_credentials (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret_encrypted BYTEA NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TRIGGER update_totp_credentials_updated_at BEFORE UPDATE ON totp_credentials
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
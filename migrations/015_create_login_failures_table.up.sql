@@ -0,0 +1,9 @@
+-- Consecutive failed logins per username. Rows are keyed by name rather
+-- than user ID so that unknown usernames are throttled like real ones.
+CREATE TABLE login_failures (
+    username VARCHAR(255) PRIMARY KEY,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_failures_last_failed_at ON login_failures(last_failed_at);
@@ -0,0 +1,5 @@
+ALTER TABLE sessions
+    ADD COLUMN ip_address VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    ADD COLUMN device_name VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN last_seen_at TIMESTAMP WITH TIME ZONE;
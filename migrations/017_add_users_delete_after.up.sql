@@ -0,0 +1,4 @@
+-- Set while a deletion requested by the user waits out its grace period
+ALTER TABLE users ADD COLUMN delete_after TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_users_delete_after ON users(delete_after) WHERE delete_after IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_user_profiles_updated_at ON user_profiles;
+DROP TABLE IF EXISTS user_profiles;
@@ -0,0 +1,6 @@
+ALTER TABLE authorization_codes
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS ip_address;
+
+DROP INDEX IF EXISTS idx_sessions_client_id;
+ALTER TABLE sessions DROP COLUMN IF EXISTS client_id;
@@ -0,0 +1,11 @@
+-- Sessions opened at the token endpoint belong to the OpenID Connect client
+-- they were issued to; first-party sessions have no client
+ALTER TABLE sessions
+    ADD COLUMN client_id VARCHAR(64) REFERENCES oauth_clients(client_id) ON DELETE CASCADE;
+
+CREATE INDEX idx_sessions_client_id ON sessions(client_id);
+
+-- Where the user authorized the code from, recorded on the client's session
+ALTER TABLE authorization_codes
+    ADD COLUMN ip_address VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN user_agent VARCHAR(512) NOT NULL DEFAULT '';
@@ -0,0 +1,58 @@
+package zkclient
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// DeleteAccount proves knowledge of password with a fresh SRP handshake and
+// deletes the account, signing out every session including this one. If the
+// server keeps deleted accounts for a grace period, the returned time is
+// when the deletion takes effect; until then, logging in again and calling
+// CancelAccountDeletion keeps the account.
+func (c *Client) DeleteAccount(ctx context.Context, password string) (*time.Time, error) {
+	c.mu.RLock()
+	username := c.username
+	c.mu.RUnlock()
+	if username == "" {
+		return nil, ErrNotAuthenticated
+	}
+
+	var challenge reauthChallengeResponse
+	if err := c.do(ctx, http.MethodPost, "/auth/reauth", struct{}{}, &challenge, true); err != nil {
+		return nil, err
+	}
+
+	hs, err := c.answerChallenge(username, password, challenge.srpParams, challenge.Salt, challenge.ServerB)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp deleteAccountResponse
+	req := &deleteAccountRequest{
+		ChallengeID: challenge.ChallengeID,
+		ClientA:     hex.EncodeToString(hs.A.Bytes()),
+		ClientProof: hex.EncodeToString(hs.M1),
+	}
+	if err := c.do(ctx, http.MethodDelete, "/account", req, &resp, true); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.username = ""
+	c.session = nil
+	c.mu.Unlock()
+
+	if err := hs.checkServerProof(resp.ServerProof); err != nil {
+		return nil, err
+	}
+	return resp.DeleteAfter, nil
+}
+
+// CancelAccountDeletion keeps an account whose deletion is still in its
+// grace period
+func (c *Client) CancelAccountDeletion(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/account/deletion", nil, nil, true)
+}
@@ -0,0 +1,435 @@
+// Package zkclient is a Go client for the zk-auth API. It runs the client side
+// of the SRP handshake locally, using the same hashing and padding rules as the
+// server, so the password never leaves the calling process.
+package zkclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+)
+
+const apiPrefix = "/api/v1"
+
+// Client talks to a zk-auth server and keeps the token of the current session
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	mu       sync.RWMutex
+	username string
+	session  *Session
+	// mfaTicket is set while a login waits for the second factor
+	mfaTicket string
+	// deviceName labels the sessions this client opens
+	deviceName string
+}
+
+// New creates a client for the server at baseURL, e.g. "https://auth.example.com".
+// If httpClient is nil, http.DefaultClient is used.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// SetDeviceName sets a label, such as "Work laptop", for the sessions opened
+// by later logins. It appears in the session list.
+func (c *Client) SetDeviceName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deviceName = name
+}
+
+// Session returns the current session, or nil if the client is not logged in
+func (c *Client) Session() *Session {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.session == nil {
+		return nil
+	}
+	session := *c.session
+	return &session
+}
+
+func (c *Client) device() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.deviceName
+}
+
+// Register creates an account. The salt and verifier are computed locally,
+// with the parameters the server asks for, and only those are sent.
+func (c *Client) Register(ctx context.Context, username, password string) (*Registration, error) {
+	creds, err := c.newCredentials(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &registerRequest{
+		Username:    username,
+		credentials: *creds,
+	}
+
+	var resp registerResponse
+	if err := c.do(ctx, http.MethodPost, "/register", req, &resp, false); err != nil {
+		return nil, err
+	}
+
+	return &Registration{
+		UserID:   resp.UserID,
+		Username: resp.Username,
+	}, nil
+}
+
+// Login runs the SRP challenge and verify steps and checks the server proof
+// before accepting the issued token. If the server asks for a stronger
+// verifier, the password is changed to itself, which signs out the other
+// sessions of the account.
+func (c *Client) Login(ctx context.Context, username, password string) (*Session, error) {
+	var challenge challengeResponse
+	challengeReq := &challengeRequest{Username: username, DeviceName: c.device()}
+	if err := c.do(ctx, http.MethodPost, "/auth/challenge", challengeReq, &challenge, false); err != nil {
+		return nil, err
+	}
+
+	hs, err := c.answerChallenge(username, password, challenge.srpParams, challenge.Salt, challenge.ServerB)
+	if err != nil {
+		return nil, err
+	}
+
+	var verify verifyResponse
+	verifyReq := &verifyRequest{
+		SessionID:   challenge.SessionID,
+		ClientA:     hex.EncodeToString(hs.A.Bytes()),
+		ClientProof: hex.EncodeToString(hs.M1),
+	}
+	if err := c.do(ctx, http.MethodPost, "/auth/verify", verifyReq, &verify, false); err != nil {
+		return nil, err
+	}
+
+	if err := hs.checkServerProof(verify.ServerProof); err != nil {
+		return nil, err
+	}
+	if verify.MFAPending {
+		return nil, c.awaitMFA(username, verify.MFATicket)
+	}
+
+	session := &Session{
+		Token:            verify.Token,
+		ExpiresAt:        verify.ExpiresAt,
+		RefreshToken:     verify.RefreshToken,
+		RefreshExpiresAt: verify.RefreshExpiresAt,
+	}
+
+	c.mu.Lock()
+	c.username = username
+	c.session = session
+	c.mu.Unlock()
+
+	// The server asks for a verifier under stronger parameters. The login
+	// stands even if storing one fails; it is tried again next time.
+	if verify.Upgrade != nil {
+		c.ChangePassword(ctx, password, password)
+	}
+
+	return c.Session(), nil
+}
+
+// Refresh exchanges the refresh token for a new access and refresh token.
+// The old refresh token can't be used again.
+func (c *Client) Refresh(ctx context.Context) (*Session, error) {
+	c.mu.RLock()
+	session := c.session
+	c.mu.RUnlock()
+	if session == nil {
+		return nil, ErrNotAuthenticated
+	}
+
+	var resp refreshResponse
+	req := &refreshRequest{RefreshToken: session.RefreshToken}
+	if err := c.do(ctx, http.MethodPost, "/auth/refresh", req, &resp, false); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.session = &Session{
+		Token:            resp.Token,
+		ExpiresAt:        resp.ExpiresAt,
+		RefreshToken:     resp.RefreshToken,
+		RefreshExpiresAt: resp.RefreshExpiresAt,
+	}
+	c.mu.Unlock()
+
+	return c.Session(), nil
+}
+
+// Logout revokes the current token and forgets the session
+func (c *Client) Logout(ctx context.Context) error {
+	if err := c.do(ctx, http.MethodPost, "/auth/logout", nil, nil, true); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.username = ""
+	c.session = nil
+	c.mu.Unlock()
+
+	return nil
+}
+
+// ChangePassword proves knowledge of currentPassword with a fresh SRP
+// handshake bound to the current session and uploads a verifier for
+// newPassword. The server signs out every other session of the account.
+func (c *Client) ChangePassword(ctx context.Context, currentPassword, newPassword string) error {
+	c.mu.RLock()
+	username := c.username
+	c.mu.RUnlock()
+	if username == "" {
+		return ErrNotAuthenticated
+	}
+
+	creds, err := c.newCredentials(ctx, username, newPassword)
+	if err != nil {
+		return err
+	}
+
+	var challenge reauthChallengeResponse
+	if err := c.do(ctx, http.MethodPost, "/auth/reauth", struct{}{}, &challenge, true); err != nil {
+		return err
+	}
+
+	hs, err := c.answerChallenge(username, currentPassword, challenge.srpParams, challenge.Salt, challenge.ServerB)
+	if err != nil {
+		return err
+	}
+
+	var resp changePasswordResponse
+	req := &changePasswordRequest{
+		ChallengeID: challenge.ChallengeID,
+		ClientA:     hex.EncodeToString(hs.A.Bytes()),
+		ClientProof: hex.EncodeToString(hs.M1),
+		credentials: *creds,
+	}
+	if err := c.do(ctx, http.MethodPut, "/auth/password", req, &resp, true); err != nil {
+		return err
+	}
+
+	return hs.checkServerProof(resp.ServerProof)
+}
+
+// newCredentials computes a fresh salt and verifier for password using the
+// parameters the server currently requires for new verifiers.
+func (c *Client) newCredentials(ctx context.Context, username, password string) (*credentials, error) {
+	var params registrationParams
+	if err := c.do(ctx, http.MethodGet, "/register/params", nil, &params, false); err != nil {
+		return nil, err
+	}
+
+	return computeCredentials(username, password, params.srpParams)
+}
+
+// computeCredentials derives a fresh salt and verifier for password
+func computeCredentials(username, password string, params srpParams) (*credentials, error) {
+	srp, err := srpFor(params.SRPGroup, params.HashAlgorithm, params.SRPMode)
+	if err != nil {
+		return nil, err
+	}
+	kdf, err := kdfFor(params.KDF)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := srp.GenerateSalt()
+	if err != nil {
+		return nil, fmt.Errorf("zkclient: failed to generate salt: %w", err)
+	}
+
+	verifier, err := srp.ComputeVerifier(username, password, salt, kdf)
+	if err != nil {
+		return nil, fmt.Errorf("zkclient: failed to compute verifier: %w", err)
+	}
+
+	return &credentials{
+		Salt:          hex.EncodeToString(salt),
+		Verifier:      hex.EncodeToString(verifier.Bytes()),
+		SRPGroup:      srp.Group.Bits,
+		HashAlgorithm: string(srp.Hash),
+		SRPMode:       string(srp.Mode),
+		KDF:           &kdf,
+	}, nil
+}
+
+// handshake is the client side of a completed SRP exchange
+type handshake struct {
+	srp *crypto.SRP
+	A   *big.Int
+	M1  []byte
+	K   []byte
+}
+
+// answerChallenge generates the client ephemeral in the account's group and
+// computes the client proof M1 and session key K for the challenge.
+func (c *Client) answerChallenge(username, password string, params srpParams, saltHex, serverBHex string) (*handshake, error) {
+	srp, err := srpFor(params.SRPGroup, params.HashAlgorithm, params.SRPMode)
+	if err != nil {
+		return nil, err
+	}
+	kdf, err := kdfFor(params.KDF)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := hex.DecodeString(saltHex)
+	if err != nil {
+		return nil, fmt.Errorf("zkclient: invalid salt in challenge: %w", err)
+	}
+	serverBBytes, err := hex.DecodeString(serverBHex)
+	if err != nil {
+		return nil, fmt.Errorf("zkclient: invalid server_b in challenge: %w", err)
+	}
+	B := new(big.Int).SetBytes(serverBBytes)
+
+	a, A, err := srp.GenerateClientKeys()
+	if err != nil {
+		return nil, fmt.Errorf("zkclient: failed to generate client keys: %w", err)
+	}
+
+	u := srp.ComputeU(A, B)
+	x, err := srp.ComputeX(username, password, salt, kdf)
+	if err != nil {
+		return nil, fmt.Errorf("zkclient: %w", err)
+	}
+
+	K, err := srp.ComputeClientSessionKey(B, a, x, u)
+	if err != nil {
+		return nil, fmt.Errorf("zkclient: %w", err)
+	}
+
+	return &handshake{
+		srp: srp,
+		A:   A,
+		M1:  srp.ComputeClientProof(username, salt, A, B, K),
+		K:   K,
+	}, nil
+}
+
+func (hs *handshake) checkServerProof(serverProofHex string) error {
+	serverProof, err := hex.DecodeString(serverProofHex)
+	if err != nil || !hs.srp.VerifyServerProof(hs.A, hs.M1, hs.K, serverProof) {
+		return ErrServerProof
+	}
+	return nil
+}
+
+// srpFor returns the SRP instance for parameters announced by the server.
+// Servers that predate per-user parameters omit them and use the defaults.
+func srpFor(groupBits int, hashAlgorithm, modeName string) (*crypto.SRP, error) {
+	if groupBits == 0 {
+		groupBits = crypto.DefaultGroupBits
+	}
+	if hashAlgorithm == "" {
+		hashAlgorithm = string(crypto.DefaultHashAlgorithm)
+	}
+	if modeName == "" {
+		modeName = string(crypto.DefaultMode)
+	}
+
+	group, err := crypto.GroupByBits(groupBits)
+	if err != nil {
+		return nil, fmt.Errorf("zkclient: %w", err)
+	}
+	hash, err := crypto.ParseHashAlgorithm(hashAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("zkclient: %w", err)
+	}
+	mode, err := crypto.ParseMode(modeName)
+	if err != nil {
+		return nil, fmt.Errorf("zkclient: %w", err)
+	}
+
+	return crypto.NewSRP(group, hash, mode), nil
+}
+
+// kdfFor checks the KDF parameters announced by the server, which bound how
+// much memory and time the client spends deriving x. Accounts created before
+// KDF support have none.
+func kdfFor(params *crypto.KDFParams) (crypto.KDFParams, error) {
+	if params == nil {
+		return crypto.KDFParams{Algorithm: crypto.KDFNone}, nil
+	}
+	if err := params.Validate(); err != nil {
+		return crypto.KDFParams{}, fmt.Errorf("zkclient: %w", err)
+	}
+	return *params, nil
+}
+
+// do sends a JSON request to the API and decodes the JSON response into out.
+// Error responses are returned as *Error.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}, authenticated bool) error {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return fmt.Errorf("zkclient: failed to encode request: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+apiPrefix+path, &payload)
+	if err != nil {
+		return fmt.Errorf("zkclient: failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if authenticated {
+		c.mu.RLock()
+		session := c.session
+		c.mu.RUnlock()
+		if session == nil {
+			return ErrNotAuthenticated
+		}
+		req.Header.Set("Authorization", "Bearer "+session.Token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("zkclient: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil || apiErr.Code == "" {
+			apiErr.Code = http.StatusText(resp.StatusCode)
+			apiErr.Message = "unexpected error response"
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("zkclient: failed to decode response: %w", err)
+	}
+
+	return nil
+}
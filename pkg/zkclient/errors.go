@@ -0,0 +1,34 @@
+package zkclient
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotAuthenticated is returned by operations that need a logged in client
+	ErrNotAuthenticated = errors.New("zkclient: not authenticated")
+	// ErrServerProof is returned when the server fails to prove knowledge of
+	// the verifier, meaning it is not the server the account registered with
+	ErrServerProof = errors.New("zkclient: server proof verification failed")
+	// ErrOPAQUELogin is returned when the OPAQUE envelope cannot be opened or
+	// the server's MAC does not verify: a wrong password or the wrong server
+	ErrOPAQUELogin = errors.New("zkclient: OPAQUE login failed")
+	// ErrMFARequired is returned by a login that passed the password check
+	// but needs a second factor; complete it with LoginTOTP
+	ErrMFARequired = errors.New("zkclient: second factor required")
+)
+
+// Error is an error response returned by the zk-auth server
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Details    string `json:"details,omitempty"`
+	// RetryAfter is set, in seconds, when a login is delayed after failures
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("zkclient: %s (%d): %s", e.Code, e.StatusCode, e.Message)
+}
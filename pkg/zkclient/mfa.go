@@ -0,0 +1,64 @@
+package zkclient
+
+import (
+	"context"
+	"net/http"
+)
+
+// EnrollTOTP starts TOTP enrollment for the logged in account. The secret
+// only becomes a second factor after ConfirmTOTP.
+func (c *Client) EnrollTOTP(ctx context.Context) (*TOTPEnrollment, error) {
+	var enrollment TOTPEnrollment
+	if err := c.do(ctx, http.MethodPost, "/mfa/totp/enroll", struct{}{}, &enrollment, true); err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+// ConfirmTOTP enables TOTP with a code from the enrolled authenticator
+func (c *Client) ConfirmTOTP(ctx context.Context, code string) error {
+	return c.do(ctx, http.MethodPost, "/mfa/totp/confirm", &totpCodeRequest{Code: code}, nil, true)
+}
+
+// LoginTOTP completes a login that returned ErrMFARequired. A wrong code can
+// be retried a few times before the login has to start over.
+func (c *Client) LoginTOTP(ctx context.Context, code string) (*Session, error) {
+	c.mu.RLock()
+	username, ticket := c.username, c.mfaTicket
+	c.mu.RUnlock()
+	if ticket == "" {
+		return nil, ErrNotAuthenticated
+	}
+
+	var resp refreshResponse
+	req := &totpCodeRequest{MFATicket: ticket, Code: code}
+	if err := c.do(ctx, http.MethodPost, "/auth/mfa/totp", req, &resp, false); err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		Token:            resp.Token,
+		ExpiresAt:        resp.ExpiresAt,
+		RefreshToken:     resp.RefreshToken,
+		RefreshExpiresAt: resp.RefreshExpiresAt,
+	}
+
+	c.mu.Lock()
+	c.username = username
+	c.session = session
+	c.mfaTicket = ""
+	c.mu.Unlock()
+
+	return c.Session(), nil
+}
+
+// awaitMFA remembers a login waiting for its second factor
+func (c *Client) awaitMFA(username, ticket string) error {
+	c.mu.Lock()
+	c.username = username
+	c.session = nil
+	c.mfaTicket = ticket
+	c.mu.Unlock()
+
+	return ErrMFARequired
+}
@@ -0,0 +1,243 @@
+package zkclient
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+)
+
+// opaqueContext must match the server's OPAQUE context
+const opaqueContext = "zk-auth"
+
+// RegisterOPAQUE creates an account that logs in with OPAQUE instead of SRP.
+// The server never learns the password or anything derived from it offline.
+func (c *Client) RegisterOPAQUE(ctx context.Context, username, password string) (*Registration, error) {
+	record, ksf, err := c.opaqueRecord(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp registerResponse
+	finishReq := &opaqueRegisterFinishRequest{
+		Username: username,
+		Record:   hex.EncodeToString(record.Bytes()),
+		KSF:      &ksf,
+	}
+	if err := c.do(ctx, http.MethodPost, "/opaque/register/finish", finishReq, &resp, false); err != nil {
+		return nil, err
+	}
+
+	return &Registration{
+		UserID:   resp.UserID,
+		Username: resp.Username,
+	}, nil
+}
+
+// LoginOPAQUE runs an OPAQUE login. The server is authenticated by its MAC
+// in KE2 before the token is requested.
+func (c *Client) LoginOPAQUE(ctx context.Context, username, password string) (*Session, error) {
+	login, ke1, err := crypto.NewOPAQUEClient(opaqueContext).StartLogin([]byte(password))
+	if err != nil {
+		return nil, fmt.Errorf("zkclient: %w", err)
+	}
+
+	var start opaqueLoginStartResponse
+	startReq := &opaqueLoginStartRequest{
+		Username:   username,
+		KE1:        hex.EncodeToString(ke1),
+		DeviceName: c.device(),
+	}
+	if err := c.do(ctx, http.MethodPost, "/opaque/login/start", startReq, &start, false); err != nil {
+		return nil, err
+	}
+
+	ksf, err := kdfFor(start.KSF)
+	if err != nil {
+		return nil, err
+	}
+	ke2, err := hex.DecodeString(start.KE2)
+	if err != nil {
+		return nil, fmt.Errorf("zkclient: invalid ke2: %w", err)
+	}
+
+	// Fails for a wrong password as well as for a server that does not hold
+	// the account's record
+	ke3, _, _, err := login.Finish(ksf, []byte(username), ke2)
+	if err != nil {
+		return nil, ErrOPAQUELogin
+	}
+
+	var finish opaqueLoginFinishResponse
+	finishReq := &opaqueLoginFinishRequest{
+		SessionID: start.SessionID,
+		KE3:       hex.EncodeToString(ke3),
+	}
+	if err := c.do(ctx, http.MethodPost, "/opaque/login/finish", finishReq, &finish, false); err != nil {
+		return nil, err
+	}
+	if finish.MFAPending {
+		return nil, c.awaitMFA(username, finish.MFATicket)
+	}
+
+	session := &Session{
+		Token:            finish.Token,
+		ExpiresAt:        finish.ExpiresAt,
+		RefreshToken:     finish.RefreshToken,
+		RefreshExpiresAt: finish.RefreshExpiresAt,
+	}
+
+	c.mu.Lock()
+	c.username = username
+	c.session = session
+	c.mu.Unlock()
+
+	return c.Session(), nil
+}
+
+// ChangePasswordOPAQUE changes the password of an OPAQUE account. The
+// current password is proven with a fresh OPAQUE handshake bound to the
+// current session. The server signs out every other session of the account.
+func (c *Client) ChangePasswordOPAQUE(ctx context.Context, currentPassword, newPassword string) error {
+	c.mu.RLock()
+	username := c.username
+	c.mu.RUnlock()
+	if username == "" {
+		return ErrNotAuthenticated
+	}
+
+	record, ksf, err := c.opaqueRecord(ctx, username, newPassword)
+	if err != nil {
+		return err
+	}
+	proof, err := c.reauthOPAQUE(ctx, username, currentPassword)
+	if err != nil {
+		return err
+	}
+
+	req := &opaqueChangePasswordRequest{
+		opaqueProof: *proof,
+		Record:      hex.EncodeToString(record.Bytes()),
+		KSF:         &ksf,
+	}
+	return c.do(ctx, http.MethodPut, "/auth/password", req, nil, true)
+}
+
+// GenerateRecoveryCodesOPAQUE is GenerateRecoveryCodes for OPAQUE accounts
+func (c *Client) GenerateRecoveryCodesOPAQUE(ctx context.Context, password string) ([]string, error) {
+	c.mu.RLock()
+	username := c.username
+	c.mu.RUnlock()
+	if username == "" {
+		return nil, ErrNotAuthenticated
+	}
+
+	proof, err := c.reauthOPAQUE(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp recoveryCodesResponse
+	if err := c.do(ctx, http.MethodPost, "/auth/recovery-codes", proof, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Codes, nil
+}
+
+// DeleteAccountOPAQUE is DeleteAccount for OPAQUE accounts
+func (c *Client) DeleteAccountOPAQUE(ctx context.Context, password string) (*time.Time, error) {
+	c.mu.RLock()
+	username := c.username
+	c.mu.RUnlock()
+	if username == "" {
+		return nil, ErrNotAuthenticated
+	}
+
+	proof, err := c.reauthOPAQUE(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp deleteAccountResponse
+	if err := c.do(ctx, http.MethodDelete, "/account", proof, &resp, true); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.username = ""
+	c.session = nil
+	c.mu.Unlock()
+
+	return resp.DeleteAfter, nil
+}
+
+// opaqueRecord registers password with the server's OPRF key for username
+// and returns the record to upload
+func (c *Client) opaqueRecord(ctx context.Context, username, password string) (*crypto.OPAQUERecord, crypto.KDFParams, error) {
+	registration, request, err := crypto.NewOPAQUEClient(opaqueContext).StartRegistration([]byte(password))
+	if err != nil {
+		return nil, crypto.KDFParams{}, fmt.Errorf("zkclient: %w", err)
+	}
+
+	var start opaqueRegisterStartResponse
+	startReq := &opaqueRegisterStartRequest{
+		Username:            username,
+		RegistrationRequest: hex.EncodeToString(request),
+	}
+	if err := c.do(ctx, http.MethodPost, "/opaque/register/start", startReq, &start, false); err != nil {
+		return nil, crypto.KDFParams{}, err
+	}
+
+	ksf, err := kdfFor(start.KSF)
+	if err != nil {
+		return nil, crypto.KDFParams{}, err
+	}
+	response, err := hex.DecodeString(start.RegistrationResponse)
+	if err != nil {
+		return nil, crypto.KDFParams{}, fmt.Errorf("zkclient: invalid registration_response: %w", err)
+	}
+
+	record, _, err := registration.Finish(ksf, []byte(username), response)
+	if err != nil {
+		return nil, crypto.KDFParams{}, fmt.Errorf("zkclient: %w", err)
+	}
+	return record, ksf, nil
+}
+
+// reauthOPAQUE runs an OPAQUE handshake bound to the current session and
+// returns the proof for a sensitive operation. The server is authenticated
+// by its MAC in KE2.
+func (c *Client) reauthOPAQUE(ctx context.Context, username, password string) (*opaqueProof, error) {
+	login, ke1, err := crypto.NewOPAQUEClient(opaqueContext).StartLogin([]byte(password))
+	if err != nil {
+		return nil, fmt.Errorf("zkclient: %w", err)
+	}
+
+	var start opaqueReauthResponse
+	startReq := &opaqueReauthRequest{KE1: hex.EncodeToString(ke1)}
+	if err := c.do(ctx, http.MethodPost, "/opaque/reauth", startReq, &start, true); err != nil {
+		return nil, err
+	}
+
+	ksf, err := kdfFor(start.KSF)
+	if err != nil {
+		return nil, err
+	}
+	ke2, err := hex.DecodeString(start.KE2)
+	if err != nil {
+		return nil, fmt.Errorf("zkclient: invalid ke2: %w", err)
+	}
+
+	ke3, _, _, err := login.Finish(ksf, []byte(username), ke2)
+	if err != nil {
+		return nil, ErrOPAQUELogin
+	}
+
+	return &opaqueProof{
+		ChallengeID: start.ChallengeID,
+		KE3:         hex.EncodeToString(ke3),
+	}, nil
+}
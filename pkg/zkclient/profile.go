@@ -0,0 +1,50 @@
+package zkclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Profile holds the account's editable attributes along with some account
+// state
+type Profile struct {
+	UserID      string          `json:"user_id"`
+	Username    string          `json:"username"`
+	DisplayName string          `json:"display_name"`
+	Email       string          `json:"email"`
+	Locale      string          `json:"locale"`
+	Metadata    json.RawMessage `json:"metadata"`
+	// RecoveryCodesRemaining is the number of unused recovery codes
+	RecoveryCodesRemaining int `json:"recovery_codes_remaining"`
+	// DeleteAfter is set while the account is scheduled for deletion
+	DeleteAfter *time.Time `json:"delete_after,omitempty"`
+}
+
+// ProfileUpdate changes the fields that are set. Empty strings clear a
+// field; Metadata replaces the whole object, up to 4 KiB of JSON.
+type ProfileUpdate struct {
+	DisplayName *string         `json:"display_name,omitempty"`
+	Email       *string         `json:"email,omitempty"`
+	Locale      *string         `json:"locale,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+}
+
+// Profile returns the account's profile
+func (c *Client) Profile(ctx context.Context) (*Profile, error) {
+	var profile Profile
+	if err := c.do(ctx, http.MethodGet, "/profile", nil, &profile, true); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// UpdateProfile applies update and returns the resulting profile
+func (c *Client) UpdateProfile(ctx context.Context, update *ProfileUpdate) (*Profile, error) {
+	var profile Profile
+	if err := c.do(ctx, http.MethodPatch, "/profile", update, &profile, true); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
@@ -0,0 +1,61 @@
+package zkclient
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+)
+
+// GenerateRecoveryCodes proves knowledge of password with a fresh SRP
+// handshake and returns a new batch of single-use recovery codes. Codes
+// generated earlier stop working.
+func (c *Client) GenerateRecoveryCodes(ctx context.Context, password string) ([]string, error) {
+	c.mu.RLock()
+	username := c.username
+	c.mu.RUnlock()
+	if username == "" {
+		return nil, ErrNotAuthenticated
+	}
+
+	var challenge reauthChallengeResponse
+	if err := c.do(ctx, http.MethodPost, "/auth/reauth", struct{}{}, &challenge, true); err != nil {
+		return nil, err
+	}
+
+	hs, err := c.answerChallenge(username, password, challenge.srpParams, challenge.Salt, challenge.ServerB)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp recoveryCodesResponse
+	req := &recoveryCodesRequest{
+		ChallengeID: challenge.ChallengeID,
+		ClientA:     hex.EncodeToString(hs.A.Bytes()),
+		ClientProof: hex.EncodeToString(hs.M1),
+	}
+	if err := c.do(ctx, http.MethodPost, "/auth/recovery-codes", req, &resp, true); err != nil {
+		return nil, err
+	}
+
+	if err := hs.checkServerProof(resp.ServerProof); err != nil {
+		return nil, err
+	}
+	return resp.Codes, nil
+}
+
+// RecoverAccount spends a recovery code to set newPassword for an account
+// whose password was forgotten. Every session of the account is signed out;
+// log in again with the new password.
+func (c *Client) RecoverAccount(ctx context.Context, username, recoveryCode, newPassword string) error {
+	creds, err := c.newCredentials(ctx, username, newPassword)
+	if err != nil {
+		return err
+	}
+
+	req := &recoverAccountRequest{
+		Username:     username,
+		RecoveryCode: recoveryCode,
+		credentials:  *creds,
+	}
+	return c.do(ctx, http.MethodPost, "/auth/recover", req, nil, false)
+}
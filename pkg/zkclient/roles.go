@@ -0,0 +1,52 @@
+package zkclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// UserRoles are the roles a user holds and the permissions they grant
+type UserRoles struct {
+	UserID      string   `json:"user_id"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// UserRoles returns the roles of the user with userID. It needs the
+// roles:read permission.
+func (c *Client) UserRoles(ctx context.Context, userID string) (*UserRoles, error) {
+	var resp UserRoles
+	if err := c.do(ctx, http.MethodGet, rolesPath(userID, ""), nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GrantRole gives the user with userID a role. It needs the roles:manage
+// permission.
+func (c *Client) GrantRole(ctx context.Context, userID, role string) (*UserRoles, error) {
+	var resp UserRoles
+	if err := c.do(ctx, http.MethodPut, rolesPath(userID, role), nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// RevokeRole takes a role from the user with userID. It needs the
+// roles:manage permission.
+func (c *Client) RevokeRole(ctx context.Context, userID, role string) (*UserRoles, error) {
+	var resp UserRoles
+	if err := c.do(ctx, http.MethodDelete, rolesPath(userID, role), nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func rolesPath(userID, role string) string {
+	path := "/admin/users/" + url.PathEscape(userID) + "/roles"
+	if role != "" {
+		path += "/" + url.PathEscape(role)
+	}
+	return path
+}
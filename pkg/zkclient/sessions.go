@@ -0,0 +1,44 @@
+package zkclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SessionInfo describes one of the account's open sessions
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	DeviceName string    `json:"device_name,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	// LastSeenAt is nil until the session has been used; it lags by up to
+	// a minute
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	// Current marks the session this client is logged in with
+	Current bool `json:"current"`
+}
+
+// Sessions lists the account's open sessions, newest first
+func (c *Client) Sessions(ctx context.Context) ([]SessionInfo, error) {
+	var resp struct {
+		Sessions []SessionInfo `json:"sessions"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/sessions", nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Sessions, nil
+}
+
+// RevokeSession signs out one of the account's sessions
+func (c *Client) RevokeSession(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/sessions/"+url.PathEscape(id), nil, nil, true)
+}
+
+// RevokeOtherSessions signs out every session except the current one
+func (c *Client) RevokeOtherSessions(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/sessions", nil, nil, true)
+}
@@ -0,0 +1,216 @@
+package zkclient
+
+import (
+	"time"
+
+	"github.com/francisco3ferraz/zk-auth/internal/crypto"
+)
+
+// Registration describes a newly created account
+type Registration struct {
+	UserID   string
+	Username string
+}
+
+// Session holds the tokens issued after a successful login or refresh
+type Session struct {
+	Token            string
+	ExpiresAt        time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// srpParams are the SRP parameters a verifier is computed with
+type srpParams struct {
+	SRPGroup      int               `json:"srp_group"`
+	HashAlgorithm string            `json:"hash_algorithm"`
+	SRPMode       string            `json:"srp_mode"`
+	KDF           *crypto.KDFParams `json:"kdf"`
+}
+
+// credentials is a client-computed salt and verifier with their parameters
+type credentials struct {
+	Salt          string            `json:"salt"`
+	Verifier      string            `json:"verifier"`
+	SRPGroup      int               `json:"srp_group"`
+	HashAlgorithm string            `json:"hash_algorithm"`
+	SRPMode       string            `json:"srp_mode"`
+	KDF           *crypto.KDFParams `json:"kdf,omitempty"`
+}
+
+type registrationParams struct {
+	srpParams
+}
+
+type registerRequest struct {
+	Username string `json:"username"`
+	credentials
+}
+
+type registerResponse struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Message  string `json:"message"`
+}
+
+type challengeRequest struct {
+	Username   string `json:"username"`
+	DeviceName string `json:"device_name,omitempty"`
+}
+
+type challengeResponse struct {
+	SessionID string `json:"session_id"`
+	Salt      string `json:"salt"`
+	ServerB   string `json:"server_b"`
+	srpParams
+}
+
+type verifyRequest struct {
+	SessionID   string `json:"session_id"`
+	ClientA     string `json:"client_a"`
+	ClientProof string `json:"client_proof"`
+}
+
+type verifyResponse struct {
+	Token            string     `json:"token"`
+	ServerProof      string     `json:"server_proof"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RefreshToken     string     `json:"refresh_token"`
+	RefreshExpiresAt time.Time  `json:"refresh_expires_at"`
+	Upgrade          *srpParams `json:"upgrade,omitempty"`
+	MFAPending       bool       `json:"mfa_pending"`
+	MFATicket        string     `json:"mfa_ticket"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type refreshResponse struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+type reauthChallengeResponse struct {
+	ChallengeID string `json:"challenge_id"`
+	Salt        string `json:"salt"`
+	ServerB     string `json:"server_b"`
+	srpParams
+}
+
+type changePasswordRequest struct {
+	ChallengeID string `json:"challenge_id"`
+	ClientA     string `json:"client_a"`
+	ClientProof string `json:"client_proof"`
+	credentials
+}
+
+type changePasswordResponse struct {
+	Message     string `json:"message"`
+	ServerProof string `json:"server_proof"`
+}
+
+type recoveryCodesRequest struct {
+	ChallengeID string `json:"challenge_id"`
+	ClientA     string `json:"client_a"`
+	ClientProof string `json:"client_proof"`
+}
+
+type recoveryCodesResponse struct {
+	Codes       []string `json:"codes"`
+	ServerProof string   `json:"server_proof"`
+}
+
+type deleteAccountRequest struct {
+	ChallengeID string `json:"challenge_id"`
+	ClientA     string `json:"client_a"`
+	ClientProof string `json:"client_proof"`
+}
+
+type deleteAccountResponse struct {
+	DeleteAfter *time.Time `json:"delete_after"`
+	ServerProof string     `json:"server_proof"`
+}
+
+type recoverAccountRequest struct {
+	Username     string `json:"username"`
+	RecoveryCode string `json:"recovery_code"`
+	credentials
+}
+
+type opaqueRegisterStartRequest struct {
+	Username            string `json:"username"`
+	RegistrationRequest string `json:"registration_request"`
+}
+
+type opaqueRegisterStartResponse struct {
+	RegistrationResponse string            `json:"registration_response"`
+	KSF                  *crypto.KDFParams `json:"ksf"`
+}
+
+type opaqueRegisterFinishRequest struct {
+	Username string            `json:"username"`
+	Record   string            `json:"record"`
+	KSF      *crypto.KDFParams `json:"ksf"`
+}
+
+type opaqueLoginStartRequest struct {
+	Username   string `json:"username"`
+	KE1        string `json:"ke1"`
+	DeviceName string `json:"device_name,omitempty"`
+}
+
+type opaqueLoginStartResponse struct {
+	SessionID string            `json:"session_id"`
+	KE2       string            `json:"ke2"`
+	KSF       *crypto.KDFParams `json:"ksf"`
+}
+
+type opaqueLoginFinishRequest struct {
+	SessionID string `json:"session_id"`
+	KE3       string `json:"ke3"`
+}
+
+type opaqueLoginFinishResponse struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	MFAPending       bool      `json:"mfa_pending"`
+	MFATicket        string    `json:"mfa_ticket"`
+}
+
+type opaqueReauthRequest struct {
+	KE1 string `json:"ke1"`
+}
+
+type opaqueReauthResponse struct {
+	ChallengeID string            `json:"challenge_id"`
+	KE2         string            `json:"ke2"`
+	KSF         *crypto.KDFParams `json:"ksf"`
+}
+
+// opaqueProof answers an OPAQUE re-authentication
+type opaqueProof struct {
+	ChallengeID string `json:"challenge_id"`
+	KE3         string `json:"ke3"`
+}
+
+type opaqueChangePasswordRequest struct {
+	opaqueProof
+	Record string            `json:"record"`
+	KSF    *crypto.KDFParams `json:"ksf"`
+}
+
+// TOTPEnrollment is a new TOTP secret to add to an authenticator app
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type totpCodeRequest struct {
+	MFATicket string `json:"mfa_ticket,omitempty"`
+	Code      string `json:"code"`
+}
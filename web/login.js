@@ -0,0 +1,63 @@
+// Login page of the OpenID Connect provider. The SRP handshake runs in the
+// WebAssembly build of pkg/zkclient (cmd/zkwasm), so the password never
+// leaves the browser. Accounts with TOTP are then asked for a code. The
+// resulting session is exchanged for an authorization code.
+(async () => {
+  const form = document.getElementById('login');
+  const button = form.querySelector('button');
+  const status = document.getElementById('status');
+  const code = document.getElementById('code');
+  let mfaPending = false;
+
+  try {
+    const go = new Go();
+    const wasm = await WebAssembly.instantiateStreaming(fetch('/oauth/static/zkclient.wasm'), go.importObject);
+    go.run(wasm.instance);
+    button.disabled = false;
+  } catch (err) {
+    status.textContent = 'Failed to load the login client.';
+    return;
+  }
+
+  form.addEventListener('submit', async (event) => {
+    event.preventDefault();
+    button.disabled = true;
+    status.textContent = '';
+
+    try {
+      let token;
+      if (mfaPending) {
+        token = await zkAuthTOTP(form.code.value);
+      } else {
+        const result = await zkAuthLogin(form.username.value, form.password.value);
+        if (result.mfaRequired) {
+          mfaPending = true;
+          form.username.disabled = true;
+          form.password.disabled = true;
+          code.hidden = false;
+          form.code.disabled = false;
+          form.code.focus();
+          button.disabled = false;
+          return;
+        }
+        token = result.token;
+      }
+      const resp = await fetch('/oauth/authorize', {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+          'Authorization': 'Bearer ' + token,
+        },
+        body: form.dataset.request,
+      });
+      const body = await resp.json();
+      if (!resp.ok) {
+        throw new Error(body.message || 'Authorization failed.');
+      }
+      window.location.assign(body.redirect_to);
+    } catch (err) {
+      status.textContent = err.message;
+      button.disabled = false;
+    }
+  });
+})();